@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/execution"
+	"github.com/georlav/bitstamp-cli/internal/guard"
+	"github.com/georlav/bitstamp-cli/internal/private"
+	"github.com/georlav/bitstamp-cli/internal/profile"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+)
+
+// profileOrders sends orders with the accounts of profiles through the guardrails, triggers
+// and jobs use it so their orders keep going to the profile that created them
+type profileOrders struct {
+	accounts *profile.Accounts
+	guard    *guard.Guard
+}
+
+// guardedAccount the account of a profile whose orders are checked by the guardrails
+type guardedAccount struct {
+	guard.Trader
+	account *profile.Account
+}
+
+// Returns the account of a profile
+func (o profileOrders) account(ctx context.Context, name string) (guardedAccount, error) {
+	acc, err := o.accounts.Account(ctx, name)
+	if err != nil {
+		return guardedAccount{}, err
+	}
+
+	return guardedAccount{Trader: o.guard.Through(acc), account: acc}, nil
+}
+
+// Returns the account the triggers of a profile send orders with
+func (o profileOrders) trigger(ctx context.Context, name string) (trigger.Account, error) {
+	acc, err := o.account(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+// Returns the account the jobs of a profile send orders with
+func (o profileOrders) job(ctx context.Context, name string) (execution.Account, error) {
+	acc, err := o.account(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+// GetOrderStatus retrieves the status of an order of the account
+func (a guardedAccount) GetOrderStatus(ctx context.Context, r bitstamp.GetOrderStatusRequest) (*bitstamp.GetOrderStatusResponse, error) {
+	return a.account.GetOrderStatus(ctx, r)
+}
+
+// OrderStatus retrieves the status of an order of the account with every field of its transactions
+func (a guardedAccount) OrderStatus(ctx context.Context, id string) (*private.OrderStatus, error) {
+	return a.account.OrderStatus(ctx, id)
+}
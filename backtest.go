@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/candlestore"
+	"github.com/georlav/bitstamp-cli/internal/config"
+	"github.com/georlav/bitstamp-cli/internal/fee"
+	"github.com/georlav/bitstamp-cli/internal/strategy"
+)
+
+// Backtests the strategy rules over cached candles, missing candles are fetched and cached
+func runBacktest(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	file := fs.String("file", cfg.GetStrategiesFile(), "strategy rules file")
+	name := fs.String("rule", "", "only backtest the rule with this name")
+	from := fs.String("from", "", "first day tested, YYYY-MM-DD in UTC, defaults to 30 days ago")
+	to := fs.String("to", "", "day the test ends before, YYYY-MM-DD in UTC, defaults to now")
+	rate := fs.Float64("fee", fee.DefaultTaker*100, "fee in percent paid by simulated orders")
+	fills := fs.Bool("fills", false, "list the simulated orders of every rule")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	end, start := time.Now(), time.Now().AddDate(0, 0, -30)
+	for _, d := range []struct {
+		value string
+		t     *time.Time
+	}{{*from, &start}, {*to, &end}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.Parse(dateLayout, d.value)
+		if err != nil {
+			return fmt.Errorf("invalid date %s, expected YYYY-MM-DD", d.value)
+		}
+		*d.t = t
+	}
+	if !start.Before(end) {
+		return errors.New("from must be before to")
+	}
+
+	rules, err := strategy.Load(*file)
+	if err != nil {
+		return err
+	}
+	if *name != "" {
+		var selected []strategy.Rule
+		for _, r := range rules {
+			if r.Name == *name {
+				selected = append(selected, r)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("unknown rule %s", *name)
+		}
+		rules = selected
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("no rules in %s", *file)
+	}
+
+	candles := candlestore.New(cfg.GetCacheDir(), bitstamp.NewHTTPAPI())
+	results := make([]strategy.Result, 0, len(rules))
+	for _, r := range rules {
+		result, err := strategy.Backtest(ctx, candles, r, start, end, *rate/100)
+		if err != nil {
+			return fmt.Errorf("failed to backtest rule %s, %w", r.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	if err := writeBacktestTable(os.Stdout, results); err != nil {
+		return err
+	}
+	if *fills {
+		fmt.Println()
+		return writeFillsTable(os.Stdout, results)
+	}
+
+	return nil
+}
+
+// Writes the summary of backtest results as an aligned table
+func writeBacktestTable(w io.Writer, results []strategy.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tPAIR\tTF\tACTION\tCANDLES\tSIGNALS\tFILLS\tPOSITION\tFEES\tPNL\tMAX DD\tBUY & HOLD")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%.2f\t%.2f\t%.2f\t%.2f%%\n", r.Rule.Name, strings.ToUpper(r.Rule.Pair),
+			r.Rule.Timeframe, r.Rule.Action, r.Candles, r.Signals, len(r.Fills),
+			strconv.FormatFloat(r.Paper.Position, 'f', -1, 64), r.Paper.Fees, r.PnL(), r.MaxDrawdown, r.BuyHold())
+	}
+
+	return tw.Flush()
+}
+
+// Writes the simulated orders of backtest results as an aligned table
+func writeFillsTable(w io.Writer, results []strategy.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tTIME\tSIDE\tAMOUNT\tPRICE")
+	for _, r := range results {
+		for _, f := range r.Fills {
+			side := "buy"
+			if f.Sell {
+				side = "sell"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Rule.Name, f.Time.UTC().Format("2006-01-02 15:04"), side,
+				strconv.FormatFloat(f.Amount, 'f', -1, 64), strconv.FormatFloat(f.Price, 'f', -1, 64))
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/execution"
+	"github.com/georlav/bitstamp-cli/internal/fee"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+)
+
+// commandUsage lists the commands accepted by the command prompt
+const commandUsage = "commands: stop|take <amount> <price>, trail <amount> <percent>, " +
+	"oco <amount> <take> <stop>, bracket <amount> <entry> <take> <stop>, " +
+	"twap buy|sell <amount> <slices> <minutes>, iceberg buy|sell <amount> <visible>, " +
+	"pov buy|sell <amount> <percent>, pause|resume <job>, cancel <trigger|job>, quote buy|sell <amount>, " +
+	"compare <pair> <pair>..."
+
+// maxCompared maximum number of pairs of the comparison chart
+const maxCompared = 6
+
+// commands runs commands entered in the command prompt
+type commands struct {
+	triggers *trigger.Manager
+	jobs     *execution.Engine
+	state    func() app.State
+	// compare replaces the pairs of the comparison chart
+	compare func(pairs []bitstamp.Pair)
+}
+
+// Runs a command against the active pair and returns a message describing its outcome,
+// triggers and jobs send their orders using the account of the profile active when they are added
+func (c commands) run(ctx context.Context, profile string, p bitstamp.Pair, line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", errors.New(commandUsage)
+	}
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "stop", "take":
+		prices, err := parseArgs(args, 2)
+		if err != nil {
+			return "", err
+		}
+
+		t, err := c.triggers.Add(trigger.Trigger{Profile: profile, Pair: p.String(), Kind: trigger.Kind(name), Amount: args[0], Price: prices[1]})
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("added %s trigger %s", t.Kind, t.ID), nil
+
+	case "trail":
+		values, err := parseArgs(args, 2)
+		if err != nil {
+			return "", err
+		}
+
+		t, err := c.triggers.Add(trigger.Trigger{Profile: profile, Pair: p.String(), Kind: trigger.KindTrailingStop, Amount: args[0], Trail: values[1] / 100})
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("added trailing stop %s", t.ID), nil
+
+	case "oco":
+		prices, err := parseArgs(args, 3)
+		if err != nil {
+			return "", err
+		}
+		if prices[1] <= prices[2] {
+			return "", errors.New("oco take profit must be above the stop loss")
+		}
+
+		group := c.triggers.Group()
+		var ids []string
+		for _, t := range []trigger.Trigger{
+			{Profile: profile, Pair: p.String(), Kind: trigger.KindTakeProfit, Amount: args[0], Price: prices[1], Group: group},
+			{Profile: profile, Pair: p.String(), Kind: trigger.KindStopLoss, Amount: args[0], Price: prices[2], Group: group},
+		} {
+			t, err := c.triggers.Add(t)
+			if err != nil {
+				return "", err
+			}
+			ids = append(ids, t.ID)
+		}
+
+		return fmt.Sprintf("added oco %s triggers %s", group, strings.Join(ids, ", ")), nil
+
+	case "bracket":
+		prices, err := parseArgs(args, 4)
+		if err != nil {
+			return "", err
+		}
+
+		triggers, err := c.triggers.Bracket(ctx, profile, p, args[0], prices[1], prices[2], prices[3])
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("sent bracket entry order %s", triggers[0].EntryOrderID), nil
+
+	case "twap", "iceberg", "pov":
+		n := map[string]int{"twap": 4, "iceberg": 3, "pov": 3}[name]
+		if len(args) != n || (args[0] != "buy" && args[0] != "sell") {
+			return "", fmt.Errorf("unknown %s arguments, %s", name, commandUsage)
+		}
+		values, err := parseArgs(args[1:], n-1)
+		if err != nil {
+			return "", err
+		}
+
+		params := execution.Params{Kind: execution.Kind(name), Profile: profile, Pair: p, Sell: args[0] == "sell", Amount: values[0]}
+		switch params.Kind {
+		case execution.KindTWAP:
+			if values[1] != float64(int(values[1])) {
+				return "", errors.New("twap slices must be a whole number")
+			}
+			params.Slices = int(values[1])
+			params.Duration = time.Duration(values[2] * float64(time.Minute))
+		case execution.KindIceberg:
+			params.Visible = values[1]
+		case execution.KindPOV:
+			params.Rate = values[1] / 100
+		}
+
+		j, err := c.jobs.Start(ctx, params)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("started %s job %s, arrival price %s", j.Kind, j.ID, strconv.FormatFloat(j.Arrival, 'f', -1, 64)), nil
+
+	case "quote":
+		if len(args) != 2 || (args[0] != "buy" && args[0] != "sell") {
+			return "", errors.New("usage: quote buy|sell <amount>")
+		}
+		values, err := parseArgs(args[1:], 1)
+		if err != nil {
+			return "", err
+		}
+
+		s := c.state()
+		e, err := fee.EstimateOrder(app.ParseLevels(s.Book.Bids), app.ParseLevels(s.Book.Asks), args[0] == "sell", values[0], fee.Taker(s.Fees, p))
+		if err != nil {
+			return "", err
+		}
+
+		return strings.ToUpper(p.String()) + " " + e.String(), nil
+
+	case "compare":
+		if len(args) < 2 || len(args) > maxCompared {
+			return "", fmt.Errorf("usage: compare <pair> <pair>..., up to %d pairs", maxCompared)
+		}
+
+		pairs := make([]bitstamp.Pair, 0, len(args))
+		for _, a := range args {
+			cp, err := findPair(a)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, cp)
+		}
+		c.compare(pairs)
+
+		return "comparing " + strings.ToUpper(strings.Join(args, ", ")), nil
+
+	case "pause", "resume":
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: %s <job>", name)
+		}
+
+		change := c.jobs.Pause
+		if name == "resume" {
+			change = c.jobs.Resume
+		}
+		if err := change(args[0]); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%sd job %s", name, args[0]), nil
+
+	case "cancel":
+		if len(args) != 1 {
+			return "", errors.New("usage: cancel <trigger|job>")
+		}
+
+		// job ids are prefixed with j, trigger ids are numbers
+		if strings.HasPrefix(args[0], "j") {
+			if err := c.jobs.Cancel(args[0]); err != nil {
+				return "", err
+			}
+
+			return "cancelled job " + args[0], nil
+		}
+
+		if err := c.triggers.Cancel(args[0]); err != nil {
+			return "", err
+		}
+
+		return "cancelled trigger " + args[0], nil
+	}
+
+	return "", fmt.Errorf("unknown command %s, %s", name, commandUsage)
+}
+
+// Parses n numeric arguments
+func parseArgs(args []string, n int) ([]float64, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("expected %d arguments, %s", n, commandUsage)
+	}
+
+	values := make([]float64, 0, n)
+	for _, a := range args {
+		v, err := strconv.ParseFloat(a, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("%s is not a number greater than zero", a)
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// Returns the pair with the given name
+func findPair(name string) (bitstamp.Pair, error) {
+	for _, p := range bitstamp.GetAllPairs() {
+		if p.String() == strings.ToLower(name) {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown pair %s", name)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/arbitrage"
+	"github.com/georlav/bitstamp-cli/internal/orderbook"
+	"github.com/georlav/bitstamp-cli/internal/stream"
+)
+
+// Returns the handler of websocket events, data missed while disconnected is reloaded on reconnect
+func (t *terminal) handler() stream.Handler {
+	return stream.Handler{
+		Error: func(err error) {
+			t.report("websocket", err)
+		},
+		Connected: func(reconnect bool) {
+			t.store.Dispatch(app.ConnectionChanged{Connected: true})
+			if reconnect {
+				s := t.store.State()
+				go t.updateLiveTrades(s.ActivePair())
+				go t.updateChartData(s.ActivePair(), s.ChartStep())
+			}
+		},
+		Disconnected: func() {
+			t.store.Dispatch(app.ConnectionChanged{Connected: false})
+		},
+		Message: func(m interface{}) {
+			switch v := m.(type) {
+			case bitstamp.LiveDetailOrderBookChannel:
+				t.detailOrderBook(v)
+			case bitstamp.LiveOrderBookChannel:
+				t.orderBook(v)
+			case bitstamp.LiveTickerChannel:
+				t.trade(v)
+			}
+		},
+	}
+}
+
+// Returns the pair a channel name refers to
+func (t *terminal) channelPair(channel string) (bitstamp.Pair, bool) {
+	p, ok := t.pairMap[channel[strings.LastIndex(channel, "_")+1:]]
+	return p, ok
+}
+
+// Updates the book of the active pair and its quote
+func (t *terminal) detailOrderBook(v bitstamp.LiveDetailOrderBookChannel) {
+	p, ok := t.channelPair(v.Channel)
+	if !ok {
+		return
+	}
+
+	var ts time.Time
+	if us, err := strconv.ParseInt(v.Data.Microtimestamp, 10, 64); err == nil {
+		ts = time.UnixMicro(us)
+	}
+
+	book := app.Book{
+		Time: ts,
+		Bids: make([]app.Level, 0, len(v.Data.Bids)),
+		Asks: make([]app.Level, 0, len(v.Data.Asks)),
+	}
+	for i := range v.Data.Bids {
+		book.Bids = append(book.Bids, app.Level{Price: v.Data.Bids[i][0], Amount: v.Data.Bids[i][1]})
+	}
+	for i := range v.Data.Asks {
+		book.Asks = append(book.Asks, app.Level{Price: v.Data.Asks[i][0], Amount: v.Data.Asks[i][1]})
+	}
+
+	if len(v.Data.Bids) > 0 && len(v.Data.Asks) > 0 {
+		bid := orderbook.ParseLevel(v.Data.Bids[0][0], v.Data.Bids[0][1])
+		ask := orderbook.ParseLevel(v.Data.Asks[0][0], v.Data.Asks[0][1])
+		t.quotes.Book(p, bid.Price, ask.Price, time.Now())
+	}
+	t.store.Dispatch(app.BookReceived{Pair: p, Book: book})
+}
+
+// Updates the top of book of an arbitrage leg and its quote
+func (t *terminal) orderBook(v bitstamp.LiveOrderBookChannel) {
+	p, ok := t.channelPair(v.Channel)
+	if !ok || len(v.Data.Bids) == 0 || len(v.Data.Asks) == 0 {
+		return
+	}
+
+	bid := orderbook.ParseLevel(v.Data.Bids[0][0], v.Data.Bids[0][1])
+	ask := orderbook.ParseLevel(v.Data.Asks[0][0], v.Data.Asks[0][1])
+	t.quotes.Book(p, bid.Price, ask.Price, time.Now())
+	t.arb.Update(p, arbitrage.Quote{
+		Bid:       bid.Price,
+		BidAmount: bid.Amount,
+		Ask:       ask.Price,
+		AskAmount: ask.Amount,
+		Time:      time.Now(),
+	})
+}
+
+// Passes a trade to every service using the trades of its pair and to the store
+func (t *terminal) trade(v bitstamp.LiveTickerChannel) {
+	p, ok := t.channelPair(v.Channel)
+	if !ok {
+		return
+	}
+
+	var ts time.Time
+	if us, err := strconv.ParseInt(v.Data.Microtimestamp, 10, 64); err == nil {
+		ts = time.UnixMicro(us)
+	} else if sec, err := strconv.ParseInt(v.Data.Timestamp, 10, 64); err == nil {
+		ts = time.Unix(sec, 0)
+	}
+
+	t.quotes.Trade(p, v.Data.Price, time.Now())
+	t.triggers.Trade(t.ctx, p, v.Data.Price, v.Data.Amount, v.Data.BuyOrderID, v.Data.SellOrderID)
+	t.jobs.Trade(p, v.Data.Amount)
+	t.board.Trade(p, v.Data.Price, v.Data.Amount)
+	t.strategies.Trade(p, v.Data.Price, v.Data.Amount, ts)
+
+	// the taker order is the one that matched existing orders of the book
+	orderID := v.Data.BuyOrderID
+	if v.Data.Type == 1 {
+		orderID = v.Data.SellOrderID
+	}
+
+	t.store.Dispatch(app.TradeReceived{Pair: p, Trade: app.Trade{
+		Amount:  v.Data.AmountStr,
+		Price:   v.Data.PriceStr,
+		Time:    ts,
+		Sell:    v.Data.Type == 1,
+		OrderID: orderID,
+	}})
+}
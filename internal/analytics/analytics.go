@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/orderbook"
+)
+
+const (
+	// Depth number of levels of each side used for imbalance
+	Depth = 10
+	// WallFactor a level is a wall when its amount is at least WallFactor times the median amount of its side
+	WallFactor = 5
+	// History how long samples are kept
+	History = time.Minute * 5
+	// SampleInterval minimum time between samples
+	SampleInterval = time.Second
+)
+
+// Stats order book statistics, spread and distances are in basis points of mid price
+type Stats struct {
+	BestBid   float64
+	BestAsk   float64
+	Spread    float64
+	SpreadBps float64
+	Mid       float64
+	Micro     float64
+	// Imbalance of top levels from -1 (only asks) to 1 (only bids)
+	Imbalance float64
+	Walls     []Wall
+}
+
+// Wall an unusually large resting order
+type Wall struct {
+	Bid         bool
+	Price       float64
+	Amount      float64
+	DistanceBps float64
+}
+
+// Sample a point of spread and imbalance history
+type Sample struct {
+	Time      time.Time
+	SpreadBps float64
+	Imbalance float64
+}
+
+// Compute calculates statistics of a book with levels sorted from the top of the
+// book, returns false when any side is empty
+func Compute(bids, asks []orderbook.Level) (Stats, bool) {
+	if len(bids) == 0 || len(asks) == 0 {
+		return Stats{}, false
+	}
+
+	s := Stats{
+		BestBid: bids[0].Price,
+		BestAsk: asks[0].Price,
+	}
+	s.Spread = s.BestAsk - s.BestBid
+	s.Mid = (s.BestAsk + s.BestBid) / 2
+	if s.Mid > 0 {
+		s.SpreadBps = s.Spread / s.Mid * 10000
+	}
+
+	// micro price weights best prices by the size resting on the opposite side
+	s.Micro = s.Mid
+	if size := bids[0].Amount + asks[0].Amount; size > 0 {
+		s.Micro = (s.BestBid*asks[0].Amount + s.BestAsk*bids[0].Amount) / size
+	}
+
+	bidVolume, askVolume := volume(bids, Depth), volume(asks, Depth)
+	if total := bidVolume + askVolume; total > 0 {
+		s.Imbalance = (bidVolume - askVolume) / total
+	}
+
+	s.Walls = append(walls(bids, true, s.Mid), walls(asks, false, s.Mid)...)
+
+	return s, true
+}
+
+// AddSample appends a sample when the last one is older than SampleInterval and
+// drops samples older than History. The given slice is never modified
+func AddSample(samples []Sample, s Sample) []Sample {
+	if n := len(samples); n > 0 && s.Time.Sub(samples[n-1].Time) < SampleInterval {
+		return samples
+	}
+
+	result := make([]Sample, 0, len(samples)+1)
+	for i := range samples {
+		if s.Time.Sub(samples[i].Time) <= History {
+			result = append(result, samples[i])
+		}
+	}
+
+	return append(result, s)
+}
+
+func volume(levels []orderbook.Level, depth int) float64 {
+	v := 0.0
+	for i := 0; i < len(levels) && i < depth; i++ {
+		v += levels[i].Amount
+	}
+
+	return v
+}
+
+func walls(levels []orderbook.Level, bid bool, mid float64) []Wall {
+	amounts := make([]float64, 0, len(levels))
+	for i := range levels {
+		amounts = append(amounts, levels[i].Amount)
+	}
+	sort.Float64s(amounts)
+	median := amounts[len(amounts)/2]
+
+	var result []Wall
+	for i := range levels {
+		if median <= 0 || levels[i].Amount < median*WallFactor {
+			continue
+		}
+
+		distance := 0.0
+		if mid > 0 {
+			distance = (levels[i].Price - mid) / mid * 10000
+			if distance < 0 {
+				distance = -distance
+			}
+		}
+
+		result = append(result, Wall{Bid: bid, Price: levels[i].Price, Amount: levels[i].Amount, DistanceBps: distance})
+	}
+
+	return result
+}
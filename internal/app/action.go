@@ -0,0 +1,166 @@
+package app
+
+import (
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/arbitrage"
+	"github.com/georlav/bitstamp-cli/internal/balance"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+	"github.com/georlav/bitstamp-cli/internal/compare"
+	"github.com/georlav/bitstamp-cli/internal/execution"
+	"github.com/georlav/bitstamp-cli/internal/notify"
+	"github.com/georlav/bitstamp-cli/internal/overview"
+	"github.com/georlav/bitstamp-cli/internal/strategy"
+	"github.com/georlav/bitstamp-cli/internal/transfer"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+)
+
+// Action describes a state transition, actions are applied by Reduce
+type Action interface{}
+
+// KeyPressed is dispatched for every keyboard or mouse event, Key uses termui event ids
+type KeyPressed struct {
+	Key string
+}
+
+// Resized is dispatched when the pair list changes height
+type Resized struct {
+	PageSize int
+}
+
+// LayoutSelected is dispatched to select a layout by name
+type LayoutSelected struct {
+	Name string
+}
+
+// TradeReceived is dispatched for every trade received from the live trades channel
+type TradeReceived struct {
+	Pair  bitstamp.Pair
+	Trade Trade
+}
+
+// TradesLoaded is dispatched when the recent trades of a pair have been retrieved
+type TradesLoaded struct {
+	Pair   bitstamp.Pair
+	Trades []Trade
+}
+
+// BookReceived is dispatched for every order book received from the detail order book channel
+type BookReceived struct {
+	Pair bitstamp.Pair
+	Book Book
+}
+
+// ChartLoaded is dispatched when the historical candles of a pair have been retrieved
+type ChartLoaded struct {
+	Pair    bitstamp.Pair
+	Step    time.Duration
+	Candles []candle.Candle
+}
+
+// WatchlistUpdated is dispatched with the statistics of the watchlist pairs
+type WatchlistUpdated struct {
+	Rows []overview.Row
+}
+
+// OverviewUpdated is dispatched with the statistics of the pairs of the selected market
+type OverviewUpdated struct {
+	Rows []overview.Row
+}
+
+// CompareSelected is dispatched when the pairs of the comparison chart are changed
+type CompareSelected struct {
+	Pairs []bitstamp.Pair
+}
+
+// ComparisonLoaded is dispatched when the candles of the compared pairs have been retrieved
+type ComparisonLoaded struct {
+	Pairs  []bitstamp.Pair
+	Step   time.Duration
+	Series []compare.Series
+}
+
+// PairsInfoLoaded is dispatched when the counter and base decimals of the trading pairs have been retrieved
+type PairsInfoLoaded struct {
+	Decimals     map[bitstamp.Pair]int
+	BaseDecimals map[bitstamp.Pair]int
+}
+
+// OpenOrdersLoaded is dispatched when the open orders of the account of a profile have been retrieved
+type OpenOrdersLoaded struct {
+	Profile string
+	Orders  []Order
+}
+
+// ProfileSelected is dispatched to select an account profile by name
+type ProfileSelected struct {
+	Name string
+}
+
+// BudgetUpdated is dispatched periodically with the remaining HTTP API request budget
+type BudgetUpdated struct {
+	Budget Budget
+}
+
+// ErrorOccurred is dispatched when an operation fails, Source describes the failed operation
+type ErrorOccurred struct {
+	Time   time.Time
+	Source string
+	Err    error
+}
+
+// TriggersUpdated is dispatched when order triggers change
+type TriggersUpdated struct {
+	Triggers []trigger.Trigger
+}
+
+// FeesLoaded is dispatched when the trading fees of the account of a profile have been retrieved
+type FeesLoaded struct {
+	Profile string
+	Fees    map[bitstamp.Pair]float64
+}
+
+// BalancesLoaded is dispatched when the balances of the account of a profile have been retrieved
+type BalancesLoaded struct {
+	Profile  string
+	Balances []balance.Balance
+}
+
+// TransfersLoaded is dispatched when the crypto deposits and withdrawals of the account of a profile have been retrieved
+type TransfersLoaded struct {
+	Profile   string
+	Transfers []transfer.Transfer
+}
+
+// ArbitrageUpdated is dispatched with the current triangular arbitrage opportunities
+type ArbitrageUpdated struct {
+	Opportunities []arbitrage.Opportunity
+	Triangles     int
+}
+
+// JobsUpdated is dispatched when execution jobs change
+type JobsUpdated struct {
+	Jobs []execution.Job
+}
+
+// StrategiesUpdated is dispatched when the status of strategy rules changes
+type StrategiesUpdated struct {
+	Statuses []strategy.Status
+}
+
+// AlertRaised is dispatched for every trigger, job and strategy event, whether or not it is notified
+type AlertRaised struct {
+	Event notify.Event
+}
+
+// MessageShown is dispatched to show the outcome of a command
+type MessageShown struct {
+	Time time.Time
+	Text string
+}
+
+// ConnectionChanged is dispatched when the websocket connection is established or lost
+type ConnectionChanged struct {
+	Connected bool
+}
@@ -0,0 +1,351 @@
+package app
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/analytics"
+	"github.com/georlav/bitstamp-cli/internal/apperror"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+	"github.com/georlav/bitstamp-cli/internal/heatmap"
+	"github.com/georlav/bitstamp-cli/internal/notify"
+	"github.com/georlav/bitstamp-cli/internal/overview"
+	"github.com/georlav/bitstamp-cli/internal/tape"
+)
+
+// Reduce applies an action to a state and returns the resulting state
+func Reduce(s State, a Action) State {
+	switch a := a.(type) {
+	case KeyPressed:
+		return reduceKey(s, a.Key)
+
+	case Resized:
+		if a.PageSize > 0 {
+			s.PageSize = a.PageSize
+		}
+
+	case LayoutSelected:
+		for i := range s.Layouts {
+			if s.Layouts[i].Name == a.Name {
+				s.LayoutIndex, s.Focus, s.Maximised = i, 0, false
+			}
+		}
+
+	case TradeReceived:
+		if a.Pair != s.ActivePair() {
+			return s
+		}
+
+		if s.Tape == nil {
+			s.Tape = tape.New(maxTrades, s.MergeTrades)
+		}
+		s.Tape = s.Tape.Add(tapeTrade(a.Trade))
+
+		price, _ := strconv.ParseFloat(a.Trade.Price, 64)
+		amount, _ := strconv.ParseFloat(a.Trade.Amount, 64)
+		s.Candles = candle.Add(s.Candles, s.ChartStep(), price, amount, a.Trade.Time, MaxCandles)
+		s.Prints = heatmap.AddPrint(s.Prints, heatmap.Print{Time: a.Trade.Time, Price: price, Amount: amount, Sell: a.Trade.Sell})
+
+	case TradesLoaded:
+		if a.Pair != s.ActivePair() {
+			return s
+		}
+
+		// loaded trades are sorted from the most recent one, the tape is built with a single copy
+		trades := make([]tape.Trade, 0, len(a.Trades))
+		for i := len(a.Trades) - 1; i >= 0; i-- {
+			trades = append(trades, tapeTrade(a.Trades[i]))
+		}
+		s.Tape = tape.New(maxTrades, s.MergeTrades).AddAll(trades...)
+
+	case BookReceived:
+		if a.Pair != s.ActivePair() {
+			return s
+		}
+
+		s.Book = a.Book
+
+		bids, asks := ParseLevels(a.Book.Bids), ParseLevels(a.Book.Asks)
+		stats, ok := analytics.Compute(bids, asks)
+		if !ok {
+			return s
+		}
+		s.BookStats = stats
+		s.BookHistory = analytics.AddSample(s.BookHistory, analytics.Sample{
+			Time:      a.Book.Time,
+			SpreadBps: stats.SpreadBps,
+			Imbalance: stats.Imbalance,
+		})
+		s.Heatmap = heatmap.AddSnapshot(s.Heatmap, heatmap.Snapshot{
+			Time:   a.Book.Time,
+			Mid:    stats.Mid,
+			Levels: append(bids, asks...),
+		})
+
+	case PairsInfoLoaded:
+		s.Decimals = a.Decimals
+		s.BaseDecimals = a.BaseDecimals
+
+	case OpenOrdersLoaded:
+		// orders of the previous profile may arrive after switching
+		if a.Profile != s.ActiveProfile() {
+			return s
+		}
+		s.OpenOrders = a.Orders
+
+	case ProfileSelected:
+		for i := range s.Profiles {
+			if s.Profiles[i] == a.Name && i != s.ProfileIndex {
+				s.ProfileIndex, s.OpenOrders, s.Fees, s.Balances, s.Transfers = i, nil, nil, nil, nil
+			}
+		}
+
+	case ErrorOccurred:
+		n := len(s.Errors)
+		if n >= maxErrors {
+			n = maxErrors - 1
+		}
+		errs := make([]ErrorEntry, 0, n+1)
+		s.Errors = append(append(errs, ErrorEntry{
+			Time:    a.Time,
+			Kind:    apperror.Classify(a.Err),
+			Source:  a.Source,
+			Message: a.Err.Error(),
+		}), s.Errors[:n]...)
+
+	case TriggersUpdated:
+		s.Triggers = a.Triggers
+
+	case FeesLoaded:
+		// fees of the previous profile may arrive after switching
+		if a.Profile != s.ActiveProfile() {
+			return s
+		}
+		s.Fees = a.Fees
+
+	case BalancesLoaded:
+		if a.Profile != s.ActiveProfile() {
+			return s
+		}
+		s.Balances = a.Balances
+
+	case TransfersLoaded:
+		if a.Profile != s.ActiveProfile() {
+			return s
+		}
+		s.Transfers = a.Transfers
+
+	case ArbitrageUpdated:
+		s.Arbitrage, s.Triangles = a.Opportunities, a.Triangles
+
+	case JobsUpdated:
+		s.Jobs = a.Jobs
+
+	case StrategiesUpdated:
+		s.Strategies = a.Statuses
+
+	case MessageShown:
+		s.Message = Message{Time: a.Time, Text: a.Text}
+
+	case ConnectionChanged:
+		s.Connected = a.Connected
+
+	case BudgetUpdated:
+		s.Budget = a.Budget
+
+	case ChartLoaded:
+		if a.Pair != s.ActivePair() || a.Step != s.ChartStep() {
+			return s
+		}
+
+		s.Candles = candle.Merge(a.Candles, s.Candles, MaxCandles)
+
+	case WatchlistUpdated:
+		s.WatchlistRows = a.Rows
+
+	case AlertRaised:
+		n := len(s.Alerts)
+		if n >= maxAlerts {
+			n = maxAlerts - 1
+		}
+		alerts := make([]notify.Event, 0, n+1)
+		s.Alerts = append(append(alerts, a.Event), s.Alerts[:n]...)
+
+	case OverviewUpdated:
+		s.Overview = a.Rows
+
+	case CompareSelected:
+		s.Compare, s.Comparison = a.Pairs, nil
+
+	case ComparisonLoaded:
+		if a.Step != s.ChartStep() || !samePairs(a.Pairs, s.Compare) {
+			return s
+		}
+
+		s.Comparison = a.Series
+	}
+
+	return s
+}
+
+// reducePrompt edits the command prompt, enter submits the command and escape discards it
+func reducePrompt(s State, key string) State {
+	switch key {
+	case "<Escape>", "<C-c>":
+		s.Prompt, s.PromptText = false, ""
+	case "<Enter>":
+		if text := strings.TrimSpace(s.PromptText); text != "" {
+			s.Command = text
+			s.CommandSeq++
+		}
+		s.Prompt, s.PromptText = false, ""
+	case "<Backspace>", "<C-<Backspace>>":
+		if r := []rune(s.PromptText); len(r) > 0 {
+			s.PromptText = string(r[:len(r)-1])
+		}
+	case "<Space>":
+		s.PromptText += " "
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			s.PromptText += key
+		}
+	}
+
+	return s
+}
+
+func reduceKey(s State, key string) State {
+	if s.Prompt {
+		return reducePrompt(s, key)
+	}
+
+	if s.HelpVisible {
+		switch key {
+		case "h", "H":
+			s.HelpVisible = false
+		case "q", "Q", "<C-c>":
+			s.Quit = true
+		}
+
+		return s
+	}
+
+	if s.LogVisible {
+		switch key {
+		case "g", "G":
+			s.LogVisible = false
+		case "q", "Q", "<C-c>":
+			s.Quit = true
+		}
+
+		return s
+	}
+
+	switch key {
+	case "q", "Q", "<C-c>":
+		s.Quit = true
+	case "h", "H":
+		s.HelpVisible = true
+	case "g", "G":
+		s.LogVisible = true
+	case ":":
+		s.Prompt, s.PromptText = true, ""
+	case "l", "L":
+		if len(s.Layouts) > 0 {
+			s.LayoutIndex = (s.LayoutIndex + 1) % len(s.Layouts)
+			s.Focus, s.Maximised = 0, false
+		}
+	case "<Tab>":
+		if n := len(s.Layout().Panels()); n > 0 {
+			s.Focus = (s.Focus + 1) % n
+		}
+	case "m", "M":
+		s.Maximised = !s.Maximised
+	case "a", "A":
+		if len(s.Profiles) > 1 {
+			s.ProfileIndex = (s.ProfileIndex + 1) % len(s.Profiles)
+			s.OpenOrders, s.Fees, s.Balances, s.Transfers = nil, nil, nil, nil
+		}
+	case "t", "T":
+		s.Timeframe = (s.Timeframe + 1) % len(candle.Timeframes)
+		s.Candles, s.Comparison = nil, nil
+	case "+", "=":
+		if s.GroupIndex < len(s.GroupSteps())-1 {
+			s.GroupIndex++
+		}
+	case "-":
+		if s.GroupIndex > 0 {
+			s.GroupIndex--
+		}
+	case "v", "V":
+		s.BookVertical = !s.BookVertical
+	case "r", "R":
+		s.RelativeTime = !s.RelativeTime
+	case "o", "O":
+		s.OverviewSort = (s.OverviewSort + 1) % overview.Sort(len(overview.Sorts))
+	case "<Up>", "w", "W", "<MouseWheelUp>":
+		s = selectPair(s, s.ActivePair(), s.PairIndex-1)
+	case "<PageUp>":
+		s = selectPair(s, s.ActivePair(), s.PairIndex-s.PageSize)
+	case "<Down>", "s", "S", "<MouseWheelDown>":
+		s = selectPair(s, s.ActivePair(), s.PairIndex+1)
+	case "<PageDown>":
+		s = selectPair(s, s.ActivePair(), s.PairIndex+s.PageSize)
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		i := int(key[0] - '1')
+		if i < len(s.Markets) {
+			prev := s.ActivePair()
+			s.MarketIndex = i
+			s = selectPair(s, prev, 0)
+		}
+	}
+
+	return s
+}
+
+// selectPair changes the active pair and clears pair data when it differs from prev,
+// the pair selected before the market or index changed
+func selectPair(s State, prev bitstamp.Pair, i int) State {
+	if n := len(s.Pairs()); i >= n {
+		i = n - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+
+	s.PairIndex = i
+	if s.ActivePair() != prev {
+		s.Tape = nil
+		s.Book = Book{}
+		s.BookStats = analytics.Stats{}
+		s.BookHistory = nil
+		s.Heatmap, s.Prints = nil, nil
+		s.GroupIndex = 0
+		s.Candles = nil
+	}
+
+	return s
+}
+
+func tapeTrade(t Trade) tape.Trade {
+	price, _ := strconv.ParseFloat(t.Price, 64)
+	amount, _ := strconv.ParseFloat(t.Amount, 64)
+
+	return tape.Trade{Time: t.Time, Price: price, Amount: amount, Sell: t.Sell, OrderID: t.OrderID}
+}
+
+// samePairs reports whether two pair lists are equal
+func samePairs(a, b []bitstamp.Pair) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
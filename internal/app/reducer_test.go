@@ -0,0 +1,344 @@
+package app
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/balance"
+	"github.com/georlav/bitstamp-cli/internal/layout"
+	"github.com/georlav/bitstamp-cli/internal/transfer"
+)
+
+func testState() State {
+	s := NewState([]Market{
+		{Name: "USD", Pairs: []bitstamp.Pair{bitstamp.BTCUSD, bitstamp.ETHUSD, bitstamp.XRPUSD}},
+		{Name: "EUR", Pairs: []bitstamp.Pair{bitstamp.BTCEUR, bitstamp.ETHEUR}},
+	}, layout.Presets())
+	s.Profiles = []string{"main", "second"}
+	s.PageSize = 2
+
+	return s
+}
+
+func press(s State, keys ...string) State {
+	for _, k := range keys {
+		s = Reduce(s, KeyPressed{Key: k})
+	}
+
+	return s
+}
+
+func TestReduceKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		state func() State
+		keys  []string
+		check func(t *testing.T, s State)
+	}{
+		{
+			name: "quit",
+			keys: []string{"q"},
+			check: func(t *testing.T, s State) {
+				if !s.Quit {
+					t.Error("expected quit")
+				}
+			},
+		},
+		{
+			name: "help only closes with h or quits",
+			keys: []string{"h", "s", "l"},
+			check: func(t *testing.T, s State) {
+				if !s.HelpVisible || s.PairIndex != 0 || s.LayoutIndex != 0 {
+					t.Errorf("keys were handled behind help, pair %d layout %d", s.PairIndex, s.LayoutIndex)
+				}
+			},
+		},
+		{
+			name: "prompt collects text and submits it",
+			keys: []string{":", "b", "u", "y", "<Space>", "1", "<Backspace>", "2", "<Enter>"},
+			check: func(t *testing.T, s State) {
+				if s.Prompt || s.Command != "buy 2" || s.CommandSeq != 1 {
+					t.Errorf("got prompt %v command %q seq %d", s.Prompt, s.Command, s.CommandSeq)
+				}
+			},
+		},
+		{
+			name: "escape discards the prompt",
+			keys: []string{":", "x", "<Escape>"},
+			check: func(t *testing.T, s State) {
+				if s.Prompt || s.PromptText != "" || s.CommandSeq != 0 {
+					t.Errorf("got prompt %v text %q seq %d", s.Prompt, s.PromptText, s.CommandSeq)
+				}
+			},
+		},
+		{
+			name: "layout cycles and resets focus",
+			state: func() State {
+				s := testState()
+				s.Focus, s.Maximised = 2, true
+				return s
+			},
+			keys: []string{"l"},
+			check: func(t *testing.T, s State) {
+				if s.LayoutIndex != 1 || s.Focus != 0 || s.Maximised {
+					t.Errorf("got layout %d focus %d maximised %v", s.LayoutIndex, s.Focus, s.Maximised)
+				}
+			},
+		},
+		{
+			name: "tab cycles focus",
+			keys: []string{"<Tab>", "<Tab>"},
+			check: func(t *testing.T, s State) {
+				if want := 2 % len(s.Layout().Panels()); s.Focus != want {
+					t.Errorf("got focus %d, want %d", s.Focus, want)
+				}
+			},
+		},
+		{
+			name: "group steps are bounded",
+			keys: []string{"-", "+"},
+			check: func(t *testing.T, s State) {
+				if s.GroupIndex != 1 && len(s.GroupSteps()) > 1 {
+					t.Errorf("got group %d", s.GroupIndex)
+				}
+			},
+		},
+		{
+			name: "timeframe clears candles",
+			keys: []string{"t"},
+			state: func() State {
+				s := testState()
+				s.Candles = Reduce(s, TradeReceived{Pair: bitstamp.BTCUSD, Trade: Trade{Price: "1", Amount: "1", Time: time.Now()}}).Candles
+				return s
+			},
+			check: func(t *testing.T, s State) {
+				if s.Timeframe != defaultTimeframe+1 || s.Candles != nil {
+					t.Errorf("got timeframe %d candles %d", s.Timeframe, len(s.Candles))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testState()
+			if tt.state != nil {
+				s = tt.state()
+			}
+			tt.check(t, press(s, tt.keys...))
+		})
+	}
+}
+
+func TestReducePairSwitching(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		pair bitstamp.Pair
+	}{
+		{name: "down", keys: []string{"s"}, pair: bitstamp.ETHUSD},
+		{name: "up stops at the first pair", keys: []string{"w"}, pair: bitstamp.BTCUSD},
+		{name: "page down stops at the last pair", keys: []string{"<PageDown>", "<PageDown>"}, pair: bitstamp.XRPUSD},
+		{name: "page up", keys: []string{"<PageDown>", "<PageUp>"}, pair: bitstamp.BTCUSD},
+		{name: "market selects its first pair", keys: []string{"s", "2"}, pair: bitstamp.BTCEUR},
+		{name: "unknown market is ignored", keys: []string{"s", "9"}, pair: bitstamp.ETHUSD},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if s := press(testState(), tt.keys...); s.ActivePair() != tt.pair {
+				t.Errorf("got %s, want %s", s.ActivePair(), tt.pair)
+			}
+		})
+	}
+}
+
+func TestReducePairSwitchClearsPairData(t *testing.T) {
+	s := testState()
+	s = Reduce(s, TradeReceived{Pair: bitstamp.BTCUSD, Trade: Trade{Price: "100", Amount: "1", Time: time.Now()}})
+	s = Reduce(s, BookReceived{Pair: bitstamp.BTCUSD, Book: Book{
+		Time: time.Now(),
+		Bids: []Level{{Price: "99", Amount: "1"}},
+		Asks: []Level{{Price: "101", Amount: "1"}},
+	}})
+	s.GroupIndex = 1
+	if s.Tape == nil || len(s.Book.Bids) == 0 || len(s.Candles) == 0 {
+		t.Fatal("expected pair data before switching")
+	}
+
+	// data of another pair is ignored
+	if other := Reduce(s, TradeReceived{Pair: bitstamp.ETHUSD, Trade: Trade{Price: "1", Amount: "1"}}); len(other.Tape.Recent(10)) != 1 {
+		t.Error("trade of another pair was added to the tape")
+	}
+
+	// moving up from the first pair keeps it selected with its data
+	if kept := press(s, "w"); kept.Tape == nil || len(kept.Book.Bids) == 0 || kept.GroupIndex != 1 {
+		t.Error("data of the selected pair was cleared")
+	}
+
+	s = press(s, "s")
+	if s.Tape != nil || len(s.Book.Bids) != 0 || s.Candles != nil || s.BookHistory != nil || s.GroupIndex != 0 {
+		t.Errorf("pair data was not cleared, tape %v book %d candles %d group %d", s.Tape, len(s.Book.Bids), len(s.Candles), s.GroupIndex)
+	}
+}
+
+func TestReduceMarketSwitchClearsPairData(t *testing.T) {
+	s := testState()
+	s = Reduce(s, TradeReceived{Pair: bitstamp.BTCUSD, Trade: Trade{Price: "100", Amount: "1", Time: time.Now()}})
+	s = Reduce(s, BookReceived{Pair: bitstamp.BTCUSD, Book: Book{
+		Time: time.Now(),
+		Bids: []Level{{Price: "99", Amount: "1"}},
+		Asks: []Level{{Price: "101", Amount: "1"}},
+	}})
+	s.GroupIndex = 1
+	if s.PairIndex != 0 || s.Tape == nil || len(s.Book.Bids) == 0 || len(s.Candles) == 0 {
+		t.Fatal("expected pair data at the first pair before switching")
+	}
+
+	// the first pair of the selected market is still selected
+	if kept := press(s, "1"); kept.Tape == nil || len(kept.Book.Bids) == 0 || kept.GroupIndex != 1 {
+		t.Error("data of the selected pair was cleared")
+	}
+
+	s = press(s, "2")
+	if s.ActivePair() != bitstamp.BTCEUR {
+		t.Fatalf("got %s, want %s", s.ActivePair(), bitstamp.BTCEUR)
+	}
+	if s.Tape != nil || len(s.Book.Bids) != 0 || s.Candles != nil || s.BookHistory != nil || s.GroupIndex != 0 {
+		t.Errorf("pair data was not cleared, tape %v book %d candles %d group %d", s.Tape, len(s.Book.Bids), len(s.Candles), s.GroupIndex)
+	}
+}
+
+func TestReduceProfileSwitching(t *testing.T) {
+	loaded := func() State {
+		s := testState()
+		s = Reduce(s, OpenOrdersLoaded{Profile: "main", Orders: []Order{{Pair: bitstamp.BTCUSD}}})
+		s = Reduce(s, FeesLoaded{Profile: "main", Fees: map[bitstamp.Pair]float64{bitstamp.BTCUSD: 0.005}})
+		s = Reduce(s, BalancesLoaded{Profile: "main", Balances: []balance.Balance{{Currency: "btc"}}})
+		s = Reduce(s, TransfersLoaded{Profile: "main", Transfers: []transfer.Transfer{{Currency: "BTC"}}})
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		action  Action
+		profile string
+		cleared bool
+	}{
+		{name: "key", action: KeyPressed{Key: "a"}, profile: "second", cleared: true},
+		{name: "selected", action: ProfileSelected{Name: "second"}, profile: "second", cleared: true},
+		{name: "selected again", action: ProfileSelected{Name: "main"}, profile: "main"},
+		{name: "unknown", action: ProfileSelected{Name: "other"}, profile: "main"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := loaded()
+			if len(s.OpenOrders) == 0 || s.Fees == nil || s.Balances == nil || s.Transfers == nil {
+				t.Fatal("expected account data of the main profile")
+			}
+
+			s = Reduce(s, tt.action)
+			if s.ActiveProfile() != tt.profile {
+				t.Errorf("got profile %s, want %s", s.ActiveProfile(), tt.profile)
+			}
+			cleared := s.OpenOrders == nil && s.Fees == nil && s.Balances == nil && s.Transfers == nil
+			if cleared != tt.cleared {
+				t.Errorf("got account data cleared %v, want %v", cleared, tt.cleared)
+			}
+		})
+	}
+}
+
+func TestReduceStaleProfileData(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(profile string) Action
+		loaded func(s State) bool
+	}{
+		{
+			name: "open orders",
+			action: func(p string) Action {
+				return OpenOrdersLoaded{Profile: p, Orders: []Order{{Pair: bitstamp.BTCUSD}}}
+			},
+			loaded: func(s State) bool { return s.OpenOrders != nil },
+		},
+		{
+			name: "fees",
+			action: func(p string) Action {
+				return FeesLoaded{Profile: p, Fees: map[bitstamp.Pair]float64{bitstamp.BTCUSD: 0.005}}
+			},
+			loaded: func(s State) bool { return s.Fees != nil },
+		},
+		{
+			name: "balances",
+			action: func(p string) Action {
+				return BalancesLoaded{Profile: p, Balances: []balance.Balance{{Currency: "btc"}}}
+			},
+			loaded: func(s State) bool { return s.Balances != nil },
+		},
+		{
+			name: "transfers",
+			action: func(p string) Action {
+				return TransfersLoaded{Profile: p, Transfers: []transfer.Transfer{{Currency: "BTC"}}}
+			},
+			loaded: func(s State) bool { return s.Transfers != nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// data requested for the main profile arrives after switching to the second one
+			s := press(testState(), "a")
+			if s = Reduce(s, tt.action("main")); tt.loaded(s) {
+				t.Error("data of the previous profile was loaded")
+			}
+			if s = Reduce(s, tt.action("second")); !tt.loaded(s) {
+				t.Error("data of the active profile was not loaded")
+			}
+		})
+	}
+}
+
+func TestReduceTapeIsNotShared(t *testing.T) {
+	s := Reduce(testState(), TradeReceived{Pair: bitstamp.BTCUSD, Trade: Trade{Price: "100", Amount: "1", Time: time.Now()}})
+	next := Reduce(s, TradeReceived{Pair: bitstamp.BTCUSD, Trade: Trade{Price: "101", Amount: "2", Time: time.Now()}})
+
+	if got := len(s.Tape.Recent(10)); got != 1 {
+		t.Errorf("previous state tape has %d trades, want 1", got)
+	}
+	if got := len(next.Tape.Recent(10)); got != 2 {
+		t.Errorf("next state tape has %d trades, want 2", got)
+	}
+}
+
+func TestStoreConcurrentDispatch(t *testing.T) {
+	store := NewStore(testState())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Dispatch(TradeReceived{Pair: bitstamp.BTCUSD, Trade: Trade{Price: "100", Amount: "1", Time: time.Now()}})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if tp := store.State().Tape; tp != nil {
+					tp.Recent(maxTrades)
+					tp.Volumes(time.Now())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(store.State().Tape.Recent(maxTrades)); got != maxTrades {
+		t.Errorf("got %d trades, want %d", got, maxTrades)
+	}
+}
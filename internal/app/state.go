@@ -0,0 +1,296 @@
+package app
+
+import (
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/analytics"
+	"github.com/georlav/bitstamp-cli/internal/apperror"
+	"github.com/georlav/bitstamp-cli/internal/arbitrage"
+	"github.com/georlav/bitstamp-cli/internal/balance"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+	"github.com/georlav/bitstamp-cli/internal/compare"
+	"github.com/georlav/bitstamp-cli/internal/execution"
+	"github.com/georlav/bitstamp-cli/internal/heatmap"
+	"github.com/georlav/bitstamp-cli/internal/layout"
+	"github.com/georlav/bitstamp-cli/internal/notify"
+	"github.com/georlav/bitstamp-cli/internal/orderbook"
+	"github.com/georlav/bitstamp-cli/internal/overview"
+	"github.com/georlav/bitstamp-cli/internal/strategy"
+	"github.com/georlav/bitstamp-cli/internal/tape"
+	"github.com/georlav/bitstamp-cli/internal/transfer"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+)
+
+const (
+	// maxTrades is the number of live trades kept in state
+	maxTrades = 100
+	// MaxCandles is the number of chart candles kept in state
+	MaxCandles = 72
+	// maxErrors is the number of errors kept in the error log
+	maxErrors = 100
+	// maxAlerts is the number of events kept in the alerts panel
+	maxAlerts = 100
+	// defaultTimeframe index of the chart timeframe selected on start (1h)
+	defaultTimeframe = 3
+)
+
+// Market groups the pairs listed under a quote currency tab
+type Market struct {
+	Name  string
+	Pairs []bitstamp.Pair
+}
+
+// Trade a single executed trade of the active pair
+type Trade struct {
+	Amount string
+	Price  string
+	Time   time.Time
+	Sell   bool
+	// OrderID id of the taker order, zero when unknown
+	OrderID int64
+}
+
+// Level a single order book price level
+type Level struct {
+	Price  string
+	Amount string
+}
+
+// Book order book snapshot of the active pair
+type Book struct {
+	Time time.Time
+	Bids []Level
+	Asks []Level
+}
+
+// Order an open order of the account
+type Order struct {
+	Pair  bitstamp.Pair
+	Price string
+	Sell  bool
+}
+
+// State holds everything the ui needs to be drawn. State values are treated as
+// immutable, reducers always allocate new slices instead of modifying existing ones
+// and the trade tape is copied when a trade is added.
+type State struct {
+	Markets     []Market
+	MarketIndex int
+	PairIndex   int
+	PageSize    int
+	Tape        *tape.Tape
+	MergeTrades bool
+	LargeTrade  float64
+	// ReadOnly every order is rejected
+	ReadOnly    bool
+	Book        Book
+	BookStats   analytics.Stats
+	BookHistory []analytics.Sample
+	// Heatmap order book snapshots and trade prints of the heatmap panel
+	Heatmap    []heatmap.Snapshot
+	Prints     []heatmap.Print
+	GroupIndex int
+	// BookVertical shows asks above bids, BookDepth limits the levels shown per side when greater than zero
+	BookVertical bool
+	BookDepth    int
+	Decimals     map[bitstamp.Pair]int
+	BaseDecimals map[bitstamp.Pair]int
+	OpenOrders   []Order
+	// Fees taker fees of the pairs of the active profile as fractions, nil when unknown
+	Fees map[bitstamp.Pair]float64
+	// Balances non zero balances of the active profile sorted by currency
+	Balances []balance.Balance
+	// Transfers crypto deposits and withdrawals of the active profile, most recent first
+	Transfers []transfer.Transfer
+	// Compare pairs of the comparison chart, Comparison their performance over the chart timeframe
+	Compare    []bitstamp.Pair
+	Comparison []compare.Series
+	// Location time zone of displayed timestamps, local when nil, RelativeTime shows trade ages instead
+	Location     *time.Location
+	RelativeTime bool
+	// Watchlist pairs of the watchlist panel, WatchlistRows their 24 hour statistics
+	Watchlist     []bitstamp.Pair
+	WatchlistRows []overview.Row
+	// Alerts trigger, job and strategy events, most recent first
+	Alerts []notify.Event
+	// Overview 24 hour statistics of the pairs of the selected market, shown in OverviewSort order
+	Overview     []overview.Row
+	OverviewSort overview.Sort
+	// Arbitrage opportunities found among Triangles triangles of pairs
+	Arbitrage []arbitrage.Opportunity
+	Triangles int
+	// Profiles names of the account profiles, ProfileIndex is the active one
+	Profiles     []string
+	ProfileIndex int
+	Candles      []candle.Candle
+	Timeframe    int
+	Layouts      []layout.Layout
+	LayoutIndex  int
+	Focus        int
+	Maximised    bool
+	Budget       Budget
+	Connected    bool
+	Errors       []ErrorEntry
+	Triggers     []trigger.Trigger
+	Jobs         []execution.Job
+	// Strategies status of the strategy rules
+	Strategies []strategy.Status
+	// Prompt the command prompt is open, PromptText is the command being typed
+	Prompt     bool
+	PromptText string
+	// Command the last submitted command, CommandSeq changes on every submission
+	Command     string
+	CommandSeq  int
+	Message     Message
+	HelpVisible bool
+	LogVisible  bool
+	Quit        bool
+}
+
+// Message outcome of a command shown in the status bar
+type Message struct {
+	Time time.Time
+	Text string
+}
+
+// ErrorEntry an error of the error log
+type ErrorEntry struct {
+	Time    time.Time
+	Kind    apperror.Kind
+	Source  string
+	Message string
+}
+
+// Budget HTTP API requests that can still be made in the current rate limit window
+type Budget struct {
+	Remaining int
+	Limit     int
+}
+
+// NewState creates the initial application state, markets are expected to
+// contain sorted pairs, the first market and the first layout are selected
+func NewState(markets []Market, layouts []layout.Layout) State {
+	return State{
+		Markets:   markets,
+		Layouts:   layouts,
+		PageSize:  1,
+		Timeframe: defaultTimeframe,
+	}
+}
+
+// Pairs returns the pairs of the selected market
+func (s State) Pairs() []bitstamp.Pair {
+	if s.MarketIndex >= len(s.Markets) {
+		return nil
+	}
+
+	return s.Markets[s.MarketIndex].Pairs
+}
+
+// ActiveProfile returns the name of the active account profile
+func (s State) ActiveProfile() string {
+	if s.ProfileIndex >= len(s.Profiles) {
+		return ""
+	}
+
+	return s.Profiles[s.ProfileIndex]
+}
+
+// ActivePair returns the selected pair
+func (s State) ActivePair() bitstamp.Pair {
+	pairs := s.Pairs()
+	if s.PairIndex >= len(pairs) {
+		return 0
+	}
+
+	return pairs[s.PairIndex]
+}
+
+// PairRows returns the pairs of the selected market formatted as list rows
+func (s State) PairRows() []string {
+	pairs := s.Pairs()
+	rows := make([]string, 0, len(pairs))
+
+	for i := range pairs {
+		rows = append(rows, strings.ToUpper(pairs[i].String()))
+	}
+
+	return rows
+}
+
+// Zone returns the time zone of displayed timestamps
+func (s State) Zone() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+
+	return s.Location
+}
+
+// Layout returns the selected layout
+func (s State) Layout() layout.Layout {
+	if s.LayoutIndex >= len(s.Layouts) {
+		return layout.Layout{}
+	}
+
+	return s.Layouts[s.LayoutIndex]
+}
+
+// Shows reports whether the selected layout contains a panel
+func (s State) Shows(panel string) bool {
+	for _, name := range s.Layout().Panels() {
+		if name == panel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FocusedPanel returns the name of the focused panel of the selected layout
+func (s State) FocusedPanel() string {
+	panels := s.Layout().Panels()
+	if s.Focus >= len(panels) {
+		return ""
+	}
+
+	return panels[s.Focus]
+}
+
+// GroupSteps returns the order book grouping steps of the active pair, nil
+// while pair information is not available
+func (s State) GroupSteps() []float64 {
+	decimals, ok := s.Decimals[s.ActivePair()]
+	if !ok {
+		return nil
+	}
+
+	return orderbook.Steps(decimals)
+}
+
+// GroupStep returns the selected order book grouping step, zero when levels are not grouped
+func (s State) GroupStep() float64 {
+	steps := s.GroupSteps()
+	if s.GroupIndex >= len(steps) {
+		return 0
+	}
+
+	return steps[s.GroupIndex]
+}
+
+// ChartStep returns the duration of a chart candle
+func (s State) ChartStep() time.Duration {
+	return candle.Timeframes[s.Timeframe]
+}
+
+// ParseLevels converts book levels to numeric levels
+func ParseLevels(levels []Level) []orderbook.Level {
+	parsed := make([]orderbook.Level, 0, len(levels))
+	for i := range levels {
+		parsed = append(parsed, orderbook.ParseLevel(levels[i].Price, levels[i].Amount))
+	}
+
+	return parsed
+}
@@ -0,0 +1,36 @@
+package app
+
+import "sync"
+
+// Store holds the application state and serializes actions dispatched from
+// multiple goroutines
+type Store struct {
+	state State
+	mu    sync.RWMutex
+}
+
+// NewStore creates a store with an initial state
+func NewStore(s State) *Store {
+	return &Store{
+		state: s,
+	}
+}
+
+// Dispatch applies an action and returns the previous and the resulting state
+func (st *Store) Dispatch(a Action) (State, State) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	prev := st.state
+	st.state = Reduce(st.state, a)
+
+	return prev, st.state
+}
+
+// State returns the current state
+func (st *Store) State() State {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	return st.state
+}
@@ -0,0 +1,112 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp"
+)
+
+// Kind category of an error
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindNetwork
+	KindRateLimit
+	KindAuth
+	KindParse
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNetwork:
+		return "network"
+	case KindRateLimit:
+		return "rate limit"
+	case KindAuth:
+		return "auth"
+	case KindParse:
+		return "parse"
+	default:
+		return "error"
+	}
+}
+
+// Transient reports whether errors of this kind may succeed when retried
+func (k Kind) Transient() bool {
+	return k == KindNetwork || k == KindRateLimit
+}
+
+// Classify returns the category of an error
+func Classify(err error) Kind {
+	var (
+		apiErr    bitstamp.Error
+		netErr    net.Error
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return KindRateLimit
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return KindAuth
+		case apiErr.StatusCode >= http.StatusInternalServerError:
+			return KindNetwork
+		}
+
+		// authentication failures are not always reported with a matching status code
+		msg := strings.ToLower(apiErr.Message)
+		if strings.Contains(msg, "api key") || strings.Contains(msg, "signature") || strings.Contains(msg, "permission") {
+			return KindAuth
+		}
+
+		return KindUnknown
+
+	case errors.Is(err, bitstamp.ErrUnableToParseMessage), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return KindParse
+
+	case errors.Is(err, bitstamp.ErrReadMessage), errors.Is(err, bitstamp.ErrWriteMessage),
+		errors.Is(err, bitstamp.ErrReceivedReconnectMessage), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr):
+		return KindNetwork
+	}
+
+	return KindUnknown
+}
+
+// Retry calls fn until it succeeds, fails with an error that is not transient or attempts
+// are exhausted. Delay between attempts starts from delay and doubles after each attempt
+func Retry(ctx context.Context, attempts int, delay time.Duration, fn func() error) error {
+	var err error
+
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil || !Classify(err).Transient() {
+			return err
+		}
+
+		if i == attempts-1 {
+			break
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		delay *= 2
+	}
+
+	return err
+}
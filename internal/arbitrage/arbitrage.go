@@ -0,0 +1,250 @@
+package arbitrage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/fee"
+)
+
+// staleAfter age after which a top of book quote is no longer used
+const staleAfter = time.Second * 30
+
+// startCurrencies preferred currencies to start a cycle from, sizes are reported in them
+var startCurrencies = []string{"usd", "eur", "gbp", "btc"}
+
+// Market a trading pair and its currencies
+type Market struct {
+	Pair  bitstamp.Pair
+	Base  string
+	Quote string
+}
+
+// Quote top of the book of a pair, amounts are in base currency
+type Quote struct {
+	Bid       float64
+	BidAmount float64
+	Ask       float64
+	AskAmount float64
+	Time      time.Time
+}
+
+// Opportunity a cycle through three pairs starting and ending in Path[0]
+type Opportunity struct {
+	// Path currencies of the cycle, the first one is repeated at the end
+	Path  []string
+	Pairs []bitstamp.Pair
+	// Gross and Net return of the cycle as fractions, Net after taker fees
+	Gross float64
+	Net   float64
+	// Size amount of Path[0] executable at the top of the book of every leg
+	Size float64
+}
+
+// triangle three currencies connected by three markets
+type triangle struct {
+	currencies [3]string
+	markets    map[[2]string]Market
+}
+
+// Monitor tracks top of book quotes of the pairs of every triangle of its markets.
+// It is safe for concurrent use
+type Monitor struct {
+	triangles []triangle
+	quotes    map[bitstamp.Pair]Quote
+	mu        sync.Mutex
+}
+
+// New creates a monitor without markets
+func New() *Monitor {
+	return &Monitor{quotes: make(map[bitstamp.Pair]Quote)}
+}
+
+// SetMarkets finds the triangles among markets
+func (m *Monitor) SetMarkets(markets []Market) {
+	edges := make(map[[2]string]Market)
+	neighbours := make(map[string]map[string]bool)
+	for _, mk := range markets {
+		edges[key(mk.Base, mk.Quote)] = mk
+		for _, c := range [][2]string{{mk.Base, mk.Quote}, {mk.Quote, mk.Base}} {
+			if neighbours[c[0]] == nil {
+				neighbours[c[0]] = make(map[string]bool)
+			}
+			neighbours[c[0]][c[1]] = true
+		}
+	}
+
+	currencies := make([]string, 0, len(neighbours))
+	for c := range neighbours {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+
+	var triangles []triangle
+	for i, a := range currencies {
+		for j := i + 1; j < len(currencies); j++ {
+			b := currencies[j]
+			if !neighbours[a][b] {
+				continue
+			}
+			for k := j + 1; k < len(currencies); k++ {
+				c := currencies[k]
+				if !neighbours[b][c] || !neighbours[a][c] {
+					continue
+				}
+
+				t := triangle{currencies: [3]string{a, b, c}, markets: make(map[[2]string]Market, 3)}
+				for _, e := range [][2]string{key(a, b), key(b, c), key(a, c)} {
+					t.markets[e] = edges[e]
+				}
+				triangles = append(triangles, t)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.triangles = triangles
+}
+
+// Pairs returns the pairs of every triangle sorted by name
+func (m *Monitor) Pairs() []bitstamp.Pair {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[bitstamp.Pair]bool)
+	var pairs []bitstamp.Pair
+	for _, t := range m.triangles {
+		for _, mk := range t.markets {
+			if !seen[mk.Pair] {
+				seen[mk.Pair] = true
+				pairs = append(pairs, mk.Pair)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].String() < pairs[j].String() })
+
+	return pairs
+}
+
+// Triangles returns the number of triangles
+func (m *Monitor) Triangles() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.triangles)
+}
+
+// Update records the top of the book of a pair
+func (m *Monitor) Update(p bitstamp.Pair, q Quote) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.quotes[p] = q
+}
+
+// Opportunities returns cycles with a net return of at least threshold, best first.
+// Both directions of every triangle with fresh quotes are evaluated
+func (m *Monitor) Opportunities(fees map[bitstamp.Pair]float64, threshold float64, now time.Time) []Opportunity {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var opportunities []Opportunity
+	for _, t := range m.triangles {
+		start := startOf(t.currencies)
+
+		// the two other currencies in both orders
+		var others []string
+		for _, c := range t.currencies {
+			if c != start {
+				others = append(others, c)
+			}
+		}
+
+		for _, path := range [][]string{
+			{start, others[0], others[1], start},
+			{start, others[1], others[0], start},
+		} {
+			o, ok := m.evaluate(t, path, fees, now)
+			if ok && o.Net >= threshold {
+				opportunities = append(opportunities, o)
+			}
+		}
+	}
+
+	sort.Slice(opportunities, func(i, j int) bool { return opportunities[i].Net > opportunities[j].Net })
+
+	return opportunities
+}
+
+// evaluate converts one unit of path[0] through the legs of path, the executable size
+// is the smallest top of book amount of the legs expressed in path[0]
+func (m *Monitor) evaluate(t triangle, path []string, fees map[bitstamp.Pair]float64, now time.Time) (Opportunity, bool) {
+	o := Opportunity{Path: path, Size: math.Inf(1)}
+	gross, net := 1.0, 1.0
+
+	for i := 0; i < len(path)-1; i++ {
+		from, to := path[i], path[i+1]
+		mk := t.markets[key(from, to)]
+
+		q, ok := m.quotes[mk.Pair]
+		if !ok || now.Sub(q.Time) > staleAfter || q.Bid <= 0 || q.Ask <= 0 {
+			return o, false
+		}
+
+		// capacity of the leg in the currency spent, converted to path[0] by the rate so far
+		// spending the quote currency buys the base at the ask, otherwise the base is sold at the bid
+		var rate, capacity float64
+		if from == mk.Quote {
+			rate, capacity = 1/q.Ask, q.Ask*q.AskAmount
+		} else {
+			rate, capacity = q.Bid, q.BidAmount
+		}
+		o.Size = math.Min(o.Size, capacity/gross)
+
+		gross *= rate
+		net *= rate * (1 - fee.Taker(fees, mk.Pair))
+		o.Pairs = append(o.Pairs, mk.Pair)
+	}
+
+	o.Gross, o.Net = gross-1, net-1
+
+	return o, true
+}
+
+// startOf returns the preferred currency to start a cycle through currencies from
+func startOf(currencies [3]string) string {
+	for _, s := range startCurrencies {
+		for _, c := range currencies {
+			if c == s {
+				return c
+			}
+		}
+	}
+
+	return currencies[0]
+}
+
+// key returns the currencies of a market in a stable order
+func key(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+
+	return [2]string{a, b}
+}
+
+// ParseMarket returns the market of a pair from its name as listed by the trading pairs info, like ETH/BTC
+func ParseMarket(p bitstamp.Pair, name string) (Market, bool) {
+	parts := strings.Split(strings.ToLower(name), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Market{}, false
+	}
+
+	return Market{Pair: p, Base: parts[0], Quote: parts[1]}, true
+}
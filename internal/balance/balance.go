@@ -0,0 +1,57 @@
+package balance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/georlav/bitstamp"
+)
+
+// Balance amounts of a currency held by an account
+type Balance struct {
+	Currency  string
+	Total     float64
+	Available float64
+	Reserved  float64
+}
+
+// Parse returns the non zero balances listed in an account balance response sorted by
+// currency, bitstamp reports them as <currency>_balance, _available and _reserved fields
+func Parse(resp *bitstamp.GetAccountBalancesResponse) ([]Balance, error) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse account balances, %w", err)
+	}
+
+	var balances []Balance
+	for k, v := range fields {
+		currency := strings.TrimSuffix(k, "_balance")
+		if currency == k {
+			continue
+		}
+
+		total, err := strconv.ParseFloat(v, 64)
+		if err != nil || total == 0 {
+			continue
+		}
+
+		bal := Balance{Currency: currency, Total: total}
+		bal.Available, _ = strconv.ParseFloat(fields[currency+"_available"], 64)
+		bal.Reserved, _ = strconv.ParseFloat(fields[currency+"_reserved"], 64)
+		balances = append(balances, bal)
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Currency < balances[j].Currency
+	})
+
+	return balances, nil
+}
@@ -0,0 +1,96 @@
+package candle
+
+import (
+	"time"
+)
+
+// Candle open, high, low, close and volume of a time frame starting at Time
+type Candle struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Timeframes lists the candle steps supported by bitstamp OHLC data that can be selected
+var Timeframes = []time.Duration{
+	time.Minute,
+	time.Minute * 5,
+	time.Minute * 15,
+	time.Hour,
+	time.Hour * 4,
+	time.Hour * 24,
+}
+
+// Start returns the start time of the candle containing t
+func Start(t time.Time, step time.Duration) time.Time {
+	return t.Truncate(step)
+}
+
+// Add folds a trade into the candles and returns the resulting candles, the last
+// candle is updated when the trade belongs to it, otherwise a new candle is started.
+// Trades older than the last candle are ignored. The given slice is never modified
+// and at most max candles are returned
+func Add(candles []Candle, step time.Duration, price, amount float64, t time.Time, max int) []Candle {
+	start := Start(t, step)
+	n := len(candles)
+
+	if n > 0 && start.Before(candles[n-1].Time) {
+		return candles
+	}
+
+	result := make([]Candle, n, n+1)
+	copy(result, candles)
+
+	if n > 0 && start.Equal(result[n-1].Time) {
+		c := &result[n-1]
+		if price > c.High {
+			c.High = price
+		}
+		if price < c.Low {
+			c.Low = price
+		}
+		c.Close = price
+		c.Volume += amount
+
+		return result
+	}
+
+	result = append(result, Candle{Time: start, Open: price, High: price, Low: price, Close: price, Volume: amount})
+	if len(result) > max {
+		result = result[len(result)-max:]
+	}
+
+	return result
+}
+
+// Merge combines historical candles with candles built from live trades, history
+// takes precedence and live candles newer than history are appended
+func Merge(history, live []Candle, max int) []Candle {
+	result := make([]Candle, 0, len(history)+1)
+	result = append(result, history...)
+
+	for i := range live {
+		if n := len(result); n == 0 || live[i].Time.After(result[n-1].Time) {
+			result = append(result, live[i])
+		}
+	}
+
+	if len(result) > max {
+		result = result[len(result)-max:]
+	}
+
+	return result
+}
+
+// Closes returns the close prices of candles
+func Closes(candles []Candle) []float64 {
+	closes := make([]float64, 0, len(candles))
+	for i := range candles {
+		closes = append(closes, candles[i].Close)
+	}
+
+	return closes
+}
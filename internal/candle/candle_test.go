@@ -0,0 +1,116 @@
+package candle
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return t0.Add(time.Minute * time.Duration(minutes))
+}
+
+func TestAdd(t *testing.T) {
+	var candles []Candle
+	candles = Add(candles, time.Minute*5, 100, 1, at(1), 10)
+	candles = Add(candles, time.Minute*5, 105, 2, at(2), 10)
+	candles = Add(candles, time.Minute*5, 95, 1, at(3), 10)
+	candles = Add(candles, time.Minute*5, 98, 3, at(4), 10)
+
+	want := []Candle{{Time: at(0), Open: 100, High: 105, Low: 95, Close: 98, Volume: 7}}
+	if !reflect.DeepEqual(candles, want) {
+		t.Fatalf("got %+v, want %+v", candles, want)
+	}
+
+	next := Add(candles, time.Minute*5, 99, 1, at(6), 10)
+	if len(next) != 2 || next[1] != (Candle{Time: at(5), Open: 99, High: 99, Low: 99, Close: 99, Volume: 1}) {
+		t.Errorf("got %+v, want a new candle at %s", next, at(5))
+	}
+
+	// trades older than the last candle are ignored
+	if old := Add(next, time.Minute*5, 1, 1, at(4), 10); !reflect.DeepEqual(old, next) {
+		t.Errorf("old trade changed candles to %+v", old)
+	}
+}
+
+func TestAddDoesNotModifyInput(t *testing.T) {
+	candles := []Candle{{Time: at(0), Open: 100, High: 100, Low: 100, Close: 100, Volume: 1}}
+	Add(candles, time.Minute*5, 110, 1, at(1), 10)
+
+	if candles[0].High != 100 || candles[0].Volume != 1 {
+		t.Errorf("input candle was modified to %+v", candles[0])
+	}
+}
+
+func TestAddKeepsMax(t *testing.T) {
+	var candles []Candle
+	for i := 0; i < 5; i++ {
+		candles = Add(candles, time.Minute, float64(i), 1, at(i), 3)
+	}
+
+	if len(candles) != 3 || !candles[0].Time.Equal(at(2)) || !candles[2].Time.Equal(at(4)) {
+		t.Errorf("got %+v, want the candles of minutes 2 to 4", candles)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	c := func(minute int, close float64) Candle {
+		return Candle{Time: at(minute), Open: close, High: close, Low: close, Close: close}
+	}
+
+	tests := []struct {
+		name    string
+		history []Candle
+		live    []Candle
+		max     int
+		want    []Candle
+	}{
+		{
+			name: "no history",
+			live: []Candle{c(0, 1), c(1, 2)},
+			max:  10,
+			want: []Candle{c(0, 1), c(1, 2)},
+		},
+		{
+			name:    "no live candles",
+			history: []Candle{c(0, 1)},
+			max:     10,
+			want:    []Candle{c(0, 1)},
+		},
+		{
+			name:    "history takes precedence",
+			history: []Candle{c(0, 1), c(1, 2)},
+			live:    []Candle{c(1, 5), c(2, 6)},
+			max:     10,
+			want:    []Candle{c(0, 1), c(1, 2), c(2, 6)},
+		},
+		{
+			name:    "live candles older than history are dropped",
+			history: []Candle{c(2, 1)},
+			live:    []Candle{c(0, 5), c(1, 6)},
+			max:     10,
+			want:    []Candle{c(2, 1)},
+		},
+		{
+			name:    "max keeps the most recent",
+			history: []Candle{c(0, 1), c(1, 2)},
+			live:    []Candle{c(2, 3)},
+			max:     2,
+			want:    []Candle{c(1, 2), c(2, 3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Merge(tt.history, tt.live, tt.max)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,483 @@
+package candlestore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+)
+
+const (
+	// pageSize maximum number of candles returned by a single OHLC request
+	pageSize = 1000
+	// maxSegmentCandles a segment is not appended to after reaching this number of candles
+	maxSegmentCandles = 10000
+	// recordSize size of an encoded candle, unix time followed by open, high, low, close and volume
+	recordSize = 8 * 6
+	indexFile  = "index.json"
+)
+
+// Fetcher retrieves OHLC data, implemented by bitstamp.HTTPAPI
+type Fetcher interface {
+	GetOHLCData(ctx context.Context, p bitstamp.Pair, r bitstamp.GetOHLCDataRequest) (*bitstamp.GetOHLCDataResponse, error)
+}
+
+// Store serves candles from a file cache and fetches missing candles from bitstamp.
+// Candles of a pair and step are kept in append only segment files described by an
+// index, only closed candles are cached
+type Store struct {
+	dir     string
+	fetcher Fetcher
+	// mu guards the cache files and calls, fetches are made without holding it
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call a fetch in progress, concurrent requests of the same candles wait for it
+type call struct {
+	done    chan struct{}
+	candles []candle.Candle
+	err     error
+}
+
+type index struct {
+	// Since unix time history has been requested from, nothing older than the
+	// first cached candle is available after it
+	Since    int64     `json:"since"`
+	Segments []segment `json:"segments"`
+}
+
+type segment struct {
+	File  string `json:"file"`
+	First int64  `json:"first"`
+	Last  int64  `json:"last"`
+	Count int    `json:"count"`
+}
+
+// New creates a store caching candles under dir
+func New(dir string, f Fetcher) *Store {
+	return &Store{
+		dir:     dir,
+		fetcher: f,
+		calls:   make(map[string]*call),
+	}
+}
+
+// DefaultDir returns the candle cache directory under the user cache directory
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "bitstamp-cli", "candles")
+	}
+
+	return filepath.Join(dir, "bitstamp-cli", "candles")
+}
+
+// Candles returns the candles of a pair from the start of from until to, candles
+// missing from the cache are fetched and cached
+func (s *Store) Candles(ctx context.Context, p bitstamp.Pair, step time.Duration, from, to time.Time) ([]candle.Candle, error) {
+	dir := filepath.Join(s.dir, p.String(), strconv.FormatInt(int64(step.Seconds()), 10))
+
+	s.mu.Lock()
+	idx, err := readIndex(dir)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	from, to = candle.Start(from, step), candle.Start(to, step)
+	closed := candle.Start(time.Now(), step).Add(-step)
+
+	var open []candle.Candle
+	switch {
+	case len(idx.Segments) == 0:
+		fetched, err := s.fetch(ctx, p, step, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.store(dir, from, closedCandles(fetched, closed)); err != nil {
+			return nil, err
+		}
+		open = fetched
+
+	default:
+		first, last := idx.bounds()
+
+		// missing head, unless it was requested before and bitstamp had no older candles
+		if from.Before(first) && from.Unix() < idx.Since {
+			fetched, err := s.fetch(ctx, p, step, from, first.Add(-step))
+			if err != nil {
+				return nil, err
+			}
+			if err := s.store(dir, from, closedCandles(fetched, closed)); err != nil {
+				return nil, err
+			}
+		}
+
+		// missing tail
+		if to.After(last) {
+			fetched, err := s.fetch(ctx, p, step, last.Add(step), to)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.store(dir, last.Add(step), closedCandles(fetched, closed)); err != nil {
+				return nil, err
+			}
+			open = fetched
+		}
+	}
+
+	s.mu.Lock()
+	idx, err = readIndex(dir)
+	var cached []candle.Candle
+	if err == nil {
+		cached, err = readCandles(dir, idx, from, to)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// the candle in progress is never cached, take it from the last fetch
+	cached = candle.Merge(cached, open, math.MaxInt32)
+
+	result := make([]candle.Candle, 0, len(cached))
+	for i := range cached {
+		if !cached[i].Time.Before(from) && !cached[i].Time.After(to) {
+			result = append(result, cached[i])
+		}
+	}
+
+	return result, nil
+}
+
+// store caches fetched candles requested from the start of from. The index is read again
+// since it may have changed while fetching, candles cached meanwhile are skipped
+func (s *Store) store(dir string, from time.Time, candles []candle.Candle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := readIndex(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(idx.Segments) == 0 {
+		idx.Since = from.Unix()
+		return appendCandles(dir, &idx, candles)
+	}
+
+	first, last := idx.bounds()
+	var head, tail []candle.Candle
+	for i := range candles {
+		switch {
+		case candles[i].Time.Before(first):
+			head = append(head, candles[i])
+		case candles[i].Time.After(last):
+			tail = append(tail, candles[i])
+		}
+	}
+
+	since := idx.Since
+	if from.Unix() < idx.Since {
+		idx.Since = from.Unix()
+	}
+	if err := appendCandles(dir, &idx, head); err != nil {
+		return err
+	}
+	if err := appendCandles(dir, &idx, tail); err != nil {
+		return err
+	}
+	if len(head) == 0 && len(tail) == 0 && idx.Since != since {
+		return writeIndex(dir, idx)
+	}
+
+	return nil
+}
+
+// fetch retrieves candles from the start of from until to, concurrent requests of the
+// same candles share a single fetch
+func (s *Store) fetch(ctx context.Context, p bitstamp.Pair, step time.Duration, from, to time.Time) ([]candle.Candle, error) {
+	key := fmt.Sprintf("%s/%d/%d-%d", p, int64(step.Seconds()), from.Unix(), to.Unix())
+
+	s.mu.Lock()
+	if c, ok := s.calls[key]; ok {
+		s.mu.Unlock()
+
+		select {
+		case <-c.done:
+			return c.candles, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	s.calls[key] = c
+	s.mu.Unlock()
+
+	c.candles, c.err = s.fetchPages(ctx, p, step, from, to)
+
+	s.mu.Lock()
+	delete(s.calls, key)
+	s.mu.Unlock()
+	close(c.done)
+
+	return c.candles, c.err
+}
+
+// fetchPages retrieves candles from the start of from until to paging backwards from to
+func (s *Store) fetchPages(ctx context.Context, p bitstamp.Pair, step time.Duration, from, to time.Time) ([]candle.Candle, error) {
+	var pages [][]candle.Candle
+
+	for end := to; !end.Before(from); {
+		limit := int64(end.Sub(from)/step) + 1
+		if limit > pageSize {
+			limit = pageSize
+		}
+
+		result, err := s.fetcher.GetOHLCData(ctx, p, bitstamp.GetOHLCDataRequest{
+			End:   end.Unix(),
+			Step:  int64(step.Seconds()),
+			Limit: limit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve %s OHLC data, %w", p, err)
+		}
+
+		page := parseOHLC(result)
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+
+		next := page[0].Time.Add(-step)
+		if !next.Before(end) {
+			break
+		}
+		end = next
+	}
+
+	var candles []candle.Candle
+	for i := len(pages) - 1; i >= 0; i-- {
+		for _, c := range pages[i] {
+			if !c.Time.Before(from) && !c.Time.After(to) {
+				candles = append(candles, c)
+			}
+		}
+	}
+
+	return candles, nil
+}
+
+// parseOHLC converts OHLC data to candles sorted by time
+func parseOHLC(r *bitstamp.GetOHLCDataResponse) []candle.Candle {
+	candles := make([]candle.Candle, 0, len(r.Data.Ohlc))
+	for _, c := range r.Data.Ohlc {
+		ts, _ := strconv.ParseInt(c.Timestamp, 10, 64)
+		open, _ := strconv.ParseFloat(c.Open, 64)
+		high, _ := strconv.ParseFloat(c.High, 64)
+		low, _ := strconv.ParseFloat(c.Low, 64)
+		close, _ := strconv.ParseFloat(c.Close, 64)
+		volume, _ := strconv.ParseFloat(c.Volume, 64)
+
+		candles = append(candles, candle.Candle{
+			Time: time.Unix(ts, 0), Open: open, High: high, Low: low, Close: close, Volume: volume,
+		})
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+
+	return candles
+}
+
+func closedCandles(candles []candle.Candle, closed time.Time) []candle.Candle {
+	result := make([]candle.Candle, 0, len(candles))
+	for i := range candles {
+		if !candles[i].Time.After(closed) {
+			result = append(result, candles[i])
+		}
+	}
+
+	return result
+}
+
+func (idx index) bounds() (time.Time, time.Time) {
+	first, last := idx.Segments[0].First, idx.Segments[0].Last
+	for _, s := range idx.Segments {
+		if s.First < first {
+			first = s.First
+		}
+		if s.Last > last {
+			last = s.Last
+		}
+	}
+
+	return time.Unix(first, 0), time.Unix(last, 0)
+}
+
+func readIndex(dir string) (index, error) {
+	var idx index
+
+	b, err := os.ReadFile(filepath.Join(dir, indexFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return idx, nil
+	case err != nil:
+		return idx, fmt.Errorf("failed to read candle index, %w", err)
+	}
+
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return idx, fmt.Errorf("failed to parse candle index %s, %w", dir, err)
+	}
+
+	return idx, nil
+}
+
+// writeIndex replaces the index atomically
+func writeIndex(dir string, idx index) error {
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, indexFile+".tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write candle index, %w", err)
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, indexFile))
+}
+
+// appendCandles stores candles continuing the most recent segment when possible,
+// otherwise a new segment is created. Records are written before the index so
+// an interrupted write leaves unreferenced bytes that are ignored
+func appendCandles(dir string, idx *index, candles []candle.Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create candle cache directory, %w", err)
+	}
+
+	first, last := candles[0].Time.Unix(), candles[len(candles)-1].Time.Unix()
+	target := -1
+	for i, s := range idx.Segments {
+		if s.Last < first && s.Count+len(candles) <= maxSegmentCandles && (target == -1 || s.Last > idx.Segments[target].Last) {
+			target = i
+		}
+	}
+	if target != -1 {
+		_, cachedLast := idx.bounds()
+		if idx.Segments[target].Last != cachedLast.Unix() {
+			target = -1
+		}
+	}
+
+	if target == -1 {
+		idx.Segments = append(idx.Segments, segment{
+			File:  fmt.Sprintf("%06d.seg", len(idx.Segments)+1),
+			First: first,
+		})
+		target = len(idx.Segments) - 1
+	}
+	seg := &idx.Segments[target]
+
+	f, err := os.OpenFile(filepath.Join(dir, seg.File), os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open candle segment, %w", err)
+	}
+	defer f.Close()
+
+	// bytes after the indexed records belong to an interrupted write and are overwritten
+	if _, err := f.Seek(int64(seg.Count*recordSize), io.SeekStart); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, len(candles)*recordSize)
+	for _, c := range candles {
+		buf = encode(buf, c)
+	}
+	if _, err := f.Write(buf); err != nil {
+		return fmt.Errorf("failed to write candle segment, %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	seg.Last = last
+	seg.Count += len(candles)
+
+	return writeIndex(dir, *idx)
+}
+
+// readCandles reads the indexed candles from the start of from until to sorted by time,
+// only segments overlapping the range are read
+func readCandles(dir string, idx index, from, to time.Time) ([]candle.Candle, error) {
+	var candles []candle.Candle
+
+	for _, s := range idx.Segments {
+		if s.Last < from.Unix() || s.First > to.Unix() {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, s.File))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read candle segment, %w", err)
+		}
+		if len(b) < s.Count*recordSize {
+			return nil, fmt.Errorf("candle segment %s is truncated", s.File)
+		}
+
+		for i := 0; i < s.Count; i++ {
+			if c := decode(b[i*recordSize:]); !c.Time.Before(from) && !c.Time.After(to) {
+				candles = append(candles, c)
+			}
+		}
+	}
+
+	sort.SliceStable(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+
+	return candles, nil
+}
+
+func encode(buf []byte, c candle.Candle) []byte {
+	var b [recordSize]byte
+
+	binary.LittleEndian.PutUint64(b[0:], uint64(c.Time.Unix()))
+	for i, v := range []float64{c.Open, c.High, c.Low, c.Close, c.Volume} {
+		binary.LittleEndian.PutUint64(b[8*(i+1):], math.Float64bits(v))
+	}
+
+	return append(buf, b[:]...)
+}
+
+func decode(b []byte) candle.Candle {
+	v := func(i int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:]))
+	}
+
+	return candle.Candle{
+		Time:   time.Unix(int64(binary.LittleEndian.Uint64(b[0:])), 0),
+		Open:   v(1),
+		High:   v(2),
+		Low:    v(3),
+		Close:  v(4),
+		Volume: v(5),
+	}
+}
@@ -0,0 +1,225 @@
+package candlestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeFetcher serves one minute candles from first until last, the close of a candle
+// is its number of minutes after t0. Requests of the blocked pair wait for release
+type fakeFetcher struct {
+	first, last time.Time
+	requests    []bitstamp.GetOHLCDataRequest
+	blocked     bitstamp.Pair
+	release     chan struct{}
+	mu          sync.Mutex
+}
+
+func (f *fakeFetcher) GetOHLCData(ctx context.Context, p bitstamp.Pair, r bitstamp.GetOHLCDataRequest) (*bitstamp.GetOHLCDataResponse, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.mu.Unlock()
+
+	if f.release != nil && p == f.blocked {
+		<-f.release
+	}
+
+	type ohlc struct {
+		Timestamp string `json:"timestamp"`
+		Open      string `json:"open"`
+		High      string `json:"high"`
+		Low       string `json:"low"`
+		Close     string `json:"close"`
+		Volume    string `json:"volume"`
+	}
+	var data []ohlc
+	end := time.Unix(r.End, 0)
+	if end.After(f.last) {
+		end = f.last
+	}
+	for t := end; !t.Before(f.first) && int64(len(data)) < r.Limit; t = t.Add(-time.Duration(r.Step) * time.Second) {
+		v := strconv.Itoa(int(t.Sub(t0) / time.Minute))
+		data = append(data, ohlc{Timestamp: strconv.FormatInt(t.Unix(), 10), Open: v, High: v, Low: v, Close: v, Volume: "1"})
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"data": map[string]interface{}{"pair": "BTC/USD", "ohlc": data}})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp bitstamp.GetOHLCDataResponse
+	return &resp, json.Unmarshal(b, &resp)
+}
+
+func minute(n int) time.Time {
+	return t0.Add(time.Minute * time.Duration(n))
+}
+
+// checkRange checks that candles are the consecutive minutes from first to last
+func checkRange(t *testing.T, candles []candle.Candle, first, last int) {
+	t.Helper()
+
+	if len(candles) != last-first+1 {
+		t.Fatalf("got %d candles, want %d", len(candles), last-first+1)
+	}
+	for i, c := range candles {
+		if !c.Time.Equal(minute(first+i)) || c.Close != float64(first+i) {
+			t.Fatalf("candle %d is %s close %v, want %s", i, c.Time.UTC(), c.Close, minute(first+i))
+		}
+	}
+}
+
+func TestCandlesPaging(t *testing.T) {
+	f := &fakeFetcher{first: minute(0), last: minute(10000)}
+	s := New(t.TempDir(), f)
+
+	candles, err := s.Candles(context.Background(), bitstamp.BTCUSD, time.Minute, minute(100), minute(2599))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRange(t, candles, 100, 2599)
+
+	if len(f.requests) != 3 {
+		t.Errorf("got %d requests, want 3 pages", len(f.requests))
+	}
+	for _, r := range f.requests {
+		if r.Limit > pageSize || r.Step != 60 {
+			t.Errorf("got request %+v", r)
+		}
+	}
+}
+
+func TestCandlesCache(t *testing.T) {
+	dir := t.TempDir()
+	f := &fakeFetcher{first: minute(0), last: minute(10000)}
+	ctx := context.Background()
+
+	if _, err := New(dir, f).Candles(ctx, bitstamp.BTCUSD, time.Minute, minute(1000), minute(1999)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to int
+		requests int
+	}{
+		{name: "cached range", from: 1200, to: 1500, requests: 0},
+		{name: "missing head", from: 500, to: 1500, requests: 1},
+		{name: "missing tail", from: 1500, to: 2500, requests: 1},
+		{name: "head and tail cached by previous requests", from: 500, to: 2500, requests: 0},
+		{name: "missing head and tail", from: 0, to: 3000, requests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.requests = nil
+
+			// a new store reads the cache written by previous ones
+			candles, err := New(dir, f).Candles(ctx, bitstamp.BTCUSD, time.Minute, minute(tt.from), minute(tt.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRange(t, candles, tt.from, tt.to)
+
+			if len(f.requests) != tt.requests {
+				t.Errorf("got %d requests, want %d", len(f.requests), tt.requests)
+			}
+		})
+	}
+}
+
+func TestCandlesHistoryStart(t *testing.T) {
+	f := &fakeFetcher{first: minute(500), last: minute(10000)}
+	s := New(t.TempDir(), f)
+	ctx := context.Background()
+
+	if _, err := s.Candles(ctx, bitstamp.BTCUSD, time.Minute, minute(1000), minute(1100)); err != nil {
+		t.Fatal(err)
+	}
+
+	// bitstamp has nothing older than minute 500, the missing head is only requested once
+	for i, requested := range []bool{true, false} {
+		f.requests = nil
+		candles, err := s.Candles(ctx, bitstamp.BTCUSD, time.Minute, minute(0), minute(1100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRange(t, candles, 500, 1100)
+
+		if got := len(f.requests) > 0; got != requested {
+			t.Errorf("call %d got %d requests", i, len(f.requests))
+		}
+	}
+}
+
+func TestCandlesConcurrent(t *testing.T) {
+	f := &fakeFetcher{first: minute(0), last: minute(10000), blocked: bitstamp.BTCUSD, release: make(chan struct{})}
+	s := New(t.TempDir(), f)
+	ctx := context.Background()
+
+	// requests of the same candles are served while a fetch is in progress
+	var wg sync.WaitGroup
+	results := make([][]candle.Candle, 3)
+	errs := make([]error, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = s.Candles(ctx, bitstamp.BTCUSD, time.Minute, minute(100), minute(199))
+		}(i)
+	}
+
+	// other pairs are served while a fetch is in progress
+	for started := false; !started; {
+		f.mu.Lock()
+		started = len(f.requests) > 0
+		f.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan error, 1)
+	go func() {
+		candles, err := s.Candles(ctx, bitstamp.ETHUSD, time.Minute, minute(100), minute(199))
+		if err == nil && len(candles) != 100 {
+			err = fmt.Errorf("got %d candles, want 100", len(candles))
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("a fetch of another pair blocked the store")
+	}
+
+	close(f.release)
+	wg.Wait()
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		checkRange(t, results[i], 100, 199)
+	}
+
+	// every fetched candle is cached once
+	f.requests = nil
+	candles, err := s.Candles(ctx, bitstamp.BTCUSD, time.Minute, minute(0), minute(299))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRange(t, candles, 0, 299)
+	if len(f.requests) != 2 {
+		t.Errorf("got %d requests, want the missing head and tail", len(f.requests))
+	}
+}
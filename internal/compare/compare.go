@@ -0,0 +1,119 @@
+package compare
+
+import (
+	"math"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+)
+
+// Candles number of candles of each pair compared, a week of hourly candles
+const Candles = 168
+
+// Series performance of a pair in percent from the close of the first compared candle
+type Series struct {
+	Pair   bitstamp.Pair
+	Change []float64
+}
+
+// Last returns the change at the end of the series
+func (s Series) Last() float64 {
+	if len(s.Change) == 0 {
+		return 0
+	}
+
+	return s.Change[len(s.Change)-1]
+}
+
+// Correlation pearson correlation coefficient of the candle returns of two pairs
+type Correlation struct {
+	A, B bitstamp.Pair
+	R    float64
+}
+
+// Build rebases the closes of the candles of each pair to 0% at the start of the window,
+// only candles present for every pair are compared so the series are aligned in time
+func Build(pairs []bitstamp.Pair, candles [][]candle.Candle) []Series {
+	common := make(map[time.Time]int)
+	for _, cs := range candles {
+		for _, c := range cs {
+			common[c.Time]++
+		}
+	}
+
+	series := make([]Series, 0, len(pairs))
+	for i, p := range pairs {
+		s := Series{Pair: p}
+
+		first := 0.0
+		for _, c := range candles[i] {
+			if common[c.Time] != len(candles) || c.Close <= 0 {
+				continue
+			}
+			if first == 0 {
+				first = c.Close
+			}
+			s.Change = append(s.Change, (c.Close/first-1)*100)
+		}
+		series = append(series, s)
+	}
+
+	return series
+}
+
+// Correlations returns the correlation of every two series in the order given
+func Correlations(series []Series) []Correlation {
+	var result []Correlation
+	for i := range series {
+		for j := i + 1; j < len(series); j++ {
+			r := pearson(returns(series[i].Change), returns(series[j].Change))
+			result = append(result, Correlation{A: series[i].Pair, B: series[j].Pair, R: r})
+		}
+	}
+
+	return result
+}
+
+// returns converts rebased changes to candle over candle returns, correlating levels
+// would mostly measure a common trend
+func returns(change []float64) []float64 {
+	result := make([]float64, 0, len(change))
+	for i := 1; i < len(change); i++ {
+		result = append(result, (100+change[i])/(100+change[i-1])-1)
+	}
+
+	return result
+}
+
+// pearson returns the correlation coefficient of two samples, NaN when undefined
+func pearson(a, b []float64) float64 {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n < 2 {
+		return math.NaN()
+	}
+
+	var meanA, meanB float64
+	for i := 0; i < n; i++ {
+		meanA += a[i]
+		meanB += b[i]
+	}
+	meanA /= float64(n)
+	meanB /= float64(n)
+
+	var cov, varA, varB float64
+	for i := 0; i < n; i++ {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return math.NaN()
+	}
+
+	return cov / math.Sqrt(varA*varB)
+}
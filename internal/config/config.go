@@ -0,0 +1,342 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	// zone names are resolved on systems without a time zone database
+	_ "time/tzdata"
+
+	"github.com/georlav/bitstamp-cli/internal/candlestore"
+	"github.com/georlav/bitstamp-cli/internal/guard"
+	"github.com/georlav/bitstamp-cli/internal/layout"
+	"github.com/georlav/bitstamp-cli/internal/notify"
+	"github.com/georlav/bitstamp-cli/internal/profile"
+	"github.com/georlav/bitstamp-cli/internal/statusline"
+	"github.com/georlav/bitstamp-cli/internal/strategy"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+)
+
+// Config application configuration, loaded from a json file
+type Config struct {
+	// Layout name of the layout used on start
+	Layout string `json:"layout"`
+	// Layouts user defined layouts, a layout named as a preset replaces it
+	Layouts []layout.Layout `json:"layouts"`
+	// Tape live trades options
+	Tape Tape `json:"tape"`
+	// Book order book options
+	Book Book `json:"book"`
+	// CacheDir directory of the candle cache, defaults to a directory under the user cache directory
+	CacheDir string `json:"cache_dir"`
+	// RateLimit limits requests to the HTTP API
+	RateLimit RateLimit `json:"rate_limit"`
+	// Profile name of the account profile used on start, defaults to the first profile
+	Profile string `json:"profile"`
+	// Profiles exchange account profiles, credentials are read from the environment when empty
+	Profiles []profile.Profile `json:"profiles"`
+	// SecretsFile encrypted file holding profile credentials, defaults to a file under the user config directory
+	SecretsFile string `json:"secrets_file"`
+	// Guardrails rules applied to every order
+	Guardrails guard.Rules `json:"guardrails"`
+	// AuditLog file recording every attempted order, defaults to a file under the user config directory
+	AuditLog string `json:"audit_log"`
+	// TriggersFile file persisting order triggers, defaults to a file under the user config directory
+	TriggersFile string `json:"triggers_file"`
+	// Statusline options of the statusline subcommand
+	Statusline Statusline `json:"statusline"`
+	// Arbitrage triangular arbitrage monitor options
+	Arbitrage Arbitrage `json:"arbitrage"`
+	// Compare pair comparison chart options
+	Compare Compare `json:"compare"`
+	// Watchlist pairs of the watchlist panel, defaults to btcusd, ethusd, xrpusd and ltcusd
+	Watchlist []string `json:"watchlist"`
+	// Time display options of timestamps
+	Time Time `json:"time"`
+	// Notifications notifications of trigger, job and strategy events
+	Notifications Notifications `json:"notifications"`
+	// Strategies strategy rules options
+	Strategies Strategies `json:"strategies"`
+}
+
+// Strategies strategy rules options
+type Strategies struct {
+	// File rules file, defaults to a file under the user config directory
+	File string `json:"file"`
+	// RealOrders allows rules with the order action to send real orders, disabled by default
+	RealOrders bool `json:"real_orders"`
+}
+
+// Notifications notifications of trigger, job and strategy events
+type Notifications struct {
+	// Sinks notification backends, bell, osc9, osc777, notify-send and command, none by default
+	Sinks []string `json:"sinks"`
+	// Command command run for every notification by the command sink with the event as json on stdin
+	Command []string `json:"command"`
+	// PerMinute maximum notifications sent per minute
+	PerMinute int `json:"per_minute"`
+	// DedupeSeconds notifications identical to one sent within this many seconds are dropped
+	DedupeSeconds int `json:"dedupe_seconds"`
+}
+
+// Time display options of timestamps
+type Time struct {
+	// Zone time zone of displayed timestamps, local, UTC or an IANA zone name such as Europe/Athens, defaults to local
+	Zone string `json:"zone"`
+	// Relative shows the age of trades instead of their time
+	Relative bool `json:"relative"`
+}
+
+// Location returns the time zone of displayed timestamps
+func (t Time) Location() (*time.Location, error) {
+	switch {
+	case t.Zone == "" || strings.EqualFold(t.Zone, "local"):
+		return time.Local, nil
+	case strings.EqualFold(t.Zone, "utc"):
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(t.Zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load time zone %s, %w", t.Zone, err)
+	}
+
+	return loc, nil
+}
+
+// Compare pair comparison chart options
+type Compare struct {
+	// Pairs compared on start, defaults to btcusd and ethusd
+	Pairs []string `json:"pairs"`
+}
+
+// Arbitrage triangular arbitrage monitor options
+type Arbitrage struct {
+	// Threshold minimum net return in percent of listed opportunities, negative values list losing cycles too
+	Threshold float64 `json:"threshold"`
+}
+
+// Statusline options of the statusline subcommand
+type Statusline struct {
+	// Pairs printed by the statusline, defaults to btcusd
+	Pairs []string `json:"pairs"`
+	// Format text/template executed with the quotes of the pairs
+	Format string `json:"format"`
+	// Socket unix socket of the statusline daemon, defaults to a socket under the user runtime directory
+	Socket string `json:"socket"`
+}
+
+// RateLimit HTTP API request limits, bitstamp bans clients making more than 8000 requests per 10 minutes
+type RateLimit struct {
+	Requests      int `json:"requests"`
+	WindowSeconds int `json:"window_seconds"`
+	Burst         int `json:"burst"`
+}
+
+// Tape live trades options
+type Tape struct {
+	// Merge merges consecutive trades of the same taker order
+	Merge bool `json:"merge"`
+	// LargeTrade highlights trades with a value in quote currency of at least this amount, zero disables it
+	LargeTrade float64 `json:"large_trade"`
+}
+
+// Book order book options
+type Book struct {
+	// Vertical shows asks above bids instead of side by side
+	Vertical bool `json:"vertical"`
+	// Depth maximum levels shown per side, zero shows as many as fit the panel
+	Depth int `json:"depth"`
+}
+
+// DefaultPath returns the location of the configuration file under the user config directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "bitstamp-cli.json"
+	}
+
+	return filepath.Join(dir, "bitstamp-cli", "config.json")
+}
+
+// Load reads configuration from path, a missing file results in the default configuration
+func Load(path string) (*Config, error) {
+	cfg := Config{
+		RateLimit: RateLimit{
+			Requests:      6000,
+			WindowSeconds: 600,
+			Burst:         10,
+		},
+		Notifications: Notifications{
+			PerMinute:     6,
+			DedupeSeconds: 60,
+		},
+	}
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to open config file, %w", err)
+	default:
+		defer f.Close()
+
+		if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s, %w", path, err)
+		}
+	}
+
+	if cfg.RateLimit.Requests <= 0 || cfg.RateLimit.WindowSeconds <= 0 || cfg.RateLimit.Burst <= 0 {
+		return nil, errors.New("rate limit requests, window and burst must be greater than zero")
+	}
+
+	for i := range cfg.Layouts {
+		if err := cfg.Layouts[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, _, err := notify.Build(cfg.Notifications.Sinks, cfg.Notifications.Command); err != nil {
+		return nil, err
+	}
+	if cfg.Notifications.PerMinute <= 0 || cfg.Notifications.DedupeSeconds < 0 {
+		return nil, errors.New("notifications per minute must be greater than zero and dedupe seconds must not be negative")
+	}
+
+	if _, err := cfg.Time.Location(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Book.Depth < 0 {
+		return nil, errors.New("book depth must not be negative")
+	}
+
+	if cfg.Guardrails.MaxDeviation < 0 || cfg.Guardrails.DailyOrders < 0 {
+		return nil, errors.New("guardrails max deviation and daily orders must not be negative")
+	}
+
+	names := make(map[string]bool, len(cfg.Profiles))
+	for _, p := range cfg.Profiles {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("profile %s is defined more than once", p.Name)
+		}
+		names[p.Name] = true
+	}
+
+	return &cfg, nil
+}
+
+// GetLayouts returns the preset layouts followed by the user defined ones
+func (c Config) GetLayouts() []layout.Layout {
+	layouts := layout.Presets()
+
+	for _, l := range c.Layouts {
+		replaced := false
+		for i := range layouts {
+			if layouts[i].Name == l.Name {
+				layouts[i], replaced = l, true
+			}
+		}
+		if !replaced {
+			layouts = append(layouts, l)
+		}
+	}
+
+	return layouts
+}
+
+// GetCacheDir returns the candle cache directory
+func (c Config) GetCacheDir() string {
+	if c.CacheDir != "" {
+		return c.CacheDir
+	}
+
+	return candlestore.DefaultDir()
+}
+
+// GetProfiles returns the configured profiles, or a single profile reading credentials
+// from the environment when none are configured
+func (c Config) GetProfiles() []profile.Profile {
+	if len(c.Profiles) == 0 {
+		return []profile.Profile{{Name: profile.DefaultName, Source: profile.SourceEnv}}
+	}
+
+	return c.Profiles
+}
+
+// GetSecretsFile returns the location of the encrypted secrets file
+func (c Config) GetSecretsFile() string {
+	if c.SecretsFile != "" {
+		return c.SecretsFile
+	}
+
+	return profile.DefaultSecretsPath()
+}
+
+// GetAuditLog returns the location of the order audit log
+func (c Config) GetAuditLog() string {
+	if c.AuditLog != "" {
+		return c.AuditLog
+	}
+
+	return guard.DefaultAuditPath()
+}
+
+// GetStatusline returns the statusline options with defaults for missing values
+func (c Config) GetStatusline() Statusline {
+	s := c.Statusline
+	if len(s.Pairs) == 0 {
+		s.Pairs = []string{"btcusd"}
+	}
+	if s.Format == "" {
+		s.Format = statusline.DefaultFormat
+	}
+	if s.Socket == "" {
+		s.Socket = statusline.DefaultSocket()
+	}
+
+	return s
+}
+
+// GetComparePairs returns the names of the pairs compared on start
+func (c Config) GetComparePairs() []string {
+	if len(c.Compare.Pairs) == 0 {
+		return []string{"btcusd", "ethusd"}
+	}
+
+	return c.Compare.Pairs
+}
+
+// GetWatchlist returns the names of the pairs of the watchlist panel
+func (c Config) GetWatchlist() []string {
+	if len(c.Watchlist) == 0 {
+		return []string{"btcusd", "ethusd", "xrpusd", "ltcusd"}
+	}
+
+	return c.Watchlist
+}
+
+// GetTriggersFile returns the location of the order triggers file
+func (c Config) GetTriggersFile() string {
+	if c.TriggersFile != "" {
+		return c.TriggersFile
+	}
+
+	return trigger.DefaultPath()
+}
+
+// GetStrategiesFile returns the location of the strategy rules file
+func (c Config) GetStrategiesFile() string {
+	if c.Strategies.File != "" {
+		return c.Strategies.File
+	}
+
+	return strategy.DefaultPath()
+}
@@ -0,0 +1,622 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/guard"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+	"github.com/georlav/bitstamp-cli/internal/private"
+)
+
+const (
+	// sliceSlippage distance of TWAP and POV slice limit prices from the touch, slices
+	// are immediate or cancel so this only bounds the price paid
+	sliceSlippage = 0.005
+	// povInterval time between POV slices
+	povInterval = time.Second * 5
+	// icebergInterval time between checks of the visible iceberg order
+	icebergInterval = time.Second * 3
+	// statusDelay time to wait before reading the fills of a sent order
+	statusDelay = time.Second
+	// maxFailures consecutive failed orders after which a job fails
+	maxFailures = 3
+	// minSlice smallest POV slice as a share of the job amount
+	minSlice = 0.01
+	// amountDecimals decimals of order amounts
+	amountDecimals = 8
+)
+
+// Market provides current prices, implemented by bitstamp.HTTPAPI
+type Market interface {
+	GetTicker(ctx context.Context, p bitstamp.Pair) (*bitstamp.GetTickerResponse, error)
+}
+
+// Account sends the orders of a profile and reads their fills, implemented by profile.Account
+type Account interface {
+	guard.Trader
+	OrderStatus(ctx context.Context, id string) (*private.OrderStatus, error)
+}
+
+// Engine runs execution jobs, each job runs in its own goroutine. It is safe for concurrent use
+type Engine struct {
+	account  func(ctx context.Context, profile string) (Account, error)
+	market   Market
+	decimals func(p bitstamp.Pair) int
+	log      *logging.Logger
+	notify   func(jobs []Job)
+	jobs     []Job
+	cancel   map[string]context.CancelFunc
+	seq      int
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// order an order sent by a job and the amount of it already recorded as filled
+type order struct {
+	id       string
+	pair     bitstamp.Pair
+	amount   float64
+	price    float64
+	recorded float64
+}
+
+// New creates an engine, account returns the account the orders of a job of a profile
+// are sent with, decimals returns the price decimals of a pair and notify is called
+// with every job after each change
+func New(account func(ctx context.Context, profile string) (Account, error), market Market, decimals func(p bitstamp.Pair) int, log *logging.Logger, notify func(jobs []Job)) *Engine {
+	return &Engine{
+		account:  account,
+		market:   market,
+		decimals: decimals,
+		log:      log,
+		notify:   notify,
+		cancel:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Start records the arrival price and starts a job, its orders are sent with the
+// account of its profile until it ends
+func (e *Engine) Start(ctx context.Context, p Params) (Job, error) {
+	if err := p.Validate(); err != nil {
+		return Job{}, err
+	}
+
+	acc, err := e.account(ctx, p.Profile)
+	if err != nil {
+		return Job{}, err
+	}
+
+	bid, ask, err := e.quote(ctx, p.Pair)
+	if err != nil {
+		return Job{}, err
+	}
+
+	e.mu.Lock()
+	e.seq++
+	j := Job{
+		ID:      "j" + strconv.Itoa(e.seq),
+		Params:  p,
+		Status:  StatusRunning,
+		Arrival: (bid + ask) / 2,
+		Started: time.Now(),
+	}
+	e.jobs = append(e.jobs, j)
+
+	// jobs outlive the command that started them, they end with ctx or when cancelled
+	jobCtx, cancel := context.WithCancel(ctx)
+	e.cancel[j.ID] = cancel
+	e.changed()
+	e.mu.Unlock()
+
+	e.log.Info("job started", "id", j.ID, "profile", p.Profile, "kind", p.Kind, "pair", p.Pair, "sell", p.Sell,
+		"amount", p.Amount, "arrival", j.Arrival)
+
+	e.wg.Add(1)
+	go e.run(jobCtx, j.ID, acc)
+
+	return j, nil
+}
+
+// Pause stops a job from sending orders, the visible order of an iceberg is cancelled
+func (e *Engine) Pause(id string) error {
+	return e.setStatus(id, StatusRunning, StatusPaused)
+}
+
+// Resume continues a paused job
+func (e *Engine) Resume(id string) error {
+	return e.setStatus(id, StatusPaused, StatusRunning)
+}
+
+// Cancel stops a job, its open order is cancelled
+func (e *Engine) Cancel(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	j := e.find(id)
+	if j == nil {
+		return fmt.Errorf("unknown job %s", id)
+	}
+	if !j.Active() {
+		return fmt.Errorf("job %s is %s", id, j.Status)
+	}
+
+	j.Status = StatusCancelled
+	e.cancel[id]()
+	e.changed()
+
+	return nil
+}
+
+// Trade records market volume of a pair used by POV jobs
+func (e *Engine) Trade(p bitstamp.Pair, amount float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.jobs {
+		if j := &e.jobs[i]; j.Kind == KindPOV && j.Pair == p && j.Active() {
+			j.Volume += amount
+		}
+	}
+}
+
+// Pairs returns the pairs of active POV jobs, they require the trades of their pair
+func (e *Engine) Pairs() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seen := make(map[bitstamp.Pair]bool)
+	var pairs []string
+	for _, j := range e.jobs {
+		if j.Kind == KindPOV && j.Active() && !seen[j.Pair] {
+			seen[j.Pair] = true
+			pairs = append(pairs, j.Pair.String())
+		}
+	}
+
+	return pairs
+}
+
+// Close cancels running jobs and waits for them to cancel their open orders
+func (e *Engine) Close() {
+	e.mu.Lock()
+	for _, cancel := range e.cancel {
+		cancel()
+	}
+	e.mu.Unlock()
+
+	e.wg.Wait()
+}
+
+// run runs a job sending its orders with acc
+func (e *Engine) run(ctx context.Context, id string, acc Account) {
+	defer e.wg.Done()
+
+	var err error
+	switch e.get(id).Kind {
+	case KindTWAP:
+		err = e.twap(ctx, id, acc)
+	case KindIceberg:
+		err = e.iceberg(ctx, id, acc)
+	case KindPOV:
+		err = e.pov(ctx, id, acc)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	j := e.find(id)
+	switch {
+	case j.Status == StatusCancelled:
+	case err != nil && ctx.Err() == nil:
+		j.Status, j.Error = StatusFailed, err.Error()
+	case err != nil:
+		j.Status = StatusCancelled
+	default:
+		j.Status = StatusDone
+	}
+	e.cancel[id]()
+	delete(e.cancel, id)
+	e.changed()
+
+	e.log.Info("job finished", "id", id, "status", j.Status, "filled", j.Filled, "avg_price", j.AvgPrice(),
+		"slippage_bps", j.Slippage(), "error", j.Error)
+}
+
+// twap sends an immediate or cancel slice every Duration/Slices, each slice is the
+// remaining amount split over the remaining slices so partial fills are caught up
+func (e *Engine) twap(ctx context.Context, id string, acc Account) error {
+	j := e.get(id)
+	interval := j.Duration / time.Duration(j.Slices)
+	failures := 0
+
+	for i := 0; i < j.Slices; i++ {
+		if i > 0 {
+			if err := sleep(ctx, interval); err != nil {
+				return err
+			}
+		}
+		if err := e.waitRunning(ctx, id); err != nil {
+			return err
+		}
+
+		j := e.get(id)
+		amount := j.remaining() / float64(j.Slices-i)
+		if amount == 0 {
+			return nil
+		}
+
+		if err := e.slice(ctx, acc, id, amount); err != nil {
+			if failures++; errors.Is(err, guard.ErrRejected) || failures >= maxFailures {
+				return err
+			}
+			e.log.Warn("job slice failed", "id", id, "error", err)
+			continue
+		}
+		failures = 0
+	}
+
+	return nil
+}
+
+// pov sends immediate or cancel slices keeping the filled amount at Rate of the market volume
+func (e *Engine) pov(ctx context.Context, id string, acc Account) error {
+	failures := 0
+
+	for {
+		if err := sleep(ctx, povInterval); err != nil {
+			return err
+		}
+		if err := e.waitRunning(ctx, id); err != nil {
+			return err
+		}
+
+		j := e.get(id)
+		remaining := j.remaining()
+		if remaining == 0 {
+			return nil
+		}
+
+		amount := math.Min(j.Rate*j.Volume-j.Filled, remaining)
+		if amount < j.Amount*minSlice && amount < remaining {
+			continue
+		}
+
+		if err := e.slice(ctx, acc, id, amount); err != nil {
+			if failures++; errors.Is(err, guard.ErrRejected) || failures >= maxFailures {
+				return err
+			}
+			e.log.Warn("job slice failed", "id", id, "error", err)
+			continue
+		}
+		failures = 0
+	}
+}
+
+// iceberg keeps a limit order of the visible amount at the best price of its side,
+// the order is replaced when it fills or the price moves away from it
+func (e *Engine) iceberg(ctx context.Context, id string, acc Account) error {
+	var (
+		o        order
+		failures int
+	)
+
+	// the visible order is cancelled when the job ends
+	defer func() {
+		if o.id == "" {
+			return
+		}
+		cctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := e.withdraw(cctx, acc, id, &o); err != nil {
+			e.log.Error("failed to cancel iceberg order", "id", id, "order_id", o.id, "error", err)
+		}
+	}()
+
+	for {
+		if o.id != "" || failures > 0 {
+			if err := sleep(ctx, icebergInterval); err != nil {
+				return err
+			}
+		}
+
+		j := e.get(id)
+		if j.Status == StatusPaused {
+			if o.id != "" {
+				if err := e.withdraw(ctx, acc, id, &o); err != nil {
+					return err
+				}
+			}
+			if err := e.waitRunning(ctx, id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if o.id != "" {
+			finished, err := e.fill(ctx, acc, id, &o)
+			if err != nil {
+				return err
+			}
+			if !finished {
+				bid, ask, err := e.quote(ctx, j.Pair)
+				if err != nil {
+					return err
+				}
+				if (j.Sell && o.price <= ask) || (!j.Sell && o.price >= bid) {
+					continue
+				}
+				// the price moved away from the visible order
+				if err := e.withdraw(ctx, acc, id, &o); err != nil {
+					return err
+				}
+			}
+			o = order{}
+		}
+
+		remaining := e.get(id).remaining()
+		if remaining == 0 {
+			return nil
+		}
+
+		bid, ask, err := e.quote(ctx, j.Pair)
+		if err == nil {
+			price := bid
+			if j.Sell {
+				price = ask
+			}
+			o, err = e.send(ctx, acc, j, math.Min(j.Visible, remaining), price, false)
+		}
+		if err != nil {
+			if failures++; errors.Is(err, guard.ErrRejected) || failures >= maxFailures {
+				return err
+			}
+			e.log.Warn("iceberg order failed", "id", id, "error", err)
+			continue
+		}
+		failures = 0
+	}
+}
+
+// slice sends an immediate or cancel limit order priced sliceSlippage through the touch and records its fills
+func (e *Engine) slice(ctx context.Context, acc Account, id string, amount float64) error {
+	j := e.get(id)
+
+	bid, ask, err := e.quote(ctx, j.Pair)
+	if err != nil {
+		return err
+	}
+	price := ask * (1 + sliceSlippage)
+	if j.Sell {
+		price = bid * (1 - sliceSlippage)
+	}
+
+	o, err := e.send(ctx, acc, j, amount, price, true)
+	if err != nil {
+		return err
+	}
+
+	if err := sleep(ctx, statusDelay); err != nil {
+		return err
+	}
+	_, err = e.fill(ctx, acc, id, &o)
+
+	return err
+}
+
+// send sends a limit order of a job with acc
+func (e *Engine) send(ctx context.Context, acc Account, j Job, amount, price float64, ioc bool) (order, error) {
+	o := order{
+		pair:   j.Pair,
+		amount: roundDown(amount, amountDecimals),
+		price:  round(price, e.decimals(j.Pair)),
+	}
+	if o.amount <= 0 {
+		return o, errors.New("order amount is too small")
+	}
+
+	a := strconv.FormatFloat(o.amount, 'f', -1, 64)
+	p := strconv.FormatFloat(o.price, 'f', -1, 64)
+
+	var (
+		resp *bitstamp.CreateOrderResponse
+		err  error
+	)
+	if j.Sell {
+		resp, err = acc.CreateSellLimitOrder(ctx, j.Pair, bitstamp.CreateSellLimitOrderRequest{Amount: a, Price: p, IOCOrder: ioc})
+	} else {
+		resp, err = acc.CreateBuyLimitOrder(ctx, j.Pair, bitstamp.CreateBuyLimitOrderRequest{Amount: a, Price: p, IOCOrder: ioc})
+	}
+	if err != nil {
+		return o, err
+	}
+	o.id = resp.ID
+	e.log.Info("job order sent", "id", j.ID, "order_id", o.id, "amount", a, "price", p, "ioc", ioc)
+
+	return o, nil
+}
+
+// withdraw cancels an open order and records its final fills
+func (e *Engine) withdraw(ctx context.Context, acc Account, id string, o *order) error {
+	// the order may have filled since it was last checked, its fills are read regardless
+	if _, err := acc.CancelOrder(ctx, bitstamp.CancelOrderRequest{ID: o.id}); err != nil {
+		e.log.Warn("failed to cancel job order", "id", id, "order_id", o.id, "error", err)
+	}
+
+	if _, err := e.fill(ctx, acc, id, o); err != nil {
+		return err
+	}
+	*o = order{}
+
+	return nil
+}
+
+// fill records fills of an order not recorded yet and reports whether the order is finished
+func (e *Engine) fill(ctx context.Context, acc Account, id string, o *order) (bool, error) {
+	status, err := acc.OrderStatus(ctx, o.id)
+	if err != nil {
+		return false, fmt.Errorf("failed to read order %s status, %w", o.id, err)
+	}
+
+	remaining, err := strconv.ParseFloat(status.AmountRemaining, 64)
+	if err != nil {
+		remaining = o.amount
+	}
+	filled := o.amount - remaining
+	price := fillPrice(status, o.pair, o.price)
+
+	if delta := filled - o.recorded; delta > 0 {
+		o.recorded = filled
+
+		e.mu.Lock()
+		if j := e.find(id); j != nil {
+			j.Filled += delta
+			j.Cost += delta * price
+		}
+		e.changed()
+		e.mu.Unlock()
+	}
+
+	return status.Status == "Finished" || status.Status == "Canceled" || remaining <= 0, nil
+}
+
+// fillPrice returns the average price of the transactions of an order of a pair weighted
+// by their base amounts, or the order price when it has none
+func fillPrice(s *private.OrderStatus, p bitstamp.Pair, price float64) float64 {
+	var amount, cost float64
+	for _, t := range s.Transactions {
+		a, tp := math.Abs(t.Float(baseCurrency(t, p.String()))), t.Float("price")
+		if a > 0 && tp > 0 {
+			amount += a
+			cost += a * tp
+		}
+	}
+
+	if amount > 0 {
+		return cost / amount
+	}
+
+	return price
+}
+
+// baseCurrency returns the key of the base amount of a transaction of a pair, amounts
+// are named after the currencies of the pair so the base is the key the pair name starts
+// with whose remainder is the quote key, like eth and usd of ethusd
+func baseCurrency(t private.Transaction, pair string) string {
+	for k := range t {
+		if k != pair && strings.HasPrefix(pair, k) {
+			if _, ok := t[strings.TrimPrefix(pair, k)]; ok {
+				return k
+			}
+		}
+	}
+
+	return ""
+}
+
+func (e *Engine) quote(ctx context.Context, p bitstamp.Pair) (float64, float64, error) {
+	t, err := e.market.GetTicker(ctx, p)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to retrieve %s ticker, %w", p, err)
+	}
+
+	bid, _ := strconv.ParseFloat(t.Bid, 64)
+	ask, _ := strconv.ParseFloat(t.Ask, 64)
+	if bid <= 0 || ask <= 0 {
+		return 0, 0, fmt.Errorf("%s has no bid or ask", p)
+	}
+
+	return bid, ask, nil
+}
+
+// waitRunning blocks while a job is paused
+func (e *Engine) waitRunning(ctx context.Context, id string) error {
+	for e.get(id).Status == StatusPaused {
+		if err := sleep(ctx, time.Second); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) setStatus(id string, from, to Status) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	j := e.find(id)
+	if j == nil {
+		return fmt.Errorf("unknown job %s", id)
+	}
+	if j.Status != from {
+		return fmt.Errorf("job %s is %s", id, j.Status)
+	}
+	j.Status = to
+	e.changed()
+
+	return nil
+}
+
+func (e *Engine) get(id string) Job {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if j := e.find(id); j != nil {
+		return *j
+	}
+
+	return Job{}
+}
+
+// find returns a job, must be called holding the lock
+func (e *Engine) find(id string) *Job {
+	for i := range e.jobs {
+		if e.jobs[i].ID == id {
+			return &e.jobs[i]
+		}
+	}
+
+	return nil
+}
+
+// changed notifies jobs, most recent first, must be called holding the lock
+func (e *Engine) changed() {
+	if e.notify == nil {
+		return
+	}
+
+	jobs := make([]Job, 0, len(e.jobs))
+	for i := len(e.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, e.jobs[i])
+	}
+	e.notify(jobs)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow10(decimals)
+	return math.Round(v*p) / p
+}
+
+func roundDown(v float64, decimals int) float64 {
+	p := math.Pow10(decimals)
+	return math.Floor(v*p) / p
+}
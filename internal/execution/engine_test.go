@@ -0,0 +1,109 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+	"github.com/georlav/bitstamp-cli/internal/private"
+)
+
+// fakeAccount fills every order it receives at its limit price
+type fakeAccount struct {
+	orders []string
+	mu     sync.Mutex
+}
+
+func (f *fakeAccount) order(amount string) (*bitstamp.CreateOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.orders = append(f.orders, amount)
+
+	return &bitstamp.CreateOrderResponse{ID: "1"}, nil
+}
+
+func (f *fakeAccount) CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.order(r.Amount)
+}
+
+func (f *fakeAccount) CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.order(r.Amount)
+}
+
+func (f *fakeAccount) CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.order(r.Amount)
+}
+
+func (f *fakeAccount) CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.order(r.Amount)
+}
+
+func (f *fakeAccount) CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error) {
+	return &bitstamp.CancelOrderResponse{}, nil
+}
+
+func (f *fakeAccount) OrderStatus(ctx context.Context, id string) (*private.OrderStatus, error) {
+	return &private.OrderStatus{Status: "Finished", AmountRemaining: "0"}, nil
+}
+
+func (f *fakeAccount) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.orders...)
+}
+
+// fakeMarket quotes every pair at a bid of 99 and an ask of 101
+type fakeMarket struct{}
+
+func (fakeMarket) GetTicker(ctx context.Context, p bitstamp.Pair) (*bitstamp.GetTickerResponse, error) {
+	return &bitstamp.GetTickerResponse{Bid: "99", Ask: "101", Last: "100"}, nil
+}
+
+func TestJobsSendWithTheirProfile(t *testing.T) {
+	accounts := map[string]*fakeAccount{"main": {}, "savings": {}}
+	account := func(ctx context.Context, profile string) (Account, error) {
+		acc, ok := accounts[profile]
+		if !ok {
+			return nil, errors.New("unknown profile " + profile)
+		}
+		return acc, nil
+	}
+	decimals := func(bitstamp.Pair) int { return 2 }
+	e := New(account, fakeMarket{}, decimals, logging.New(io.Discard, logging.LevelError, logging.FormatLogfmt), nil)
+	defer e.Close()
+
+	params := Params{Kind: KindTWAP, Pair: bitstamp.BTCUSD, Amount: 1, Slices: 1, Duration: time.Second}
+	if _, err := e.Start(context.Background(), params); err == nil {
+		t.Error("started a job without a profile")
+	}
+	params.Profile = "old"
+	if _, err := e.Start(context.Background(), params); err == nil {
+		t.Error("started a job of an unknown profile")
+	}
+
+	params.Profile = "savings"
+	j, err := e.Start(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(time.Second * 5); e.get(j.ID).Active() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if got := e.get(j.ID); got.Status != StatusDone || got.Filled != 1 {
+		t.Errorf("job is %s with %v filled, want done and 1", got.Status, got.Filled)
+	}
+	if got := accounts["savings"].sent(); len(got) != 1 || got[0] != "1" {
+		t.Errorf("savings account sent %v, want one order of 1", got)
+	}
+	if got := accounts["main"].sent(); len(got) != 0 {
+		t.Errorf("main account sent %v, want none", got)
+	}
+}
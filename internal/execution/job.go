@@ -0,0 +1,135 @@
+package execution
+
+import (
+	"errors"
+	"time"
+
+	"github.com/georlav/bitstamp"
+)
+
+// Kind execution algorithm of a job
+type Kind string
+
+const (
+	// KindTWAP splits the amount into equal slices sent over a duration
+	KindTWAP Kind = "twap"
+	// KindIceberg keeps a visible limit order at the top of the book until the amount is filled
+	KindIceberg Kind = "iceberg"
+	// KindPOV trades a share of the volume traded by the market
+	KindPOV Kind = "pov"
+)
+
+// Status lifecycle of a job
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusPaused    Status = "paused"
+	StatusDone      Status = "done"
+	StatusCancelled Status = "cancelled"
+	StatusFailed    Status = "failed"
+)
+
+// Params parameters of a job, Amount is in base currency. Orders are sent using the
+// account of Profile regardless of the active profile
+type Params struct {
+	Kind    Kind
+	Profile string
+	Pair    bitstamp.Pair
+	Sell    bool
+	Amount  float64
+	// Slices and Duration of a TWAP job
+	Slices   int
+	Duration time.Duration
+	// Visible amount of the limit order of an iceberg job
+	Visible float64
+	// Rate share of market volume of a POV job, from 0 to 1
+	Rate float64
+}
+
+// Validate checks the parameters of the job kind
+func (p Params) Validate() error {
+	if p.Profile == "" {
+		return errors.New("job profile is required")
+	}
+	if p.Amount <= 0 {
+		return errors.New("job amount must be greater than zero")
+	}
+
+	switch p.Kind {
+	case KindTWAP:
+		if p.Slices <= 0 || p.Duration <= 0 {
+			return errors.New("twap slices and duration must be greater than zero")
+		}
+	case KindIceberg:
+		if p.Visible <= 0 || p.Visible > p.Amount {
+			return errors.New("iceberg visible amount must be greater than zero and at most the amount")
+		}
+	case KindPOV:
+		if p.Rate <= 0 || p.Rate > 1 {
+			return errors.New("pov rate must be between 0 and 100%")
+		}
+	default:
+		return errors.New("unknown job kind " + string(p.Kind))
+	}
+
+	return nil
+}
+
+// Job progress of an execution algorithm
+type Job struct {
+	ID string
+	Params
+	Status Status
+	// Filled amount and Cost in quote currency of the fills
+	Filled float64
+	Cost   float64
+	// Arrival mid price when the job started
+	Arrival float64
+	// Volume market volume traded since the job started, used by POV jobs
+	Volume  float64
+	Started time.Time
+	Error   string
+}
+
+// Progress returns the filled share of the amount from 0 to 1
+func (j Job) Progress() float64 {
+	return j.Filled / j.Amount
+}
+
+// AvgPrice returns the average fill price, zero before the first fill
+func (j Job) AvgPrice() float64 {
+	if j.Filled == 0 {
+		return 0
+	}
+
+	return j.Cost / j.Filled
+}
+
+// Slippage returns how much worse the average fill price is than the arrival price in basis points
+func (j Job) Slippage() float64 {
+	avg := j.AvgPrice()
+	if avg == 0 || j.Arrival == 0 {
+		return 0
+	}
+
+	if j.Sell {
+		return (j.Arrival - avg) / j.Arrival * 10000
+	}
+
+	return (avg - j.Arrival) / j.Arrival * 10000
+}
+
+// Active reports whether the job has not finished
+func (j Job) Active() bool {
+	return j.Status == StatusRunning || j.Status == StatusPaused
+}
+
+// remaining returns the amount left to fill
+func (j Job) remaining() float64 {
+	if r := j.Amount - j.Filled; r > j.Amount*1e-9 {
+		return r
+	}
+
+	return 0
+}
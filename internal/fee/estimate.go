@@ -0,0 +1,111 @@
+package fee
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/georlav/bitstamp-cli/internal/orderbook"
+)
+
+// Estimate expected outcome of a market order filled against the resting levels of a book
+type Estimate struct {
+	Sell bool
+	// Amount requested and Filled amount available in the book, in base currency
+	Amount float64
+	Filled float64
+	// AvgPrice average fill price, Cost its value in quote currency before fees
+	AvgPrice float64
+	Cost     float64
+	// Mid price of the book and Slippage of the average price from it in basis points, worse is positive
+	Mid      float64
+	Slippage float64
+	// Rate fee rate and Fee its amount in quote currency
+	Rate float64
+	Fee  float64
+	// Levels number of price levels the order walks through
+	Levels int
+}
+
+// Total returns the quote currency spent by a buy or received by a sell, fee included
+func (e Estimate) Total() float64 {
+	if e.Sell {
+		return e.Cost - e.Fee
+	}
+
+	return e.Cost + e.Fee
+}
+
+// String summarises the estimate in a single line
+func (e Estimate) String() string {
+	side := "buy"
+	if e.Sell {
+		side = "sell"
+	}
+
+	s := fmt.Sprintf("%s %s avg %.8g slippage %.1f bps over %d levels, fee %.2f%% %.8g, total %.8g",
+		side, formatAmount(e.Filled), e.AvgPrice, e.Slippage, e.Levels, e.Rate*100, e.Fee, e.Total())
+	if e.Partial() {
+		s += fmt.Sprintf(", only %s of %s available in the book", formatAmount(e.Filled), formatAmount(e.Amount))
+	}
+
+	return s
+}
+
+// Partial reports whether the book lacks the depth to fill the whole amount
+func (e Estimate) Partial() bool {
+	return e.Filled < e.Amount
+}
+
+// EstimateOrder walks the asks of a book for a buy or its bids for a sell, levels sorted
+// from the top of the book, and applies the fee rate to the filled value
+func EstimateOrder(bids, asks []orderbook.Level, sell bool, amount, rate float64) (Estimate, error) {
+	if amount <= 0 {
+		return Estimate{}, errors.New("amount must be greater than zero")
+	}
+	if len(bids) == 0 || len(asks) == 0 {
+		return Estimate{}, errors.New("order book is empty")
+	}
+
+	e := Estimate{
+		Sell:   sell,
+		Amount: amount,
+		Mid:    (bids[0].Price + asks[0].Price) / 2,
+		Rate:   rate,
+	}
+
+	levels := asks
+	if sell {
+		levels = bids
+	}
+	for _, l := range levels {
+		if e.Filled >= amount {
+			break
+		}
+
+		fill := l.Amount
+		if remaining := amount - e.Filled; fill > remaining {
+			fill = remaining
+		}
+		e.Filled += fill
+		e.Cost += fill * l.Price
+		e.Levels++
+	}
+
+	if e.Filled > 0 {
+		e.AvgPrice = e.Cost / e.Filled
+		e.Slippage = (e.AvgPrice - e.Mid) / e.Mid * 10000
+		if sell {
+			e.Slippage = -e.Slippage
+		}
+	}
+	e.Fee = e.Cost * rate
+
+	return e, nil
+}
+
+// formatAmount formats an amount with up to 8 decimals
+func formatAmount(a float64) string {
+	return strconv.FormatFloat(math.Round(a*1e8)/1e8, 'f', -1, 64)
+}
@@ -0,0 +1,59 @@
+package fee
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/georlav/bitstamp"
+)
+
+// DefaultTaker taker fee used for pairs whose account fee is unknown or without
+// credentials, the public default tier of the bitstamp schedule
+const DefaultTaker = 0.005
+
+// Parse returns the trading fees of the pairs listed in an account balance response
+// as fractions, bitstamp reports them in percent as <pair>_fee fields
+func Parse(resp *bitstamp.GetAccountBalancesResponse) (map[bitstamp.Pair]float64, error) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse account fees, %w", err)
+	}
+
+	pairs := make(map[string]bitstamp.Pair)
+	for _, p := range bitstamp.GetAllPairs() {
+		pairs[p.String()] = p
+	}
+
+	fees := make(map[bitstamp.Pair]float64)
+	for k, v := range fields {
+		if !strings.HasSuffix(k, "_fee") || strings.HasSuffix(k, "_withdrawal_fee") {
+			continue
+		}
+
+		p, ok := pairs[strings.TrimSuffix(k, "_fee")]
+		if !ok {
+			continue
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			fees[p] = f / 100
+		}
+	}
+
+	return fees, nil
+}
+
+// Taker returns the fee of a pair from fees, DefaultTaker when unknown
+func Taker(fees map[bitstamp.Pair]float64, p bitstamp.Pair) float64 {
+	if f, ok := fees[p]; ok {
+		return f
+	}
+
+	return DefaultTaker
+}
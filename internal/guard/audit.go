@@ -0,0 +1,114 @@
+package guard
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/logging"
+)
+
+// Entry an attempted order or cancellation
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Pair   string    `json:"pair,omitempty"`
+	Amount float64   `json:"amount,omitempty"`
+	Price  float64   `json:"price,omitempty"`
+	// Reasons rules violated by a rejected order
+	Reasons []string `json:"reasons,omitempty"`
+	// Sent is true when the order passed the guardrails and was sent to bitstamp
+	Sent    bool   `json:"sent"`
+	OrderID string `json:"order_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Audit appends entries to a json lines file
+type Audit struct {
+	path string
+	f    *os.File
+	log  *logging.Logger
+	mu   sync.Mutex
+}
+
+// DefaultAuditPath returns the location of the audit log under the user config directory
+func DefaultAuditPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "bitstamp-cli-audit.log"
+	}
+
+	return filepath.Join(dir, "bitstamp-cli", "audit.log")
+}
+
+// OpenAudit opens the audit log at path for appending, entries are also logged to log
+func OpenAudit(path string, log *logging.Logger) (*Audit, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory, %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log, %w", err)
+	}
+
+	return &Audit{path: path, f: f, log: log}, nil
+}
+
+// Record appends an entry
+func (a *Audit) Record(e Entry) {
+	a.log.Info("order audit", "action", e.Action, "pair", e.Pair, "amount", e.Amount, "price", e.Price,
+		"sent", e.Sent, "reasons", e.Reasons, "order_id", e.OrderID, "error", e.Error)
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		a.log.Error("failed to encode audit entry", "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.f.Write(append(b, '\n')); err != nil {
+		a.log.Error("failed to write audit log", "error", err)
+	}
+}
+
+// SentSince returns the number of orders sent successfully since t
+func (a *Audit) SentSince(t time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			a.log.Error("failed to read audit log", "error", err)
+		}
+		return 0
+	}
+	defer f.Close()
+
+	sent := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.Sent && e.Error == "" && e.Action != "cancel" && !e.Time.Before(t) {
+			sent++
+		}
+	}
+
+	return sent
+}
+
+// Close closes the audit log
+func (a *Audit) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,292 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+)
+
+// ErrRejected is wrapped by errors of orders rejected by a guardrail
+var ErrRejected = errors.New("order rejected")
+
+// Trader creates and cancels orders, implemented by bitstamp.HTTPAPI
+type Trader interface {
+	CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error)
+	CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error)
+	CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error)
+	CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error)
+	CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error)
+}
+
+// Rules guardrails applied to orders
+type Rules struct {
+	// ReadOnly rejects every order and cancellation
+	ReadOnly bool `json:"read_only"`
+	// MaxNotional maximum order value in quote currency by pair, e.g. {"btcusd": 1000}
+	MaxNotional map[string]float64 `json:"max_notional"`
+	// MaxDeviation maximum distance of a limit price from the opposite side of the book
+	// as a fraction, 0.05 rejects buys more than 5% above the ask. Zero disables it
+	MaxDeviation float64 `json:"max_deviation"`
+	// DailyOrders maximum number of orders sent per UTC day, zero disables it
+	DailyOrders int `json:"daily_orders"`
+}
+
+// Quote market prices used to check orders of a pair
+type Quote struct {
+	Last float64
+	Bid  float64
+	Ask  float64
+}
+
+// Guard checks orders against rules before passing them to the next trader, every
+// attempt is recorded in the audit log. It is safe for concurrent use
+type Guard struct {
+	next   Trader
+	rules  Rules
+	quotes func(ctx context.Context, p bitstamp.Pair) (Quote, bool)
+	audit  *Audit
+	day    time.Time
+	sent   int
+	mu     sync.Mutex
+}
+
+// order an order being checked
+type order struct {
+	kind   string
+	pair   bitstamp.Pair
+	amount float64
+	price  float64
+	// counter amount is in quote currency
+	counter bool
+	sell    bool
+}
+
+// New creates a guard, quotes returns the current prices of a pair. The number of
+// orders sent today is restored from the audit log
+func New(next Trader, rules Rules, quotes func(ctx context.Context, p bitstamp.Pair) (Quote, bool), audit *Audit) *Guard {
+	g := Guard{
+		next:   next,
+		rules:  rules,
+		quotes: quotes,
+		audit:  audit,
+		day:    today(time.Now()),
+	}
+	g.sent = audit.SentSince(g.day)
+
+	return &g
+}
+
+// ReadOnly reports whether every order is rejected
+func (g *Guard) ReadOnly() bool {
+	return g.rules.ReadOnly
+}
+
+// CreateBuyLimitOrder implements the Trader interface
+func (g *Guard) CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return gated{guard: g, next: g.next}.CreateBuyLimitOrder(ctx, p, r)
+}
+
+// CreateBuyInstantOrder implements the Trader interface
+func (g *Guard) CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return gated{guard: g, next: g.next}.CreateBuyInstantOrder(ctx, p, r)
+}
+
+// CreateSellLimitOrder implements the Trader interface
+func (g *Guard) CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return gated{guard: g, next: g.next}.CreateSellLimitOrder(ctx, p, r)
+}
+
+// CreateSellInstantOrder implements the Trader interface
+func (g *Guard) CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return gated{guard: g, next: g.next}.CreateSellInstantOrder(ctx, p, r)
+}
+
+// CancelOrder implements the Trader interface, cancellations are only rejected in read only mode
+func (g *Guard) CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error) {
+	return gated{guard: g, next: g.next}.CancelOrder(ctx, r)
+}
+
+// Through returns a trader checking orders against the rules of the guard before sending
+// them to next instead of the trader of the guard, such as the account of one profile.
+// The daily order count and the audit log are shared with the guard
+func (g *Guard) Through(next Trader) Trader {
+	return gated{guard: g, next: next}
+}
+
+// gated checks orders with a guard and sends them to a trader
+type gated struct {
+	guard *Guard
+	next  Trader
+}
+
+// CreateBuyLimitOrder implements the Trader interface
+func (t gated) CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	o := order{kind: "buy limit", pair: p, amount: parse(r.Amount), price: parse(r.Price)}
+
+	return t.guard.create(ctx, o, func() (*bitstamp.CreateOrderResponse, error) {
+		return t.next.CreateBuyLimitOrder(ctx, p, r)
+	})
+}
+
+// CreateBuyInstantOrder implements the Trader interface
+func (t gated) CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	o := order{kind: "buy instant", pair: p, amount: parse(r.Amount), counter: true}
+
+	return t.guard.create(ctx, o, func() (*bitstamp.CreateOrderResponse, error) {
+		return t.next.CreateBuyInstantOrder(ctx, p, r)
+	})
+}
+
+// CreateSellLimitOrder implements the Trader interface
+func (t gated) CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	o := order{kind: "sell limit", pair: p, amount: parse(r.Amount), price: parse(r.Price), sell: true}
+
+	return t.guard.create(ctx, o, func() (*bitstamp.CreateOrderResponse, error) {
+		return t.next.CreateSellLimitOrder(ctx, p, r)
+	})
+}
+
+// CreateSellInstantOrder implements the Trader interface
+func (t gated) CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	o := order{kind: "sell instant", pair: p, amount: parse(r.Amount), counter: r.AmountInCounter, sell: true}
+
+	return t.guard.create(ctx, o, func() (*bitstamp.CreateOrderResponse, error) {
+		return t.next.CreateSellInstantOrder(ctx, p, r)
+	})
+}
+
+// CancelOrder implements the Trader interface
+func (t gated) CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error) {
+	entry := Entry{Time: time.Now(), Action: "cancel", OrderID: r.ID}
+
+	if t.guard.rules.ReadOnly {
+		entry.Reasons = []string{"read only mode"}
+		t.guard.audit.Record(entry)
+
+		return nil, fmt.Errorf("%w, read only mode", ErrRejected)
+	}
+
+	resp, err := t.next.CancelOrder(ctx, r)
+	entry.Sent = true
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	t.guard.audit.Record(entry)
+
+	return resp, err
+}
+
+// create checks an order and sends it when no rule is violated
+func (g *Guard) create(ctx context.Context, o order, send func() (*bitstamp.CreateOrderResponse, error)) (*bitstamp.CreateOrderResponse, error) {
+	entry := Entry{
+		Time:   time.Now(),
+		Action: o.kind,
+		Pair:   o.pair.String(),
+		Amount: o.amount,
+		Price:  o.price,
+	}
+
+	// prices may be requested, they are read before locking
+	q, ok := g.quotes(ctx, o.pair)
+
+	g.mu.Lock()
+	if d := today(entry.Time); !d.Equal(g.day) {
+		g.day, g.sent = d, 0
+	}
+	entry.Reasons = g.check(o, q, ok)
+	if len(entry.Reasons) == 0 {
+		// counted before sending so concurrent orders can not exceed the cap, failed sends are uncounted
+		g.sent++
+	}
+	g.mu.Unlock()
+
+	if len(entry.Reasons) > 0 {
+		g.audit.Record(entry)
+		return nil, fmt.Errorf("%w, %s", ErrRejected, strings.Join(entry.Reasons, ", "))
+	}
+
+	resp, err := send()
+	entry.Sent = true
+	if err != nil {
+		entry.Error = err.Error()
+
+		g.mu.Lock()
+		if today(entry.Time).Equal(g.day) {
+			g.sent--
+		}
+		g.mu.Unlock()
+	} else if resp != nil {
+		entry.OrderID = resp.ID
+	}
+	g.audit.Record(entry)
+
+	return resp, err
+}
+
+// check returns the rules an order violates, q are the prices of its pair when ok
+func (g *Guard) check(o order, q Quote, ok bool) []string {
+	var reasons []string
+
+	if g.rules.ReadOnly {
+		return []string{"read only mode"}
+	}
+
+	if g.rules.DailyOrders > 0 && g.sent >= g.rules.DailyOrders {
+		reasons = append(reasons, fmt.Sprintf("daily order cap of %d reached", g.rules.DailyOrders))
+	}
+
+	if o.amount <= 0 {
+		reasons = append(reasons, "amount must be greater than zero")
+	}
+
+	if max, limited := g.rules.MaxNotional[o.pair.String()]; limited {
+		switch notional, known := o.notional(q, ok); {
+		case !known:
+			reasons = append(reasons, "no price to compute order value")
+		case notional > max:
+			reasons = append(reasons, fmt.Sprintf("value %.2f exceeds maximum of %.2f", notional, max))
+		}
+	}
+
+	if g.rules.MaxDeviation > 0 && o.price > 0 {
+		switch {
+		case !ok || q.Bid <= 0 || q.Ask <= 0:
+			reasons = append(reasons, "no book prices to check limit price")
+		case !o.sell && o.price > q.Ask*(1+g.rules.MaxDeviation):
+			reasons = append(reasons, fmt.Sprintf("buy price is more than %.2f%% above the ask", g.rules.MaxDeviation*100))
+		case o.sell && o.price < q.Bid*(1-g.rules.MaxDeviation):
+			reasons = append(reasons, fmt.Sprintf("sell price is more than %.2f%% below the bid", g.rules.MaxDeviation*100))
+		}
+	}
+
+	return reasons
+}
+
+// notional returns the value of an order in quote currency, market orders are valued at the last price
+func (o order) notional(q Quote, ok bool) (float64, bool) {
+	switch {
+	case o.counter:
+		return o.amount, true
+	case o.price > 0:
+		return o.amount * o.price, true
+	case ok && q.Last > 0:
+		return o.amount * q.Last, true
+	}
+
+	return 0, false
+}
+
+func parse(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func today(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour * 24)
+}
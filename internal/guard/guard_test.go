@@ -0,0 +1,373 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+)
+
+// fakeTrader records the orders it receives and fails them when err is set
+type fakeTrader struct {
+	err  error
+	sent []string
+	mu   sync.Mutex
+}
+
+func (f *fakeTrader) send(kind string) (*bitstamp.CreateOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, kind)
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &bitstamp.CreateOrderResponse{ID: "1"}, nil
+}
+
+func (f *fakeTrader) CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.send("buy limit")
+}
+
+func (f *fakeTrader) CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.send("buy instant")
+}
+
+func (f *fakeTrader) CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.send("sell limit")
+}
+
+func (f *fakeTrader) CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return f.send("sell instant")
+}
+
+func (f *fakeTrader) CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, "cancel")
+
+	return &bitstamp.CancelOrderResponse{}, f.err
+}
+
+func testAudit(t *testing.T) *Audit {
+	t.Helper()
+
+	a, err := OpenAudit(filepath.Join(t.TempDir(), "audit.log"), logging.New(io.Discard, logging.LevelError, logging.FormatLogfmt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	return a
+}
+
+// fixedQuotes returns the same prices for every pair
+func fixedQuotes(q Quote, ok bool) func(ctx context.Context, p bitstamp.Pair) (Quote, bool) {
+	return func(ctx context.Context, p bitstamp.Pair) (Quote, bool) {
+		return q, ok
+	}
+}
+
+func TestGuardChecks(t *testing.T) {
+	quote := Quote{Last: 100, Bid: 99, Ask: 101}
+	rules := Rules{MaxNotional: map[string]float64{"ethusd": 1000}, MaxDeviation: 0.05}
+
+	tests := []struct {
+		name   string
+		rules  Rules
+		quoted bool
+		send   func(g *Guard) error
+		reason string
+	}{
+		{
+			name:   "limit within limits",
+			rules:  rules,
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateBuyLimitOrder(context.Background(), bitstamp.ETHUSD, bitstamp.CreateBuyLimitOrderRequest{Amount: "9", Price: "102"})
+				return err
+			},
+		},
+		{
+			name:   "read only",
+			rules:  Rules{ReadOnly: true},
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateBuyLimitOrder(context.Background(), bitstamp.ETHUSD, bitstamp.CreateBuyLimitOrderRequest{Amount: "1", Price: "100"})
+				return err
+			},
+			reason: "read only mode",
+		},
+		{
+			name:   "read only cancel",
+			rules:  Rules{ReadOnly: true},
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CancelOrder(context.Background(), bitstamp.CancelOrderRequest{ID: "1"})
+				return err
+			},
+			reason: "read only mode",
+		},
+		{
+			name:   "zero amount",
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateSellInstantOrder(context.Background(), bitstamp.ETHUSD, bitstamp.CreateSellInstantOrderRequest{Amount: "0"})
+				return err
+			},
+			reason: "amount must be greater than zero",
+		},
+		{
+			name:   "limit value above maximum",
+			rules:  rules,
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateSellLimitOrder(context.Background(), bitstamp.ETHUSD, bitstamp.CreateSellLimitOrderRequest{Amount: "11", Price: "100"})
+				return err
+			},
+			reason: "value 1100.00 exceeds maximum of 1000.00",
+		},
+		{
+			name:   "market order valued at the last price",
+			rules:  rules,
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateSellInstantOrder(context.Background(), bitstamp.ETHUSD, bitstamp.CreateSellInstantOrderRequest{Amount: "10.5"})
+				return err
+			},
+			reason: "value 1050.00 exceeds maximum of 1000.00",
+		},
+		{
+			name:   "counter amount is the value",
+			rules:  rules,
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateBuyInstantOrder(context.Background(), bitstamp.ETHUSD, bitstamp.CreateBuyInstantOrderRequest{Amount: "1001"})
+				return err
+			},
+			reason: "value 1001.00 exceeds maximum of 1000.00",
+		},
+		{
+			name:  "market order without a price",
+			rules: rules,
+			send: func(g *Guard) error {
+				_, err := g.CreateSellInstantOrder(context.Background(), bitstamp.ETHUSD, bitstamp.CreateSellInstantOrderRequest{Amount: "1"})
+				return err
+			},
+			reason: "no price to compute order value",
+		},
+		{
+			name:   "pair without a maximum",
+			rules:  rules,
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateSellInstantOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateSellInstantOrderRequest{Amount: "1000"})
+				return err
+			},
+		},
+		{
+			name:   "buy far above the ask",
+			rules:  rules,
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateBuyLimitOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateBuyLimitOrderRequest{Amount: "1", Price: "107"})
+				return err
+			},
+			reason: "buy price is more than 5.00% above the ask",
+		},
+		{
+			name:   "sell far below the bid",
+			rules:  rules,
+			quoted: true,
+			send: func(g *Guard) error {
+				_, err := g.CreateSellLimitOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateSellLimitOrderRequest{Amount: "1", Price: "93"})
+				return err
+			},
+			reason: "sell price is more than 5.00% below the bid",
+		},
+		{
+			name:  "limit price without book prices",
+			rules: rules,
+			send: func(g *Guard) error {
+				_, err := g.CreateSellLimitOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateSellLimitOrderRequest{Amount: "1", Price: "100"})
+				return err
+			},
+			reason: "no book prices to check limit price",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trader := &fakeTrader{}
+			g := New(trader, tt.rules, fixedQuotes(quote, tt.quoted), testAudit(t))
+
+			err := tt.send(g)
+			switch {
+			case tt.reason == "" && err != nil:
+				t.Fatalf("unexpected error %s", err)
+			case tt.reason == "" && len(trader.sent) != 1:
+				t.Errorf("order was not sent")
+			case tt.reason != "" && !errors.Is(err, ErrRejected):
+				t.Fatalf("got error %v, want a rejection", err)
+			case tt.reason != "" && !strings.Contains(err.Error(), tt.reason):
+				t.Errorf("got error %s, want %s", err, tt.reason)
+			case tt.reason != "" && len(trader.sent) != 0:
+				t.Errorf("rejected order was sent")
+			}
+		})
+	}
+}
+
+func TestGuardDailyOrders(t *testing.T) {
+	trader := &fakeTrader{err: errors.New("insufficient balance")}
+	audit := testAudit(t)
+	g := New(trader, Rules{DailyOrders: 2}, fixedQuotes(Quote{}, false), audit)
+
+	buy := func() error {
+		_, err := g.CreateBuyInstantOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateBuyInstantOrderRequest{Amount: "10"})
+		return err
+	}
+
+	// failed sends do not count
+	for i := 0; i < 3; i++ {
+		if err := buy(); err == nil || errors.Is(err, ErrRejected) {
+			t.Fatalf("got error %v, want the send error", err)
+		}
+	}
+
+	trader.err = nil
+	for i := 0; i < 2; i++ {
+		if err := buy(); err != nil {
+			t.Fatalf("order %d failed, %s", i, err)
+		}
+	}
+	if err := buy(); !errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), "daily order cap of 2 reached") {
+		t.Errorf("got error %v, want the daily cap rejection", err)
+	}
+
+	// the count is restored from the audit log
+	if sent := audit.SentSince(today(time.Now())); sent != 2 {
+		t.Errorf("audit log counts %d sent orders, want 2", sent)
+	}
+	restored := New(trader, Rules{DailyOrders: 2}, fixedQuotes(Quote{}, false), audit)
+	if _, err := restored.CreateBuyInstantOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateBuyInstantOrderRequest{Amount: "10"}); !errors.Is(err, ErrRejected) {
+		t.Errorf("got error %v, want the daily cap rejection after restoring", err)
+	}
+}
+
+func TestGuardConcurrentDailyOrders(t *testing.T) {
+	trader := &fakeTrader{}
+	g := New(trader, Rules{DailyOrders: 5}, fixedQuotes(Quote{}, false), testAudit(t))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.CreateBuyInstantOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateBuyInstantOrderRequest{Amount: "10"})
+		}()
+	}
+	wg.Wait()
+
+	if len(trader.sent) != 5 {
+		t.Errorf("sent %d orders, want 5", len(trader.sent))
+	}
+}
+
+// fakeTicker returns a ticker of fixed prices and counts requests
+type fakeTicker struct {
+	resp     bitstamp.GetTickerResponse
+	err      error
+	requests int
+	mu       sync.Mutex
+}
+
+func (f *fakeTicker) GetTicker(ctx context.Context, p bitstamp.Pair) (*bitstamp.GetTickerResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := f.resp
+
+	return &resp, nil
+}
+
+func TestQuotes(t *testing.T) {
+	ticker := &fakeTicker{resp: bitstamp.GetTickerResponse{Last: "50", Bid: "49", Ask: "51"}}
+	q := NewQuotes(ticker, time.Minute)
+	ctx := context.Background()
+
+	// a pair without stream prices is read from the ticker, once within maxAge
+	for i := 0; i < 2; i++ {
+		if got, ok := q.Quote(ctx, bitstamp.ETHUSD); !ok || got != (Quote{Last: 50, Bid: 49, Ask: 51}) {
+			t.Errorf("got %+v %v, want the ticker prices", got, ok)
+		}
+	}
+	if ticker.requests != 1 {
+		t.Errorf("got %d ticker requests, want 1", ticker.requests)
+	}
+
+	// fresh stream prices are used without requesting the ticker
+	q.Trade(bitstamp.BTCUSD, 100, time.Now())
+	q.Book(bitstamp.BTCUSD, 99, 101, time.Now())
+	if got, ok := q.Quote(ctx, bitstamp.BTCUSD); !ok || got != (Quote{Last: 100, Bid: 99, Ask: 101}) {
+		t.Errorf("got %+v %v, want the stream prices", got, ok)
+	}
+	if ticker.requests != 1 {
+		t.Errorf("got %d ticker requests, want 1", ticker.requests)
+	}
+
+	// a stale side is refreshed from the ticker, the fresh one is kept
+	q.Trade(bitstamp.XRPUSD, 1, time.Now())
+	q.Book(bitstamp.XRPUSD, 0.9, 1.1, time.Now().Add(-time.Hour))
+	if got, ok := q.Quote(ctx, bitstamp.XRPUSD); !ok || got != (Quote{Last: 1, Bid: 49, Ask: 51}) {
+		t.Errorf("got %+v %v, want the stream trade and the ticker book", got, ok)
+	}
+
+	// stale prices are used when the ticker fails, up to a bound
+	ticker.err = errors.New("timeout")
+	q.Trade(bitstamp.LTCUSD, 70, time.Now().Add(-time.Minute*2))
+	if got, ok := q.Quote(ctx, bitstamp.LTCUSD); !ok || got.Last != 70 {
+		t.Errorf("got %+v %v, want the stale trade price", got, ok)
+	}
+	q.Trade(bitstamp.LTCEUR, 60, time.Now().Add(-time.Hour))
+	q.Book(bitstamp.LTCEUR, 59, 61, time.Now().Add(-time.Hour))
+	if got, ok := q.Quote(ctx, bitstamp.LTCEUR); ok {
+		t.Errorf("got %+v, want prices older than the bound rejected", got)
+	}
+	if _, ok := q.Quote(ctx, bitstamp.BCHUSD); ok {
+		t.Error("got prices of a pair never seen with a failing ticker")
+	}
+}
+
+func TestGuardThrough(t *testing.T) {
+	active, pinned := &fakeTrader{}, &fakeTrader{}
+	g := New(active, Rules{DailyOrders: 2}, fixedQuotes(Quote{}, false), testAudit(t))
+	through := g.Through(pinned)
+
+	for i := 0; i < 2; i++ {
+		if _, err := through.CreateBuyInstantOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateBuyInstantOrderRequest{Amount: "10"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pinned.sent) != 2 || len(active.sent) != 0 {
+		t.Errorf("sent %d orders through and %d to the guard trader, want 2 and 0", len(pinned.sent), len(active.sent))
+	}
+
+	// the daily order count is shared
+	if _, err := g.CreateBuyInstantOrder(context.Background(), bitstamp.BTCUSD, bitstamp.CreateBuyInstantOrderRequest{Amount: "10"}); !errors.Is(err, ErrRejected) {
+		t.Errorf("got error %v, want the daily cap rejection", err)
+	}
+}
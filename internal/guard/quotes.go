@@ -0,0 +1,146 @@
+package guard
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+)
+
+// staleFactor multiple of maxAge up to which prices are used when the ticker fails,
+// orders are rejected for lack of a reference price after that
+const staleFactor = 5
+
+// Ticker retrieves the ticker of a pair, implemented by bitstamp.HTTPAPI
+type Ticker interface {
+	GetTicker(ctx context.Context, p bitstamp.Pair) (*bitstamp.GetTickerResponse, error)
+}
+
+// quote prices of a pair and the time each side was last updated
+type quote struct {
+	Quote
+	traded  time.Time
+	booked  time.Time
+	fetched time.Time
+}
+
+// Quotes keeps the last trade price and top of book of every pair seen on the stream,
+// prices older than maxAge are refreshed from the ticker of the pair when an order needs
+// them. It is safe for concurrent use
+type Quotes struct {
+	ticker Ticker
+	maxAge time.Duration
+	quotes map[bitstamp.Pair]*quote
+	mu     sync.Mutex
+}
+
+// NewQuotes creates quotes refreshing prices older than maxAge from ticker
+func NewQuotes(ticker Ticker, maxAge time.Duration) *Quotes {
+	return &Quotes{
+		ticker: ticker,
+		maxAge: maxAge,
+		quotes: make(map[bitstamp.Pair]*quote),
+	}
+}
+
+// Trade records the price of a trade of a pair
+func (q *Quotes) Trade(p bitstamp.Pair, price float64, t time.Time) {
+	if price <= 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.get(p)
+	e.Last, e.traded = price, t
+}
+
+// Book records the best bid and ask of a pair
+func (q *Quotes) Book(p bitstamp.Pair, bid, ask float64, t time.Time) {
+	if bid <= 0 || ask <= 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.get(p)
+	e.Bid, e.Ask, e.booked = bid, ask, t
+}
+
+// Quote returns the prices of a pair, the ticker is requested when the last trade or
+// the top of book is older than maxAge. When the ticker fails prices up to staleFactor
+// times maxAge old are used
+func (q *Quotes) Quote(ctx context.Context, p bitstamp.Pair) (Quote, bool) {
+	now := time.Now()
+
+	q.mu.Lock()
+	e := *q.get(p)
+	q.mu.Unlock()
+
+	if q.fresh(e, now) {
+		return e.Quote, true
+	}
+
+	resp, err := q.ticker.GetTicker(ctx, p)
+	if err != nil {
+		return q.recent(e, now)
+	}
+
+	last, _ := strconv.ParseFloat(resp.Last, 64)
+	bid, _ := strconv.ParseFloat(resp.Bid, 64)
+	ask, _ := strconv.ParseFloat(resp.Ask, 64)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// prices received from the stream while the ticker was requested are more recent
+	f := q.get(p)
+	if !f.traded.After(now.Add(-q.maxAge)) && last > 0 {
+		f.Last = last
+	}
+	if !f.booked.After(now.Add(-q.maxAge)) && bid > 0 && ask > 0 {
+		f.Bid, f.Ask = bid, ask
+	}
+	f.fetched = now
+
+	return f.Quote, f.Last > 0 || (f.Bid > 0 && f.Ask > 0)
+}
+
+// fresh reports whether the prices of a quote can be used without requesting the ticker,
+// a ticker requested within maxAge is used as is
+func (q *Quotes) fresh(e quote, now time.Time) bool {
+	if now.Sub(e.fetched) <= q.maxAge {
+		return true
+	}
+
+	return e.Last > 0 && e.Bid > 0 && e.Ask > 0 && now.Sub(e.traded) <= q.maxAge && now.Sub(e.booked) <= q.maxAge
+}
+
+// recent returns the prices of a quote updated within staleFactor times maxAge, by the
+// stream or the ticker
+func (q *Quotes) recent(e quote, now time.Time) (Quote, bool) {
+	bound := q.maxAge * staleFactor
+	if now.Sub(e.traded) > bound && now.Sub(e.fetched) > bound {
+		e.Last = 0
+	}
+	if now.Sub(e.booked) > bound && now.Sub(e.fetched) > bound {
+		e.Bid, e.Ask = 0, 0
+	}
+
+	return e.Quote, e.Last > 0 || (e.Bid > 0 && e.Ask > 0)
+}
+
+// get returns the quote of a pair, must be called holding the lock
+func (q *Quotes) get(p bitstamp.Pair) *quote {
+	e, ok := q.quotes[p]
+	if !ok {
+		e = &quote{}
+		q.quotes[p] = e
+	}
+
+	return e
+}
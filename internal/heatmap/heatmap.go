@@ -0,0 +1,156 @@
+package heatmap
+
+import (
+	"math"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/orderbook"
+)
+
+const (
+	// History how long snapshots and prints are kept
+	History = time.Minute * 10
+	// SampleInterval minimum time between snapshots
+	SampleInterval = time.Second
+)
+
+// Snapshot resting volume of both sides of the order book at a point in time
+type Snapshot struct {
+	Time   time.Time
+	Mid    float64
+	Levels []orderbook.Level
+}
+
+// Print a trade drawn over the heatmap
+type Print struct {
+	Time   time.Time
+	Price  float64
+	Amount float64
+	Sell   bool
+}
+
+// Cell resting volume of a price range during a snapshot and the volume traded in it
+// until the next snapshot, Sell is set when most of the traded volume was sold
+type Cell struct {
+	Volume float64
+	Traded float64
+	Sell   bool
+}
+
+// Grid snapshots binned into price rows, Cells[row][col] with row 0 holding the highest
+// prices and the last column the most recent snapshot
+type Grid struct {
+	Cells [][]Cell
+	// Top price of the upper edge of the first row, Step price range of a row
+	Top       float64
+	Step      float64
+	MaxVolume float64
+	MaxTraded float64
+}
+
+// AddSnapshot appends a snapshot when the last one is older than SampleInterval and
+// drops snapshots older than History. The given slice is never modified
+func AddSnapshot(snapshots []Snapshot, s Snapshot) []Snapshot {
+	if n := len(snapshots); n > 0 && s.Time.Sub(snapshots[n-1].Time) < SampleInterval {
+		return snapshots
+	}
+
+	result := make([]Snapshot, 0, len(snapshots)+1)
+	for i := range snapshots {
+		if s.Time.Sub(snapshots[i].Time) <= History {
+			result = append(result, snapshots[i])
+		}
+	}
+
+	return append(result, s)
+}
+
+// AddPrint appends a print and drops prints older than History. The given slice is never modified
+func AddPrint(prints []Print, p Print) []Print {
+	result := make([]Print, 0, len(prints)+1)
+	for i := range prints {
+		if p.Time.Sub(prints[i].Time) <= History {
+			result = append(result, prints[i])
+		}
+	}
+
+	return append(result, p)
+}
+
+// Build bins the most recent cols snapshots into rows price rows centred on the mid
+// price of the latest snapshot, span is the distance from mid to the edges as a fraction of mid
+func Build(snapshots []Snapshot, prints []Print, rows, cols int, span float64) Grid {
+	if len(snapshots) == 0 || rows <= 0 || cols <= 0 {
+		return Grid{}
+	}
+	if len(snapshots) > cols {
+		snapshots = snapshots[len(snapshots)-cols:]
+	}
+
+	mid := snapshots[len(snapshots)-1].Mid
+	g := Grid{
+		Cells: make([][]Cell, rows),
+		Top:   mid * (1 + span),
+		Step:  mid * span * 2 / float64(rows),
+	}
+	if g.Step <= 0 {
+		return Grid{}
+	}
+	for r := range g.Cells {
+		g.Cells[r] = make([]Cell, len(snapshots))
+	}
+
+	for c, s := range snapshots {
+		for _, l := range s.Levels {
+			if r, ok := g.row(l.Price); ok {
+				g.Cells[r][c].Volume += l.Amount
+				g.MaxVolume = math.Max(g.MaxVolume, g.Cells[r][c].Volume)
+			}
+		}
+	}
+
+	// net sold volume of each cell decides the side of its print
+	sold := make(map[[2]int]float64)
+	for _, p := range prints {
+		c := column(snapshots, p.Time)
+		r, ok := g.row(p.Price)
+		if c < 0 || !ok {
+			continue
+		}
+
+		cell := &g.Cells[r][c]
+		cell.Traded += p.Amount
+		if p.Sell {
+			sold[[2]int{r, c}] += p.Amount
+		} else {
+			sold[[2]int{r, c}] -= p.Amount
+		}
+		cell.Sell = sold[[2]int{r, c}] > 0
+		g.MaxTraded = math.Max(g.MaxTraded, cell.Traded)
+	}
+
+	return g
+}
+
+// Price returns the price at the centre of a row
+func (g Grid) Price(row int) float64 {
+	return g.Top - (float64(row)+0.5)*g.Step
+}
+
+// row returns the row of a price
+func (g Grid) row(price float64) (int, bool) {
+	r := int(math.Floor((g.Top - price) / g.Step))
+
+	return r, r >= 0 && r < len(g.Cells)
+}
+
+// column returns the snapshot a point in time belongs to, -1 when it precedes all of them
+func column(snapshots []Snapshot, t time.Time) int {
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !t.Before(snapshots[i].Time) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,266 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Available panels
+const (
+	PanelCurrencies = "currencies"
+	PanelPairs      = "pairs"
+	PanelChart      = "chart"
+	PanelBook       = "book"
+	PanelTrades     = "trades"
+	PanelDepth      = "depth"
+	PanelAnalytics  = "analytics"
+	PanelErrors     = "errors"
+	PanelTriggers   = "triggers"
+	PanelJobs       = "jobs"
+	PanelHeatmap    = "heatmap"
+	PanelArbitrage  = "arbitrage"
+	PanelTransfers  = "transfers"
+	PanelCompare    = "compare"
+	PanelOverview   = "overview"
+	PanelStrategies = "strategies"
+	PanelWatchlist  = "watchlist"
+	PanelAccount    = "account"
+	PanelAlerts     = "alerts"
+)
+
+// Panels lists all panel names that can be placed in a layout
+var Panels = []string{
+	PanelCurrencies,
+	PanelPairs,
+	PanelChart,
+	PanelBook,
+	PanelTrades,
+	PanelDepth,
+	PanelAnalytics,
+	PanelErrors,
+	PanelTriggers,
+	PanelJobs,
+	PanelHeatmap,
+	PanelArbitrage,
+	PanelTransfers,
+	PanelCompare,
+	PanelOverview,
+	PanelStrategies,
+	PanelWatchlist,
+	PanelAccount,
+	PanelAlerts,
+}
+
+// Node is either a panel or a split of its space into rows or columns. Ratio is
+// the share of the parent space, ratios of siblings should add up to 1
+type Node struct {
+	Ratio float64 `json:"ratio,omitempty"`
+	Panel string  `json:"panel,omitempty"`
+	Rows  []Node  `json:"rows,omitempty"`
+	Cols  []Node  `json:"cols,omitempty"`
+}
+
+// Layout a named arrangement of panels
+type Layout struct {
+	Name string `json:"name"`
+	Node
+}
+
+// Presets returns the built in layouts
+func Presets() []Layout {
+	return []Layout{
+		{Name: "trader", Node: Node{Cols: []Node{
+			{Ratio: 0.3, Rows: []Node{
+				{Ratio: 0.4, Cols: []Node{
+					{Ratio: 0.5, Panel: PanelCurrencies},
+					{Ratio: 0.5, Panel: PanelPairs},
+				}},
+				{Ratio: 0.25, Panel: PanelWatchlist},
+				{Ratio: 0.35, Panel: PanelTrades},
+			}},
+			{Ratio: 0.7, Rows: []Node{
+				{Ratio: 0.5, Panel: PanelChart},
+				{Ratio: 0.5, Panel: PanelBook},
+			}},
+		}}},
+		{Name: "monitor", Node: Node{Cols: []Node{
+			{Ratio: 0.2, Rows: []Node{
+				{Ratio: 0.25, Panel: PanelCurrencies},
+				{Ratio: 0.45, Panel: PanelPairs},
+				{Ratio: 0.3, Panel: PanelAlerts},
+			}},
+			{Ratio: 0.8, Rows: []Node{
+				{Ratio: 0.6, Panel: PanelChart},
+				{Ratio: 0.4, Cols: []Node{
+					{Ratio: 0.35, Panel: PanelDepth},
+					{Ratio: 0.35, Panel: PanelTrades},
+					{Ratio: 0.3, Panel: PanelAnalytics},
+				}},
+			}},
+		}}},
+		{Name: "orders", Node: Node{Cols: []Node{
+			{Ratio: 0.2, Panel: PanelPairs},
+			{Ratio: 0.45, Rows: []Node{
+				{Ratio: 0.5, Panel: PanelChart},
+				{Ratio: 0.5, Panel: PanelBook},
+			}},
+			{Ratio: 0.35, Rows: []Node{
+				{Ratio: 0.4, Panel: PanelTrades},
+				{Ratio: 0.3, Panel: PanelTriggers},
+				{Ratio: 0.3, Panel: PanelJobs},
+			}},
+		}}},
+		{Name: "liquidity", Node: Node{Cols: []Node{
+			{Ratio: 0.15, Panel: PanelPairs},
+			{Ratio: 0.6, Panel: PanelHeatmap},
+			{Ratio: 0.25, Rows: []Node{
+				{Ratio: 0.5, Panel: PanelBook},
+				{Ratio: 0.5, Panel: PanelTrades},
+			}},
+		}}},
+		{Name: "arbitrage", Node: Node{Cols: []Node{
+			{Ratio: 0.2, Panel: PanelPairs},
+			{Ratio: 0.5, Panel: PanelArbitrage},
+			{Ratio: 0.3, Rows: []Node{
+				{Ratio: 0.5, Panel: PanelBook},
+				{Ratio: 0.5, Panel: PanelTrades},
+			}},
+		}}},
+		{Name: "overview", Node: Node{Cols: []Node{
+			{Ratio: 0.15, Panel: PanelCurrencies},
+			{Ratio: 0.55, Panel: PanelOverview},
+			{Ratio: 0.3, Rows: []Node{
+				{Ratio: 0.5, Panel: PanelBook},
+				{Ratio: 0.5, Panel: PanelTrades},
+			}},
+		}}},
+		{Name: "compare", Node: Node{Cols: []Node{
+			{Ratio: 0.2, Panel: PanelPairs},
+			{Ratio: 0.8, Rows: []Node{
+				{Ratio: 0.7, Panel: PanelCompare},
+				{Ratio: 0.3, Panel: PanelChart},
+			}},
+		}}},
+		{Name: "strategies", Node: Node{Cols: []Node{
+			{Ratio: 0.2, Panel: PanelPairs},
+			{Ratio: 0.8, Rows: []Node{
+				{Ratio: 0.45, Panel: PanelStrategies},
+				{Ratio: 0.55, Cols: []Node{
+					{Ratio: 0.6, Panel: PanelChart},
+					{Ratio: 0.4, Panel: PanelTrades},
+				}},
+			}},
+		}}},
+		{Name: "account", Node: Node{Cols: []Node{
+			{Ratio: 0.2, Panel: PanelPairs},
+			{Ratio: 0.5, Rows: []Node{
+				{Ratio: 0.4, Panel: PanelAccount},
+				{Ratio: 0.6, Panel: PanelTransfers},
+			}},
+			{Ratio: 0.3, Rows: []Node{
+				{Ratio: 0.35, Panel: PanelTriggers},
+				{Ratio: 0.35, Panel: PanelJobs},
+				{Ratio: 0.3, Panel: PanelAlerts},
+			}},
+		}}},
+		{Name: "compact", Node: Node{Cols: []Node{
+			{Ratio: 0.3, Rows: []Node{
+				{Ratio: 0.6, Panel: PanelPairs},
+				{Ratio: 0.4, Panel: PanelWatchlist},
+			}},
+			{Ratio: 0.7, Rows: []Node{
+				{Ratio: 0.55, Panel: PanelChart},
+				{Ratio: 0.45, Panel: PanelBook},
+			}},
+		}}},
+	}
+}
+
+// Validate checks that every node is either a panel or a split, that sibling
+// ratios are valid and that panels are known and used once
+func (l Layout) Validate() error {
+	if l.Name == "" {
+		return errors.New("layout name is required")
+	}
+
+	seen := make(map[string]bool)
+	if err := l.Node.validate(seen); err != nil {
+		return fmt.Errorf("layout %s, %w", l.Name, err)
+	}
+
+	return nil
+}
+
+func (n Node) validate(seen map[string]bool) error {
+	set := 0
+	for _, ok := range []bool{n.Panel != "", len(n.Rows) > 0, len(n.Cols) > 0} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("a node must define exactly one of panel, rows or cols")
+	}
+
+	if n.Panel != "" {
+		if !isPanel(n.Panel) {
+			return fmt.Errorf("unknown panel %s", n.Panel)
+		}
+		if seen[n.Panel] {
+			return fmt.Errorf("panel %s is used more than once", n.Panel)
+		}
+		seen[n.Panel] = true
+
+		return nil
+	}
+
+	children := n.Rows
+	if len(n.Cols) > 0 {
+		children = n.Cols
+	}
+
+	sum := 0.0
+	for i := range children {
+		if children[i].Ratio <= 0 {
+			return errors.New("ratio must be greater than zero")
+		}
+		sum += children[i].Ratio
+
+		if err := children[i].validate(seen); err != nil {
+			return err
+		}
+	}
+	if math.Abs(sum-1) > 0.001 {
+		return fmt.Errorf("ratios must add up to 1, got %.3f", sum)
+	}
+
+	return nil
+}
+
+// Panels returns the panels of a layout in the order they are defined
+func (n Node) Panels() []string {
+	if n.Panel != "" {
+		return []string{n.Panel}
+	}
+
+	var panels []string
+	for _, c := range n.Rows {
+		panels = append(panels, c.Panels()...)
+	}
+	for _, c := range n.Cols {
+		panels = append(panels, c.Panels()...)
+	}
+
+	return panels
+}
+
+func isPanel(name string) bool {
+	for i := range Panels {
+		if Panels[i] == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresetsAreValid(t *testing.T) {
+	names := make(map[string]bool)
+	for _, l := range Presets() {
+		if err := l.Validate(); err != nil {
+			t.Errorf("preset %s is invalid, %s", l.Name, err)
+		}
+		if names[l.Name] {
+			t.Errorf("preset name %s is used more than once", l.Name)
+		}
+		names[l.Name] = true
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout Layout
+		err    string
+	}{
+		{
+			name:   "single panel",
+			layout: Layout{Name: "a", Node: Node{Panel: PanelChart}},
+		},
+		{
+			name: "nested splits",
+			layout: Layout{Name: "a", Node: Node{Cols: []Node{
+				{Ratio: 0.3, Panel: PanelPairs},
+				{Ratio: 0.7, Rows: []Node{{Ratio: 0.5, Panel: PanelChart}, {Ratio: 0.5, Panel: PanelBook}}},
+			}}},
+		},
+		{
+			name:   "missing name",
+			layout: Layout{Node: Node{Panel: PanelChart}},
+			err:    "name is required",
+		},
+		{
+			name:   "empty node",
+			layout: Layout{Name: "a"},
+			err:    "exactly one of",
+		},
+		{
+			name:   "panel and split",
+			layout: Layout{Name: "a", Node: Node{Panel: PanelChart, Rows: []Node{{Ratio: 1, Panel: PanelBook}}}},
+			err:    "exactly one of",
+		},
+		{
+			name:   "unknown panel",
+			layout: Layout{Name: "a", Node: Node{Panel: "ticker"}},
+			err:    "unknown panel ticker",
+		},
+		{
+			name: "panel used twice",
+			layout: Layout{Name: "a", Node: Node{Rows: []Node{
+				{Ratio: 0.5, Panel: PanelChart},
+				{Ratio: 0.5, Cols: []Node{{Ratio: 0.5, Panel: PanelBook}, {Ratio: 0.5, Panel: PanelChart}}},
+			}}},
+			err: "panel chart is used more than once",
+		},
+		{
+			name:   "zero ratio",
+			layout: Layout{Name: "a", Node: Node{Rows: []Node{{Panel: PanelChart}, {Ratio: 1, Panel: PanelBook}}}},
+			err:    "greater than zero",
+		},
+		{
+			name:   "ratios do not add up",
+			layout: Layout{Name: "a", Node: Node{Cols: []Node{{Ratio: 0.5, Panel: PanelChart}, {Ratio: 0.4, Panel: PanelBook}}}},
+			err:    "add up to 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.layout.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error %s", err)
+			case tt.err != "" && err == nil:
+				t.Errorf("expected error %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Errorf("got error %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPanels(t *testing.T) {
+	n := Node{Cols: []Node{
+		{Ratio: 0.3, Rows: []Node{{Ratio: 0.5, Panel: PanelCurrencies}, {Ratio: 0.5, Panel: PanelPairs}}},
+		{Ratio: 0.7, Panel: PanelChart},
+	}}
+
+	if got, want := strings.Join(n.Panels(), ","), "currencies,pairs,chart"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
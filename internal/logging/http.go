@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const redacted = "[redacted]"
+
+// secrets names of fields and headers holding credentials, compared case insensitively
+var secrets = []string{"x-auth", "x-auth-signature", "signature", "key", "secret", "passphrase", "password"}
+
+// Redact returns value, or a placeholder when key names a secret
+func Redact(key string, value interface{}) interface{} {
+	for _, s := range secrets {
+		if strings.EqualFold(key, s) {
+			return redacted
+		}
+	}
+
+	return value
+}
+
+// Transport logs requests sent through the next round tripper, request headers are
+// logged at debug level with credentials redacted
+type Transport struct {
+	Logger *Logger
+	Next   http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if t.Logger.Enabled(LevelDebug) {
+		t.Logger.Debug("http request", "method", r.Method, "url", r.URL.Redacted(), "headers", headers(r.Header))
+	}
+
+	start := time.Now()
+	resp, err := next.RoundTrip(r)
+	if err != nil {
+		t.Logger.Warn("http request failed", "method", r.Method, "url", r.URL.Redacted(), "duration", time.Since(start), "error", err)
+		return nil, err
+	}
+
+	t.Logger.Info("http response", "method", r.Method, "url", r.URL.Redacted(), "status", resp.StatusCode, "duration", time.Since(start))
+
+	return resp, nil
+}
+
+func headers(h http.Header) map[string]string {
+	result := make(map[string]string, len(h))
+	for k := range h {
+		result[k] = Redact(k, h.Get(k)).(string)
+	}
+
+	return result
+}
@@ -0,0 +1,231 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tailSize number of recent entries kept in memory
+const tailSize = 500
+
+// Level severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ParseLevel returns the level named s
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelError; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level %s", s)
+}
+
+// Format encoding of log entries
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatLogfmt Format = "logfmt"
+)
+
+// ParseFormat returns the format named s
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSON, FormatLogfmt:
+		return f, nil
+	}
+
+	return FormatLogfmt, fmt.Errorf("unknown log format %s", s)
+}
+
+// Logger writes structured entries at or above its level, the most recent entries are
+// also kept in memory. A nil logger discards entries. It is safe for concurrent use
+type Logger struct {
+	w      io.Writer
+	level  Level
+	format Format
+	tail   []string
+	next   int
+	mu     sync.Mutex
+}
+
+// New creates a logger writing to w, w may be nil to only keep entries in memory
+func New(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{
+		w:      w,
+		level:  level,
+		format: format,
+		tail:   make([]string, 0, tailSize),
+	}
+}
+
+// Debug logs a message with key value pairs at debug level
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+// Info logs a message with key value pairs at info level
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+// Warn logs a message with key value pairs at warn level
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
+
+// Error logs a message with key value pairs at error level
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+// Enabled reports whether entries of level are logged
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.level
+}
+
+// Tail returns up to n of the most recent entries, oldest first
+func (l *Logger) Tail(n int) []string {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n > len(l.tail) {
+		n = len(l.tail)
+	}
+	lines := make([]string, 0, n)
+	for i := len(l.tail) - n; i < len(l.tail); i++ {
+		lines = append(lines, l.tail[(l.next+i)%len(l.tail)])
+	}
+
+	return lines
+}
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	fields := make([]field, 0, len(kv)/2+3)
+	fields = append(fields,
+		field{"time", time.Now().UTC().Format(time.RFC3339Nano)},
+		field{"level", level.String()},
+		field{"msg", msg},
+	)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		fields = append(fields, field{key, Redact(key, value(kv[i+1]))})
+	}
+
+	var line string
+	if l.format == FormatJSON {
+		line = encodeJSON(fields)
+	} else {
+		line = encodeLogfmt(fields)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.tail) < tailSize {
+		l.tail = append(l.tail, line)
+	} else {
+		l.tail[l.next] = line
+		l.next = (l.next + 1) % tailSize
+	}
+
+	if l.w != nil {
+		_, _ = io.WriteString(l.w, line+"\n")
+	}
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// value converts errors and durations to strings so they encode readably
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return v
+}
+
+func encodeJSON(fields []field) string {
+	var b strings.Builder
+
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(f.value))
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func encodeLogfmt(fields []field) string {
+	var b strings.Builder
+
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f.key)
+		b.WriteByte('=')
+
+		s := fmt.Sprint(f.value)
+		if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+
+	return b.String()
+}
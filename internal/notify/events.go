@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/georlav/bitstamp-cli/internal/execution"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+)
+
+// Event kinds
+const (
+	KindTrigger  = "trigger"
+	KindJob      = "job"
+	KindStrategy = "strategy"
+)
+
+// TriggerEvents returns events for triggers whose status changed to armed, fired, failed or orphaned
+func TriggerEvents(prev, next []trigger.Trigger) []Event {
+	status := make(map[string]trigger.Status, len(prev))
+	for _, t := range prev {
+		status[t.ID] = t.Status
+	}
+
+	var events []Event
+	for _, t := range next {
+		before, ok := status[t.ID]
+		if !ok || before == t.Status {
+			continue
+		}
+
+		pair := strings.ToUpper(t.Pair)
+		e := Event{Kind: KindTrigger, Pair: t.Pair, Time: t.Updated}
+		switch {
+		case t.Status == trigger.StatusFired:
+			e.Title = fmt.Sprintf("%s %s trigger fired", pair, t.Kind)
+			e.Message = fmt.Sprintf("sell order %s of %s sent", t.OrderID, t.SellAmount())
+		case t.Status == trigger.StatusFailed || t.Status == trigger.StatusOrphaned:
+			e.Title = fmt.Sprintf("%s %s trigger failed", pair, t.Kind)
+			e.Message = t.Error
+		case before == trigger.StatusWaiting && t.Status == trigger.StatusActive:
+			e.Title = fmt.Sprintf("%s entry order filled", pair)
+			e.Message = fmt.Sprintf("%s trigger %s armed", t.Kind, t.ID)
+		default:
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// JobEvents returns events for jobs that finished or failed
+func JobEvents(prev, next []execution.Job) []Event {
+	status := make(map[string]execution.Status, len(prev))
+	for _, j := range prev {
+		status[j.ID] = j.Status
+	}
+
+	var events []Event
+	for _, j := range next {
+		before, ok := status[j.ID]
+		if !ok || before == j.Status || (j.Status != execution.StatusDone && j.Status != execution.StatusFailed) {
+			continue
+		}
+
+		side := "buy"
+		if j.Sell {
+			side = "sell"
+		}
+		pair := strings.ToUpper(j.Pair.String())
+		e := Event{
+			Kind:  KindJob,
+			Title: fmt.Sprintf("%s %s %s job %s %s", pair, j.Kind, side, j.ID, j.Status),
+			Message: fmt.Sprintf("filled %s of %s at %s", strconv.FormatFloat(j.Filled, 'f', -1, 64),
+				strconv.FormatFloat(j.Amount, 'f', -1, 64), strconv.FormatFloat(j.AvgPrice(), 'f', -1, 64)),
+			Pair: j.Pair.String(),
+		}
+		if j.Error != "" {
+			e.Message += ", " + j.Error
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
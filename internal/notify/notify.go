@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/logging"
+)
+
+// sendTimeout time a sink is given to deliver a notification
+const sendTimeout = time.Second * 10
+
+// Event a notification, sent as json to command hooks
+type Event struct {
+	Kind    string    `json:"kind"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Pair    string    `json:"pair,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Sink delivers notifications
+type Sink interface {
+	Send(ctx context.Context, e Event) error
+}
+
+// Notifier sends events to every sink, at most perMinute events are sent per minute and
+// events identical to one sent within dedupe are dropped
+type Notifier struct {
+	sinks     []Sink
+	perMinute int
+	dedupe    time.Duration
+	log       *logging.Logger
+	sent      []time.Time
+	seen      map[string]time.Time
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+}
+
+// New creates a notifier sending to sinks
+func New(sinks []Sink, perMinute int, dedupe time.Duration, log *logging.Logger) *Notifier {
+	return &Notifier{
+		sinks:     sinks,
+		perMinute: perMinute,
+		dedupe:    dedupe,
+		log:       log,
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// Notify sends an event to the sinks in the background unless it is rate limited or a duplicate
+func (n *Notifier) Notify(e Event) {
+	if len(n.sinks) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if !n.allow(e) {
+		n.log.Debug("notification dropped", "kind", e.Kind, "title", e.Title)
+		return
+	}
+
+	for _, s := range n.sinks {
+		n.wg.Add(1)
+		go func(s Sink) {
+			defer n.wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := s.Send(ctx, e); err != nil {
+				n.log.Warn("notification failed", "kind", e.Kind, "error", err)
+			}
+		}(s)
+	}
+}
+
+// Close waits for notifications being sent
+func (n *Notifier) Close() {
+	n.wg.Wait()
+}
+
+func (n *Notifier) allow(e Event) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := e.Kind + "\x00" + e.Title + "\x00" + e.Message
+	if t, ok := n.seen[key]; ok && e.Time.Sub(t) < n.dedupe {
+		return false
+	}
+
+	// forget sends older than a minute and duplicates older than the dedupe window
+	recent := n.sent[:0]
+	for _, t := range n.sent {
+		if e.Time.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	n.sent = recent
+	for k, t := range n.seen {
+		if e.Time.Sub(t) >= n.dedupe {
+			delete(n.seen, k)
+		}
+	}
+
+	if len(n.sent) >= n.perMinute {
+		return false
+	}
+	n.sent = append(n.sent, e.Time)
+	n.seen[key] = e.Time
+
+	return true
+}
@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Sink names accepted by Build
+const (
+	SinkBell       = "bell"
+	SinkOSC9       = "osc9"
+	SinkOSC777     = "osc777"
+	SinkNotifySend = "notify-send"
+	SinkCommand    = "command"
+)
+
+// SinkNames lists the available sinks
+var SinkNames = []string{SinkBell, SinkOSC9, SinkOSC777, SinkNotifySend, SinkCommand}
+
+// Build creates the sinks with the given names, the returned terminal is nil unless a
+// terminal sink is used and must be flushed by the owner of the terminal
+func Build(names []string, command []string) ([]Sink, *Terminal, error) {
+	var (
+		sinks []Sink
+		term  *Terminal
+	)
+	for _, name := range names {
+		switch name {
+		case SinkBell, SinkOSC9, SinkOSC777:
+			if term == nil {
+				term = &Terminal{}
+				sinks = append(sinks, term)
+			}
+			term.Bell = term.Bell || name == SinkBell
+			term.OSC9 = term.OSC9 || name == SinkOSC9
+			term.OSC777 = term.OSC777 || name == SinkOSC777
+		case SinkNotifySend:
+			sinks = append(sinks, NotifySend{})
+		case SinkCommand:
+			if len(command) == 0 {
+				return nil, nil, errors.New("the command notification sink requires a command")
+			}
+			sinks = append(sinks, Command{Args: command})
+		default:
+			return nil, nil, fmt.Errorf("unknown notification sink %s, available sinks are %s", name, strings.Join(SinkNames, ", "))
+		}
+	}
+
+	return sinks, term, nil
+}
+
+// Terminal rings the terminal bell and writes OSC 9 and OSC 777 desktop notification escape
+// sequences. Sequences are queued until Flush so they are not interleaved with screen updates
+type Terminal struct {
+	Bell   bool
+	OSC9   bool
+	OSC777 bool
+	queue  []string
+	mu     sync.Mutex
+}
+
+// Send implements the Sink interface
+func (t *Terminal) Send(_ context.Context, e Event) error {
+	var seq string
+	if t.Bell {
+		seq += "\a"
+	}
+	if t.OSC9 {
+		seq += "\x1b]9;" + oscText(e.Title+": "+e.Message) + "\a"
+	}
+	if t.OSC777 {
+		// fields of OSC 777 are separated by semicolons
+		seq += "\x1b]777;notify;" + strings.ReplaceAll(oscText(e.Title), ";", ",") + ";" + oscText(e.Message) + "\a"
+	}
+
+	t.mu.Lock()
+	t.queue = append(t.queue, seq)
+	t.mu.Unlock()
+
+	return nil
+}
+
+// Flush writes queued sequences to w
+func (t *Terminal) Flush(w io.Writer) error {
+	t.mu.Lock()
+	queue := t.queue
+	t.queue = nil
+	t.mu.Unlock()
+
+	for _, seq := range queue {
+		if _, err := io.WriteString(w, seq); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// oscText removes control characters that would end an escape sequence early
+func oscText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// NotifySend shows desktop notifications with notify-send, available on most linux desktops
+type NotifySend struct{}
+
+// Send implements the Sink interface
+func (NotifySend) Send(ctx context.Context, e Event) error {
+	out, err := exec.CommandContext(ctx, "notify-send", "--app-name", "bitstamp-cli", e.Title, e.Message).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to run notify-send, %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// Command runs a command for every event with the event as json on stdin
+type Command struct {
+	Args []string
+}
+
+// Send implements the Sink interface
+func (c Command) Send(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification, %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Stdin = bytes.NewReader(b)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run notification command %s, %w: %s", c.Args[0], err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
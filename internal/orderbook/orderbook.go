@@ -0,0 +1,101 @@
+package orderbook
+
+import (
+	"math"
+	"strconv"
+)
+
+// Level a price level with parsed values
+type Level struct {
+	Price  float64
+	Amount float64
+}
+
+// Row an aggregated price level, Total is the cumulative amount from the top of the book
+type Row struct {
+	Price  float64
+	Amount float64
+	Total  float64
+	Own    bool
+}
+
+// ParseLevel converts a price and an amount to a level, invalid values are treated as zero
+func ParseLevel(price, amount string) Level {
+	p, _ := strconv.ParseFloat(price, 64)
+	a, _ := strconv.ParseFloat(amount, 64)
+
+	return Level{Price: p, Amount: a}
+}
+
+// Steps returns the available grouping steps for a pair with the given counter
+// decimals, starting from the smallest price increment of the pair
+func Steps(decimals int) []float64 {
+	steps := make([]float64, 0, 5)
+	for i := 0; i < 5; i++ {
+		steps = append(steps, math.Pow10(i-decimals))
+	}
+
+	return steps
+}
+
+// Bucket returns the grouped price of a level, bids are rounded down and asks up
+// so a bucket never shows a better price than the levels it contains
+func Bucket(price, step float64, bid bool) float64 {
+	if step <= 0 {
+		return price
+	}
+
+	// tolerate float representation errors of prices that are already multiples of step
+	n := price / step
+	if bid {
+		return math.Floor(n+1e-9) * step
+	}
+
+	return math.Ceil(n-1e-9) * step
+}
+
+// Group aggregates levels sorted from the top of the book into buckets of step size
+func Group(levels []Level, step float64, bid bool) []Row {
+	rows := make([]Row, 0, len(levels))
+
+	total := 0.0
+	for i := range levels {
+		price := Bucket(levels[i].Price, step, bid)
+		total += levels[i].Amount
+
+		if n := len(rows); n > 0 && rows[n-1].Price == price {
+			rows[n-1].Amount += levels[i].Amount
+			rows[n-1].Total = total
+			continue
+		}
+
+		rows = append(rows, Row{Price: price, Amount: levels[i].Amount, Total: total})
+	}
+
+	return rows
+}
+
+// MarkOwn flags the rows that contain any of the given order prices
+func MarkOwn(rows []Row, prices []float64, step float64, bid bool) {
+	for i := range rows {
+		for _, p := range prices {
+			if Bucket(p, step, bid) == rows[i].Price {
+				rows[i].Own = true
+			}
+		}
+	}
+}
+
+// MaxAmount returns the largest amount of the given rows
+func MaxAmount(rows ...[]Row) float64 {
+	max := 0.0
+	for i := range rows {
+		for j := range rows[i] {
+			if rows[i][j].Amount > max {
+				max = rows[i][j].Amount
+			}
+		}
+	}
+
+	return max
+}
@@ -0,0 +1,152 @@
+package overview
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+)
+
+// Sort order of overview rows
+type Sort int
+
+// Available sort orders, change and volume sort the largest first
+const (
+	SortName Sort = iota
+	SortChange
+	SortVolume
+)
+
+// Sorts names of the sort orders in the order they are cycled through
+var Sorts = []string{"name", "change", "volume"}
+
+// Row 24 hour statistics of a pair, Volume is in quote currency
+type Row struct {
+	Pair   bitstamp.Pair
+	Last   float64
+	Change float64
+	Volume float64
+	High   float64
+	Low    float64
+}
+
+// ticker 24 hour statistics of a pair seeded from the ticker and extended by live trades,
+// trades are not removed once older than 24 hours so tickers are reseeded periodically
+type ticker struct {
+	last, open, high, low, volume float64
+}
+
+// Board keeps the statistics of the pairs of the overview
+type Board struct {
+	tickers map[bitstamp.Pair]*ticker
+	// attempted time the ticker of a pair was last requested, successfully or not
+	attempted map[bitstamp.Pair]time.Time
+	mu        sync.Mutex
+}
+
+// New creates an empty board
+func New() *Board {
+	return &Board{
+		tickers:   make(map[bitstamp.Pair]*ticker),
+		attempted: make(map[bitstamp.Pair]time.Time),
+	}
+}
+
+// Seed replaces the statistics of a pair with the ones of its ticker
+func (b *Board) Seed(p bitstamp.Pair, r *bitstamp.GetTickerResponse, now time.Time) {
+	var t ticker
+	t.last, _ = strconv.ParseFloat(r.Last, 64)
+	t.open, _ = strconv.ParseFloat(r.Open, 64)
+	t.high, _ = strconv.ParseFloat(r.High, 64)
+	t.low, _ = strconv.ParseFloat(r.Low, 64)
+	t.volume, _ = strconv.ParseFloat(r.Volume, 64)
+
+	b.mu.Lock()
+	b.tickers[p], b.attempted[p] = &t, now
+	b.mu.Unlock()
+}
+
+// Failed records a failed ticker request of a pair, it is not requested again before it is stale
+func (b *Board) Failed(p bitstamp.Pair, now time.Time) {
+	b.mu.Lock()
+	b.attempted[p] = now
+	b.mu.Unlock()
+}
+
+// Trade updates the statistics of a seeded pair with a live trade
+func (b *Board) Trade(p bitstamp.Pair, price, amount float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.tickers[p]
+	if !ok || price <= 0 {
+		return
+	}
+
+	t.last = price
+	t.volume += amount
+	if price > t.high {
+		t.high = price
+	}
+	if price < t.low || t.low == 0 {
+		t.low = price
+	}
+}
+
+// Stale returns the pairs whose ticker was never requested or was requested more than maxAge ago
+func (b *Board) Stale(pairs []bitstamp.Pair, now time.Time, maxAge time.Duration) []bitstamp.Pair {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var stale []bitstamp.Pair
+	for _, p := range pairs {
+		if t, ok := b.attempted[p]; !ok || now.Sub(t) > maxAge {
+			stale = append(stale, p)
+		}
+	}
+
+	return stale
+}
+
+// Rows returns the statistics of the seeded pairs in the order given
+func (b *Board) Rows(pairs []bitstamp.Pair) []Row {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rows := make([]Row, 0, len(pairs))
+	for _, p := range pairs {
+		t, ok := b.tickers[p]
+		if !ok {
+			continue
+		}
+
+		r := Row{Pair: p, Last: t.last, Volume: t.volume * t.last, High: t.high, Low: t.low}
+		if t.open > 0 {
+			r.Change = (t.last/t.open - 1) * 100
+		}
+		rows = append(rows, r)
+	}
+
+	return rows
+}
+
+// Sorted returns a copy of rows in the given order
+func Sorted(rows []Row, s Sort) []Row {
+	sorted := make([]Row, len(rows))
+	copy(sorted, rows)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		switch s {
+		case SortChange:
+			return sorted[i].Change > sorted[j].Change
+		case SortVolume:
+			return sorted[i].Volume > sorted[j].Volume
+		default:
+			return sorted[i].Pair.String() < sorted[j].Pair.String()
+		}
+	})
+
+	return sorted
+}
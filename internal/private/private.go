@@ -0,0 +1,207 @@
+package private
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp"
+)
+
+const (
+	baseURL               = "https://www.bitstamp.net"
+	orderStatusURL        = "/api/v2/order_status/"
+	cryptoTransactionsURL = "/api/v2/crypto-transactions/"
+)
+
+// Client sends signed requests to private endpoints whose parameters or responses the
+// bitstamp library does not map completely. Requests are sent using http.DefaultClient
+// like the ones of bitstamp.HTTPAPI, so they share its rate limit and logging
+type Client struct {
+	key     string
+	secret  string
+	baseURL string
+}
+
+// New creates a client signing requests with an api key and secret
+func New(key, secret string) *Client {
+	return &Client{key: key, secret: secret, baseURL: baseURL}
+}
+
+// Transaction a transaction of an order, amounts are named after their currency
+type Transaction map[string]interface{}
+
+// Float returns a numeric field of a transaction, zero when missing or invalid
+func (t Transaction) Float(key string) float64 {
+	switch v := t[key].(type) {
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	case float64:
+		return v
+	}
+
+	return 0
+}
+
+// OrderStatus status of an order and its transactions
+type OrderStatus struct {
+	ID              int64         `json:"id"`
+	Status          string        `json:"status"`
+	AmountRemaining string        `json:"amount_remaining"`
+	Transactions    []Transaction `json:"transactions"`
+}
+
+// OrderStatus retrieves the status of an order
+func (c *Client) OrderStatus(ctx context.Context, id string) (*OrderStatus, error) {
+	var result OrderStatus
+	if err := c.Post(ctx, orderStatusURL, url.Values{"id": {id}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CryptoTransaction a cryptocurrency deposit, withdrawal or ripple IOU transaction
+type CryptoTransaction struct {
+	Currency           string `json:"currency"`
+	DestinationAddress string `json:"destination_address"`
+	TXID               string `json:"txid"`
+	Amount             string `json:"amount"`
+	Datetime           string `json:"datetime"`
+}
+
+// CryptoTransactions cryptocurrency transactions of an account, ripple IOU transactions are
+// only listed when requested and are missing from bitstamp.GetCryptoTransactionsResponse
+type CryptoTransactions struct {
+	Deposits              []CryptoTransaction `json:"deposits"`
+	Withdrawals           []CryptoTransaction `json:"withdrawals"`
+	RippleIOUTransactions []CryptoTransaction `json:"ripple_iou_transactions"`
+}
+
+// GetCryptoTransactions retrieves cryptocurrency deposits and withdrawals, the form is built
+// here because the library tags include_ious with a trailing space and bitstamp ignores it
+func (c *Client) GetCryptoTransactions(ctx context.Context, r bitstamp.GetCryptoTransactionsRequest) (*CryptoTransactions, error) {
+	form := url.Values{}
+	if r.Limit > 0 {
+		form.Set("limit", strconv.FormatInt(r.Limit, 10))
+	}
+	if r.Offset > 0 {
+		form.Set("offset", strconv.FormatInt(r.Offset, 10))
+	}
+	if r.IncludeIOUS {
+		form.Set("include_ious", "True")
+	}
+
+	var result CryptoTransactions
+	if err := c.Post(ctx, cryptoTransactionsURL, form, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Post sends form to a private endpoint and decodes its response into out. Failures
+// are returned as bitstamp.Error
+func (c *Client) Post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if err := c.sign(req, body); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response, %w", err)
+	}
+
+	// errors may be returned with status 200
+	var apiErr bitstamp.GenericErrorResponse
+	if resp.StatusCode != http.StatusOK || (json.Unmarshal(b, &apiErr) == nil && apiErr.Status == "error") {
+		return newError(resp.StatusCode, b)
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+// sign adds the authentication headers of version 2 of the api to a request
+func (c *Client) sign(req *http.Request, body string) error {
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Auth", "BITSTAMP "+c.key)
+	req.Header.Set("X-Auth-Nonce", nonce)
+	req.Header.Set("X-Auth-Timestamp", strconv.FormatInt(time.Now().UTC().UnixMilli(), 10))
+	req.Header.Set("X-Auth-Version", "v2")
+	req.Header.Set("Accept", "application/json")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	query := req.URL.RawQuery
+	if query != "" {
+		query = "?" + query
+	}
+	message := "BITSTAMP " + c.key + req.Method + req.URL.Host + req.URL.Path + query + req.Header.Get("Content-Type") +
+		nonce + req.Header.Get("X-Auth-Timestamp") + "v2" + body
+
+	mac := hmac.New(sha256.New, []byte(c.secret))
+	mac.Write([]byte(message))
+	req.Header.Set("X-Auth-Signature", hex.EncodeToString(mac.Sum(nil)))
+
+	return nil
+}
+
+// newNonce returns a random nonce formatted like a uuid, as required by the api
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate nonce, %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(b)
+
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+func newError(status int, body []byte) bitstamp.Error {
+	message := http.StatusText(status)
+
+	var resp bitstamp.GenericErrorResponse
+	if err := json.Unmarshal(bytes.TrimSpace(body), &resp); err == nil {
+		switch {
+		case resp.Reason != nil:
+			message = string(resp.Reason)
+		case len(resp.Errors) > 0:
+			message = string(resp.Errors)
+		case resp.Error != "":
+			message = resp.Error
+		}
+	}
+
+	return bitstamp.Error{Message: message, StatusCode: status}
+}
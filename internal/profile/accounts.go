@@ -0,0 +1,308 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/private"
+)
+
+// Accounts holds the HTTP API clients of profiles and tracks the active one. It is
+// safe for concurrent use
+type Accounts struct {
+	resolver Resolver
+	profiles []Profile
+	// resolved accounts by profile name, credentials are resolved once
+	resolved map[string]*Account
+	name     string
+	active   *Account
+	seq      int
+	mu       sync.Mutex
+}
+
+// Account the clients of one profile, orders sent through it always use the account
+// of that profile. Without credentials every request fails
+type Account struct {
+	name    string
+	api     *bitstamp.HTTPAPI
+	private *private.Client
+}
+
+// NewAccounts creates accounts of profiles, no profile is active until one is selected
+func NewAccounts(r Resolver, profiles []Profile) *Accounts {
+	return &Accounts{
+		resolver: r,
+		profiles: profiles,
+		resolved: make(map[string]*Account),
+	}
+}
+
+// Select makes a profile active, its credentials are resolved in the background and
+// done is called once the client has been replaced. A selection superseded by a later
+// one is discarded without calling done. Without credentials the profile has no client
+func (a *Accounts) Select(ctx context.Context, name string, done func(err error)) {
+	a.mu.Lock()
+	a.seq++
+	seq := a.seq
+	a.name, a.active = name, nil
+	a.mu.Unlock()
+
+	go func() {
+		acc, err := a.Account(ctx, name)
+
+		a.mu.Lock()
+		if seq != a.seq {
+			a.mu.Unlock()
+			return
+		}
+		a.active = acc
+		a.mu.Unlock()
+
+		done(err)
+	}()
+}
+
+// Active returns the name of the active profile and its client, the client is nil
+// while credentials are resolved or when the profile has none
+func (a *Accounts) Active() (string, *bitstamp.HTTPAPI) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.active == nil {
+		return a.name, nil
+	}
+
+	return a.name, a.active.api
+}
+
+// Has reports whether a profile is configured
+func (a *Accounts) Has(name string) bool {
+	for _, p := range a.profiles {
+		if p.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Account returns the account of a profile, its credentials are resolved on first use
+func (a *Accounts) Account(ctx context.Context, name string) (*Account, error) {
+	a.mu.Lock()
+	acc, ok := a.resolved[name]
+	a.mu.Unlock()
+	if ok {
+		return acc, nil
+	}
+
+	// credentials commands may be slow, they run without holding the lock
+	c, err := a.credentials(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	acc = &Account{name: name}
+	if c.Valid() {
+		acc.api = bitstamp.NewHTTPAPI(bitstamp.APIKeyOption(c.Key), bitstamp.APISecretOption(c.Secret))
+		acc.private = private.New(c.Key, c.Secret)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// a concurrent call may have resolved it first
+	if resolved, ok := a.resolved[name]; ok {
+		return resolved, nil
+	}
+	a.resolved[name] = acc
+
+	return acc, nil
+}
+
+func (a *Accounts) credentials(ctx context.Context, name string) (Credentials, error) {
+	for _, p := range a.profiles {
+		if p.Name != name {
+			continue
+		}
+
+		c, err := a.resolver.Resolve(ctx, p)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if !c.Valid() {
+			// the environment is the default source, missing credentials there only disable account data
+			if p.Source == "" || p.Source == SourceEnv {
+				return Credentials{}, nil
+			}
+
+			return Credentials{}, fmt.Errorf("profile %s has an empty api key or secret", name)
+		}
+
+		return c, nil
+	}
+
+	return Credentials{}, errors.New("unknown profile " + name)
+}
+
+// current returns the account of the active profile or an error while it is resolved
+func (a *Accounts) current() (*Account, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.active == nil {
+		return nil, fmt.Errorf("profile %s has no credentials", a.name)
+	}
+
+	return a.active, nil
+}
+
+// CreateBuyLimitOrder creates a buy limit order using the active profile
+func (a *Accounts) CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	acc, err := a.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return acc.CreateBuyLimitOrder(ctx, p, r)
+}
+
+// CreateBuyInstantOrder creates a buy instant order using the active profile
+func (a *Accounts) CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	acc, err := a.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return acc.CreateBuyInstantOrder(ctx, p, r)
+}
+
+// CreateSellLimitOrder creates a sell limit order using the active profile
+func (a *Accounts) CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	acc, err := a.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return acc.CreateSellLimitOrder(ctx, p, r)
+}
+
+// CreateSellInstantOrder creates a sell instant order using the active profile
+func (a *Accounts) CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	acc, err := a.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return acc.CreateSellInstantOrder(ctx, p, r)
+}
+
+// CancelOrder cancels an order using the active profile
+func (a *Accounts) CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error) {
+	acc, err := a.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return acc.CancelOrder(ctx, r)
+}
+
+// Name returns the name of the profile of the account
+func (a *Account) Name() string {
+	return a.name
+}
+
+// API returns the client of the account, nil when the profile has no credentials
+func (a *Account) API() *bitstamp.HTTPAPI {
+	return a.api
+}
+
+// client returns the client of the account or an error when the profile has no credentials
+func (a *Account) client() (*bitstamp.HTTPAPI, error) {
+	if a.api == nil {
+		return nil, fmt.Errorf("profile %s has no credentials", a.name)
+	}
+
+	return a.api, nil
+}
+
+// CreateBuyLimitOrder creates a buy limit order using the account
+func (a *Account) CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	api, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return api.CreateBuyLimitOrder(ctx, p, r)
+}
+
+// CreateBuyInstantOrder creates a buy instant order using the account
+func (a *Account) CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	api, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return api.CreateBuyInstantOrder(ctx, p, r)
+}
+
+// CreateSellLimitOrder creates a sell limit order using the account
+func (a *Account) CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	api, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return api.CreateSellLimitOrder(ctx, p, r)
+}
+
+// CreateSellInstantOrder creates a sell instant order using the account
+func (a *Account) CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	api, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return api.CreateSellInstantOrder(ctx, p, r)
+}
+
+// CancelOrder cancels an order using the account
+func (a *Account) CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error) {
+	api, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return api.CancelOrder(ctx, r)
+}
+
+// GetOrderStatus retrieves the status of an order using the account
+func (a *Account) GetOrderStatus(ctx context.Context, r bitstamp.GetOrderStatusRequest) (*bitstamp.GetOrderStatusResponse, error) {
+	api, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return api.GetOrderStatus(ctx, r)
+}
+
+// OrderStatus retrieves the status of an order with every field of its transactions
+// using the account
+func (a *Account) OrderStatus(ctx context.Context, id string) (*private.OrderStatus, error) {
+	if a.private == nil {
+		return nil, fmt.Errorf("profile %s has no credentials", a.name)
+	}
+
+	return a.private.OrderStatus(ctx, id)
+}
+
+// GetCryptoTransactions retrieves crypto deposits and withdrawals using the account
+func (a *Account) GetCryptoTransactions(ctx context.Context, r bitstamp.GetCryptoTransactionsRequest) (*private.CryptoTransactions, error) {
+	if a.private == nil {
+		return nil, fmt.Errorf("profile %s has no credentials", a.name)
+	}
+
+	return a.private.GetCryptoTransactions(ctx, r)
+}
@@ -0,0 +1,112 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Credential sources
+const (
+	// SourceEnv reads credentials from BITSTAMP_KEY and BITSTAMP_SECRET
+	SourceEnv = "env"
+	// SourceFile reads credentials from the encrypted secrets file
+	SourceFile = "file"
+	// SourceCommand reads credentials from the output of a command
+	SourceCommand = "command"
+)
+
+// DefaultName name of the profile used when none are configured
+const DefaultName = "default"
+
+// Profile an exchange account and the source of its credentials
+type Profile struct {
+	Name string `json:"name"`
+	// Source one of env, file or command, defaults to env
+	Source string `json:"source"`
+	// Command program and arguments printing the api key and secret on separate lines, e.g. ["pass", "bitstamp/main"]
+	Command []string `json:"command"`
+}
+
+// Credentials api key and secret of an account
+type Credentials struct {
+	Key    string `json:"key"`
+	Secret string `json:"secret"`
+}
+
+// Valid reports whether both key and secret are set
+func (c Credentials) Valid() bool {
+	return c.Key != "" && c.Secret != ""
+}
+
+// Validate checks that the profile has a name and a known source
+func (p Profile) Validate() error {
+	if p.Name == "" {
+		return errors.New("profile name is required")
+	}
+
+	switch p.Source {
+	case "", SourceEnv, SourceFile:
+	case SourceCommand:
+		if len(p.Command) == 0 {
+			return fmt.Errorf("profile %s requires a command", p.Name)
+		}
+	default:
+		return fmt.Errorf("profile %s has unknown source %s", p.Name, p.Source)
+	}
+
+	return nil
+}
+
+// Resolver resolves profile credentials
+type Resolver struct {
+	// Env credentials read from the environment
+	Env Credentials
+	// Secrets decrypted contents of the secrets file by profile name
+	Secrets map[string]Credentials
+}
+
+// Resolve returns the credentials of a profile
+func (r Resolver) Resolve(ctx context.Context, p Profile) (Credentials, error) {
+	switch p.Source {
+	case SourceFile:
+		c, ok := r.Secrets[p.Name]
+		if !ok {
+			return Credentials{}, fmt.Errorf("profile %s is missing from the secrets file", p.Name)
+		}
+
+		return c, nil
+
+	case SourceCommand:
+		return run(ctx, p.Command)
+	}
+
+	return r.Env, nil
+}
+
+// run executes a credentials command, the first two non empty lines of its output are the key and secret
+func run(ctx context.Context, command []string) (Credentials, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to run credentials command %s, %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	var lines []string
+	for _, l := range strings.Split(string(out), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) < 2 {
+		return Credentials{}, fmt.Errorf("credentials command %s must print the api key and secret on separate lines", command[0])
+	}
+
+	return Credentials{Key: lines[0], Secret: lines[1]}, nil
+}
@@ -0,0 +1,225 @@
+package profile
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// iterations of PBKDF2-HMAC-SHA256 used to derive keys of new files
+	iterations = 600000
+	saltSize   = 16
+	keySize    = 32
+)
+
+// PassphraseEnv environment variable read before prompting for the secrets file passphrase
+const PassphraseEnv = "BITSTAMP_CLI_PASSPHRASE"
+
+// secretsFile contents of the encrypted secrets file, data is the AES-256-GCM encrypted
+// json object of credentials by profile name
+type secretsFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// DefaultSecretsPath returns the location of the secrets file under the user config directory
+func DefaultSecretsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "bitstamp-cli.secrets"
+	}
+
+	return filepath.Join(dir, "bitstamp-cli", "secrets.json")
+}
+
+// LoadSecrets decrypts the secrets file, a missing file contains no secrets
+func LoadSecrets(path, passphrase string) (map[string]Credentials, error) {
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return map[string]Credentials{}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read secrets file, %w", err)
+	}
+
+	var f secretsFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file %s, %w", path, err)
+	}
+	if f.Version != 1 {
+		return nil, fmt.Errorf("unsupported secrets file version %d", f.Version)
+	}
+
+	gcm, err := newGCM(passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("secrets file nonce is invalid")
+	}
+
+	data, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt secrets file, wrong passphrase or corrupted file")
+	}
+
+	secrets := make(map[string]Credentials)
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted secrets, %w", err)
+	}
+
+	return secrets, nil
+}
+
+// SaveSecrets encrypts secrets with a key derived from passphrase and replaces the secrets file
+func SaveSecrets(path, passphrase string, secrets map[string]Credentials) error {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	f := secretsFile{
+		Version:    1,
+		Iterations: iterations,
+		Salt:       make([]byte, saltSize),
+	}
+	if _, err := io.ReadFull(rand.Reader, f.Salt); err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, f.Nonce); err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, data, nil)
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create secrets directory, %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write secrets file, %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// ReadPassphrase returns the passphrase from PassphraseEnv or prompts for it on the terminal
+func ReadPassphrase(prompt string) (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+
+	return ReadSecret(prompt)
+}
+
+// ReadSecret prompts for a value on the terminal without echoing it
+func ReadSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	// disable echo where stty is available, elsewhere the passphrase is echoed
+	if err := stty("-echo"); err == nil {
+		defer func() {
+			_ = stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	// read a byte at a time, a buffered reader would consume the lines of following prompts
+	var (
+		line []byte
+		b    [1]byte
+	)
+	for {
+		n, err := os.Stdin.Read(b[:])
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read input, %w", err)
+		}
+	}
+
+	return strings.TrimRight(string(line), "\r"), nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+
+	return cmd.Run()
+}
+
+func newGCM(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	if iter <= 0 || len(salt) == 0 {
+		return nil, errors.New("secrets file key parameters are invalid")
+	}
+
+	block, err := aes.NewCipher(pbkdf2([]byte(passphrase), salt, iter, keySize))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key using PBKDF2 with HMAC-SHA256 as specified in RFC 8018
+func pbkdf2(password, salt []byte, iter, size int) []byte {
+	prf := hmac.New(sha256.New, password)
+	n := (size + prf.Size() - 1) / prf.Size()
+
+	key := make([]byte, 0, n*prf.Size())
+	u := make([]byte, prf.Size())
+	for block := 1; block <= n; block++ {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(block))
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(b[:])
+		u = prf.Sum(u[:0])
+
+		t := append([]byte(nil), u...)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:size]
+}
@@ -0,0 +1,130 @@
+package profile
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 test vectors, the inputs of RFC 6070 and the 64 byte key of RFC 7914
+	tests := []struct {
+		password, salt string
+		iter, size     int
+		want           string
+	}{
+		{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, 32, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwd", "salt", 1, 64, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	}
+
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iter, tt.size))
+		if got != tt.want {
+			t.Errorf("pbkdf2(%s, %s, %d) = %s, want %s", tt.password, tt.salt, tt.iter, got, tt.want)
+		}
+	}
+}
+
+func TestSecretsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "secrets.json")
+	secrets := map[string]Credentials{
+		"main":   {Key: "key1", Secret: "secret1"},
+		"second": {Key: "key2", Secret: "secret2"},
+	}
+
+	if err := SaveSecrets(path, "correct horse", secrets); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret1") || strings.Contains(string(b), "key2") {
+		t.Error("secrets file contains plain text credentials")
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("secrets file mode is %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := LoadSecrets(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded["main"] != secrets["main"] || loaded["second"] != secrets["second"] {
+		t.Errorf("got %+v, want %+v", loaded, secrets)
+	}
+
+	if _, err := LoadSecrets(path, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("got error %v, want a wrong passphrase error", err)
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	// files are written with few iterations, the iterations are read from the file
+	write := func(t *testing.T, change func(f *secretsFile)) string {
+		f := secretsFile{Version: 1, Iterations: 1000, Salt: []byte("0123456789abcdef")}
+		gcm, err := newGCM("pass", f.Salt, f.Iterations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Nonce = make([]byte, gcm.NonceSize())
+		f.Data = gcm.Seal(nil, f.Nonce, []byte(`{"main":{"key":"k","secret":"s"}}`), nil)
+		if change != nil {
+			change(&f)
+		}
+
+		b, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	tests := []struct {
+		name   string
+		change func(f *secretsFile)
+		err    string
+	}{
+		{name: "valid"},
+		{name: "unsupported version", change: func(f *secretsFile) { f.Version = 2 }, err: "unsupported secrets file version"},
+		{name: "missing salt", change: func(f *secretsFile) { f.Salt = nil }, err: "key parameters are invalid"},
+		{name: "invalid iterations", change: func(f *secretsFile) { f.Iterations = 0 }, err: "key parameters are invalid"},
+		{name: "invalid nonce", change: func(f *secretsFile) { f.Nonce = f.Nonce[:4] }, err: "nonce is invalid"},
+		{name: "tampered data", change: func(f *secretsFile) { f.Data[0] ^= 1 }, err: "wrong passphrase or corrupted file"},
+		{name: "tampered salt", change: func(f *secretsFile) { f.Salt[0] ^= 1 }, err: "wrong passphrase or corrupted file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets, err := LoadSecrets(write(t, tt.change), "pass")
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error %s", err)
+			case tt.err == "" && secrets["main"] != (Credentials{Key: "k", Secret: "s"}):
+				t.Errorf("got %+v", secrets)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadSecretsMissingFile(t *testing.T) {
+	secrets, err := LoadSecrets(filepath.Join(t.TempDir(), "missing.json"), "pass")
+	if err != nil || len(secrets) != 0 {
+		t.Errorf("got %v and error %v, want no secrets", secrets, err)
+	}
+}
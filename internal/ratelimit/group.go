@@ -0,0 +1,38 @@
+package ratelimit
+
+import "sync"
+
+// Group coalesces concurrent calls with the same key, while a call is in flight
+// further calls with its key are dropped
+type Group struct {
+	running map[string]bool
+	mu      sync.Mutex
+}
+
+// NewGroup creates an empty group
+func NewGroup() *Group {
+	return &Group{
+		running: make(map[string]bool),
+	}
+}
+
+// Do runs fn unless a call with the same key is in flight, returns false when fn was dropped
+func (g *Group) Do(key string, fn func()) bool {
+	g.mu.Lock()
+	if g.running[key] {
+		g.mu.Unlock()
+		return false
+	}
+	g.running[key] = true
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.running, key)
+		g.mu.Unlock()
+	}()
+
+	fn()
+
+	return true
+}
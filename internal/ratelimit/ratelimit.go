@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket limiter that also counts requests made during the
+// last window, used to report the remaining request budget. It is safe for concurrent use
+type Limiter struct {
+	limit  int
+	window time.Duration
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	sent   []time.Time
+	mu     sync.Mutex
+}
+
+// New creates a limiter allowing limit requests per window with bursts of up to burst requests
+func New(limit int, window time.Duration, burst int) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		rate:   float64(limit) / window.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request is allowed or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.refill(now)
+		if l.tokens >= 1 {
+			l.tokens--
+			l.sent = append(l.sent, now)
+			l.mu.Unlock()
+
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Remaining returns the number of requests that can still be made in the current window and the window limit
+func (l *Limiter) Remaining() (int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.expire(time.Now())
+
+	return l.limit - len(l.sent), l.limit
+}
+
+func (l *Limiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.expire(now)
+}
+
+func (l *Limiter) expire(now time.Time) {
+	i := 0
+	for i < len(l.sent) && now.Sub(l.sent[i]) >= l.window {
+		i++
+	}
+	l.sent = l.sent[i:]
+}
+
+// Transport limits requests sent through the next round tripper
+type Transport struct {
+	Limiter *Limiter
+	Next    http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(r.Context()); err != nil {
+		return nil, err
+	}
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return next.RoundTrip(r)
+}
@@ -0,0 +1,38 @@
+package statusline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrNotRunning no daemon is listening on the socket
+var ErrNotRunning = errors.New("statusline daemon is not running")
+
+// Query requests the quotes of pairs from the daemon listening on socket
+func Query(socket string, pairs []string, timeout time.Duration) ([]Quote, error) {
+	conn, err := net.DialTimeout("unix", socket, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(conn).Encode(request{Pairs: pairs}); err != nil {
+		return nil, fmt.Errorf("failed to send statusline request, %w", err)
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read statusline response, %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return resp.Quotes, nil
+}
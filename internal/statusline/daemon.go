@@ -0,0 +1,316 @@
+package statusline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+	"github.com/georlav/bitstamp-cli/internal/stream"
+)
+
+const (
+	// idleTimeout time without requests after which the daemon exits
+	idleTimeout = time.Minute * 10
+	// openRefresh time after which the 24 hour open price of a pair is reloaded
+	openRefresh = time.Minute * 10
+	// connTimeout time allowed to read a request and write its response
+	connTimeout = time.Second * 5
+)
+
+// Market provides ticker prices, implemented by bitstamp.HTTPAPI
+type Market interface {
+	GetTicker(ctx context.Context, p bitstamp.Pair) (*bitstamp.GetTickerResponse, error)
+}
+
+// request sent by clients, one json object per connection
+type request struct {
+	Pairs []string `json:"pairs"`
+}
+
+// response sent by the daemon, quotes are in the order of the requested pairs
+type response struct {
+	Quotes []Quote `json:"quotes"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// quote a quote and when its open price was loaded
+type quote struct {
+	Quote
+	loaded time.Time
+}
+
+// Daemon keeps one websocket connection subscribed to the trades of the pairs requested
+// by clients and answers requests on a unix socket. It exits after idleTimeout without requests
+type Daemon struct {
+	socket string
+	market Market
+	log    *logging.Logger
+	stream *stream.Stream
+	pairs  map[string]bitstamp.Pair
+	quotes map[bitstamp.Pair]*quote
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// DefaultSocket returns the location of the daemon socket under the user runtime directory
+func DefaultSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "bitstamp-cli-"+strconv.Itoa(os.Getuid())+".sock")
+}
+
+// NewDaemon creates a daemon listening on socket, open prices are loaded from market
+func NewDaemon(socket string, market Market, log *logging.Logger) *Daemon {
+	pairs := make(map[string]bitstamp.Pair)
+	for _, p := range bitstamp.GetAllPairs() {
+		pairs[p.String()] = p
+	}
+
+	return &Daemon{
+		socket: socket,
+		market: market,
+		log:    log,
+		// the daemon has no selected pair, every channel is watched
+		stream: stream.New(bitstamp.BTCUSD, func(p bitstamp.Pair) []bitstamp.Channel { return nil }, log),
+		pairs:  pairs,
+		quotes: make(map[bitstamp.Pair]*quote),
+		last:   time.Now(),
+	}
+}
+
+// Run serves requests until ctx is done or the daemon is idle
+func (d *Daemon) Run(ctx context.Context) error {
+	ln, err := listen(d.socket)
+	if err != nil {
+		return err
+	}
+	d.log.Info("statusline daemon started", "socket", d.socket)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+
+		d.stream.Run(ctx, stream.Handler{
+			Message:      d.message,
+			Error:        func(err error) {},
+			Connected:    func(reconnect bool) {},
+			Disconnected: func() {},
+		})
+	}()
+
+	go func() {
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				ln.Close()
+				return
+			case <-t.C:
+				d.mu.Lock()
+				idle := time.Since(d.last) > idleTimeout
+				d.mu.Unlock()
+				if idle {
+					d.log.Info("statusline daemon idle")
+					cancel()
+				}
+			}
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			cancel()
+			<-streamDone
+			return fmt.Errorf("failed to accept statusline connection, %w", err)
+		}
+
+		go d.serve(ctx, conn)
+	}
+
+	<-streamDone
+	d.log.Info("statusline daemon stopped")
+
+	return nil
+}
+
+// serve answers the request of a connection
+func (d *Daemon) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		// connections checking whether the daemon is running close without a request
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		d.log.Warn("invalid statusline request", "error", err)
+		return
+	}
+
+	var resp response
+	quotes, err := d.quotesOf(ctx, req.Pairs)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	resp.Quotes = quotes
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		d.log.Warn("failed to write statusline response", "error", err)
+	}
+}
+
+// quotesOf returns the quotes of pairs, pairs requested for the first time are
+// loaded from the ticker and watched from then on
+func (d *Daemon) quotesOf(ctx context.Context, names []string) ([]Quote, error) {
+	pairs := make([]bitstamp.Pair, 0, len(names))
+	for _, name := range names {
+		p, ok := d.pairs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown pair %s", name)
+		}
+		pairs = append(pairs, p)
+	}
+
+	d.mu.Lock()
+	d.last = time.Now()
+	var stale []bitstamp.Pair
+	for _, p := range pairs {
+		if q, ok := d.quotes[p]; !ok || time.Since(q.loaded) > openRefresh {
+			stale = append(stale, p)
+		}
+	}
+	d.mu.Unlock()
+
+	var added bool
+	for _, p := range stale {
+		t, err := d.market.GetTicker(ctx, p)
+		if err != nil {
+			d.log.Warn("failed to retrieve ticker", "pair", p, "error", err)
+		}
+
+		d.mu.Lock()
+		q, ok := d.quotes[p]
+		if !ok {
+			// trades update the price of pairs whose ticker failed, the open price is retried on the next request
+			q, added = &quote{Quote: Quote{Pair: p.String()}}, true
+			d.quotes[p] = q
+		}
+		if err == nil {
+			if q.Last == 0 {
+				q.Last, _ = strconv.ParseFloat(t.Last, 64)
+				q.Updated = time.Now()
+			}
+			q.Open, _ = strconv.ParseFloat(t.Open, 64)
+			q.loaded = time.Now()
+		}
+		d.mu.Unlock()
+	}
+
+	if added {
+		d.mu.Lock()
+		channels := make([]bitstamp.Channel, 0, len(d.quotes))
+		for p := range d.quotes {
+			channels = append(channels, bitstamp.GetLiveTradeChannel(p))
+		}
+		d.mu.Unlock()
+
+		if err := d.stream.Watch(ctx, "statusline", channels); err != nil {
+			d.log.Warn("failed to subscribe", "error", err)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	quotes := make([]Quote, 0, len(pairs))
+	for _, p := range pairs {
+		if q, ok := d.quotes[p]; ok {
+			quotes = append(quotes, q.Quote)
+			continue
+		}
+		quotes = append(quotes, Quote{Pair: p.String()})
+	}
+
+	return quotes, nil
+}
+
+// message updates the last price of a pair from a trade
+func (d *Daemon) message(m interface{}) {
+	v, ok := m.(bitstamp.LiveTickerChannel)
+	if !ok {
+		return
+	}
+
+	p, ok := d.pairs[v.Channel[strings.LastIndex(v.Channel, "_")+1:]]
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q, ok := d.quotes[p]
+	if !ok {
+		return
+	}
+
+	switch {
+	case v.Data.Price > q.Last:
+		q.Tick = 1
+	case v.Data.Price < q.Last:
+		q.Tick = -1
+	}
+	q.Last, q.Updated = v.Data.Price, time.Now()
+}
+
+// listen listens on socket, a socket file left by a daemon that is no longer running is replaced
+func listen(socket string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		conn.Close()
+		return nil, errors.New("statusline daemon is already running on " + socket)
+	}
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale statusline socket, %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create statusline socket directory, %w", err)
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on statusline socket, %w", err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict statusline socket, %w", err)
+	}
+
+	return ln, nil
+}
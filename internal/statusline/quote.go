@@ -0,0 +1,100 @@
+package statusline
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultFormat template used when none is configured, it prints every pair with its
+// last price and change over 24 hours
+const DefaultFormat = `{{range $i, $q := .}}{{if $i}} {{end}}{{$q.Symbol}} {{$q.Price}} {{$q.Arrow}}{{printf "%.2f" $q.Change}}%{{end}}`
+
+// Quote last traded price of a pair
+type Quote struct {
+	Pair string  `json:"pair"`
+	Last float64 `json:"last"`
+	// Open price 24 hours ago
+	Open float64 `json:"open"`
+	// Tick direction of the last trade against the previous one, 1 up, -1 down and 0 unchanged
+	Tick    int       `json:"tick"`
+	Updated time.Time `json:"updated"`
+}
+
+// Symbol returns the upper case pair name
+func (q Quote) Symbol() string {
+	return strings.ToUpper(q.Pair)
+}
+
+// Price returns the last price, a dash when no price is known yet
+func (q Quote) Price() string {
+	if q.Last == 0 {
+		return "-"
+	}
+
+	return strconv.FormatFloat(q.Last, 'f', -1, 64)
+}
+
+// Change returns the change of the last price over 24 hours in percent
+func (q Quote) Change() float64 {
+	if q.Open == 0 || q.Last == 0 {
+		return 0
+	}
+
+	return (q.Last - q.Open) / q.Open * 100
+}
+
+// Arrow returns an arrow pointing in the direction of the change over 24 hours
+func (q Quote) Arrow() string {
+	switch c := q.Change(); {
+	case c > 0:
+		return "▲"
+	case c < 0:
+		return "▼"
+	}
+
+	return "="
+}
+
+// TickArrow returns an arrow pointing in the direction of the last trade
+func (q Quote) TickArrow() string {
+	switch {
+	case q.Tick > 0:
+		return "↑"
+	case q.Tick < 0:
+		return "↓"
+	}
+
+	return ""
+}
+
+// Age returns the time since the last price was updated
+func (q Quote) Age() time.Duration {
+	return time.Since(q.Updated).Truncate(time.Second)
+}
+
+// Parse parses a status line template, it is executed with a slice of quotes
+func Parse(format string) (*template.Template, error) {
+	t, err := template.New("statusline").Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse statusline format, %w", err)
+	}
+
+	return t, nil
+}
+
+// Render executes a status line template with quotes
+func Render(t *template.Template, quotes []Quote) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, quotes); err != nil {
+		return "", fmt.Errorf("failed to render statusline, %w", err)
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,107 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/candle"
+)
+
+// Fill a simulated order of a backtest
+type Fill struct {
+	Time   time.Time
+	Sell   bool
+	Amount float64
+	Price  float64
+}
+
+// Result of a backtest, First and Last are the close prices of the first and last candle tested
+type Result struct {
+	Rule    Rule
+	Candles int
+	Signals int
+	Fills   []Fill
+	Paper   Paper
+	First   float64
+	Last    float64
+	// MaxDrawdown largest drop of the paper equity from its peak in quote currency
+	MaxDrawdown float64
+}
+
+// PnL returns the profit of the paper account at the last price
+func (r Result) PnL() float64 {
+	return r.Paper.Equity(r.Last)
+}
+
+// BuyHold returns the percent change of the price over the backtest
+func (r Result) BuyHold() float64 {
+	if r.First == 0 {
+		return 0
+	}
+
+	return (r.Last/r.First - 1) * 100
+}
+
+// Backtest evaluates a rule on every candle closed between from and to, the condition is
+// evaluated like it is live and paper orders of paper and order rules are filled at the close
+// paying the fee rate. Candles before from are loaded to warm up the indicators
+func Backtest(ctx context.Context, source Source, rule Rule, from, to time.Time, rate float64) (Result, error) {
+	// the window of closed candles evaluated by live rules
+	lookback := rule.Lookback() + 1
+	candles, err := source.Candles(ctx, rule.pair, rule.step, from.Add(-rule.step*time.Duration(lookback)), to)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to load candles, %w", err)
+	}
+
+	result := Result{Rule: rule}
+	var value, evaluated bool
+	var peak float64
+	for i, c := range candles {
+		if c.Time.Before(candle.Start(from, rule.step)) || c.Time.Add(rule.step).After(to) {
+			continue
+		}
+
+		start := i + 1 - lookback
+		if start < 0 {
+			start = 0
+		}
+		v, err := rule.expr.Eval(&series{candles: candles[start : i+1]})
+		switch {
+		case errors.Is(err, errNoData):
+			continue
+		case err != nil:
+			return result, fmt.Errorf("failed to evaluate candle %s, %w", c.Time.UTC().Format(time.RFC3339), err)
+		}
+
+		if result.Candles == 0 {
+			result.First = c.Close
+		}
+		result.Candles++
+		result.Last = c.Close
+
+		if v && !value && evaluated {
+			result.Signals++
+			if rule.Action != ActionAlert {
+				result.Paper.Fill(rule.Sell(), rule.Amount, c.Close, rate)
+				result.Fills = append(result.Fills, Fill{Time: c.Time.Add(rule.step), Sell: rule.Sell(), Amount: rule.Amount, Price: c.Close})
+			}
+		}
+		value, evaluated = v, true
+
+		equity := result.Paper.Equity(c.Close)
+		if equity > peak {
+			peak = equity
+		}
+		if dd := peak - equity; dd > result.MaxDrawdown {
+			result.MaxDrawdown = dd
+		}
+	}
+
+	if result.Candles == 0 {
+		return result, fmt.Errorf("not enough candles between %s and %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+
+	return result, nil
+}
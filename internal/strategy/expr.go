@@ -0,0 +1,424 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errNoData is returned when there are not enough candles to evaluate an indicator
+var errNoData = errors.New("not enough candles")
+
+// kind static type of an expression
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindBool
+)
+
+func (k kind) String() string {
+	if k == kindBool {
+		return "boolean"
+	}
+
+	return "number"
+}
+
+// node an expression, booleans evaluate to 1 and 0
+type node interface {
+	eval(s *series) (float64, error)
+	kind() kind
+	// lookback number of candles required to evaluate the node
+	lookback() int
+}
+
+// Expr a parsed boolean expression
+type Expr struct {
+	source string
+	root   node
+}
+
+// Parse parses a boolean expression such as sma(20) > sma(50) && rsi(14) < 70
+func Parse(source string) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at %d", t, t.pos)
+	}
+	if root.kind() != kindBool {
+		return nil, errors.New("expression must be a condition, e.g. close > sma(20)")
+	}
+
+	return &Expr{source: source, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Lookback returns the number of candles required to evaluate the expression
+func (e *Expr) Lookback() int {
+	return e.root.lookback()
+}
+
+// Eval evaluates the expression against candles, errNoData is returned while there are not enough of them
+func (e *Expr) Eval(s *series) (bool, error) {
+	v, err := e.root.eval(s)
+	return v != 0, err
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+
+	return strconv.Quote(t.text)
+}
+
+// operators longest first so two character operators are matched before their prefixes
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "+", "-", "*", "/", "!"}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(s) && (unicode.IsDigit(rune(s[i])) || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[start:i], pos: start})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(s) && (unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i])) || s[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[start:i], pos: start})
+
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+// parser recursive descent parser, from the lowest precedence: ||, &&, comparisons,
+// + and -, * and /, unary - and !
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+
+	return t
+}
+
+// accept consumes the next token when it is one of the operators
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.i++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) or() (node, error) {
+	return p.binary(p.and, kindBool, "||")
+}
+
+func (p *parser) and() (node, error) {
+	return p.binary(p.comparison, kindBool, "&&")
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if left.kind() != kindNumber || right.kind() != kindNumber {
+		return nil, fmt.Errorf("%s compares numbers", op)
+	}
+
+	return &binaryNode{op: op, left: left, right: right, result: kindBool}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.binary(p.product, kindNumber, "+", "-")
+}
+
+func (p *parser) product() (node, error) {
+	return p.binary(p.unary, kindNumber, "*", "/")
+}
+
+// binary parses operands joined by ops, operands and result are of kind k
+func (p *parser) binary(operand func() (node, error), k kind, ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != k || right.kind() != k {
+			return nil, fmt.Errorf("%s expects %s operands", op, k)
+		}
+		left = &binaryNode{op: op, left: left, right: right, result: k}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	op, ok := p.accept("-", "!")
+	if !ok {
+		return p.primary()
+	}
+
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	want := kindNumber
+	if op == "!" {
+		want = kindBool
+	}
+	if operand.kind() != want {
+		return nil, fmt.Errorf("%s expects a %s operand", op, want)
+	}
+
+	return &unaryNode{op: op, operand: operand}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at %d", t.text, t.pos)
+		}
+		return &numberNode{value: v}, nil
+
+	case tokenLParen:
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ) at %d, found %s", t.pos, t)
+		}
+		return n, nil
+
+	case tokenIdent:
+		if p.peek().kind != tokenLParen {
+			return variable(t)
+		}
+		p.next()
+
+		var args []token
+		for p.peek().kind != tokenRParen {
+			if p.peek().kind == tokenEOF {
+				return nil, fmt.Errorf("missing ) of %s", t.text)
+			}
+			if len(args) > 0 {
+				if t := p.next(); t.kind != tokenComma {
+					return nil, fmt.Errorf("expected , at %d, found %s", t.pos, t)
+				}
+			}
+			args = append(args, p.next())
+		}
+		p.next()
+
+		return function(t, args)
+	}
+
+	return nil, fmt.Errorf("unexpected %s at %d", t, t.pos)
+}
+
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) eval(*series) (float64, error) { return n.value, nil }
+func (n *numberNode) kind() kind                    { return kindNumber }
+func (n *numberNode) lookback() int                 { return 0 }
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) eval(s *series) (float64, error) {
+	v, err := n.operand.eval(s)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "!" {
+		return boolValue(v == 0), nil
+	}
+
+	return -v, nil
+}
+
+func (n *unaryNode) kind() kind    { return n.operand.kind() }
+func (n *unaryNode) lookback() int { return n.operand.lookback() }
+
+type binaryNode struct {
+	op          string
+	left, right node
+	result      kind
+}
+
+func (n *binaryNode) eval(s *series) (float64, error) {
+	l, err := n.left.eval(s)
+	if err != nil {
+		return 0, err
+	}
+
+	// conditions short circuit
+	switch {
+	case n.op == "&&" && l == 0:
+		return 0, nil
+	case n.op == "||" && l != 0:
+		return 1, nil
+	}
+
+	r, err := n.right.eval(s)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return boolValue(r != 0), nil
+	case "<":
+		return boolValue(l < r), nil
+	case "<=":
+		return boolValue(l <= r), nil
+	case ">":
+		return boolValue(l > r), nil
+	case ">=":
+		return boolValue(l >= r), nil
+	case "==":
+		return boolValue(l == r), nil
+	case "!=":
+		return boolValue(l != r), nil
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	default:
+		if r == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return l / r, nil
+	}
+}
+
+func (n *binaryNode) kind() kind { return n.result }
+
+func (n *binaryNode) lookback() int {
+	if l, r := n.left.lookback(), n.right.lookback(); l > r {
+		return l
+	}
+
+	return n.right.lookback()
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,157 @@
+package strategy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/georlav/bitstamp-cli/internal/candle"
+)
+
+// closes returns a series of candles closing at v, highs and lows are one above and below
+func closes(v ...float64) *series {
+	s := &series{}
+	for _, c := range v {
+		s.candles = append(s.candles, candle.Candle{Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 1})
+	}
+
+	return s
+}
+
+func TestParseEval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"10 - 4 - 3 == 3", true},
+		{"12 / 3 / 2 == 2", true},
+		{"-2 * -3 == 6", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"!(1 > 2)", true},
+		{"!!true", true},
+		{"1 <= 1 && 1 >= 1 && 1 != 2", true},
+		{"close > open", false},
+		{"close == price && high - low == 2", true},
+		{"volume == 1", true},
+		{"false && sma(100) > 0", false},
+		{"true || sma(100) > 0", true},
+		{".5 + .5 == 1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := Parse(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.Eval(closes(5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"", "unexpected end of expression at 0"},
+		{"close", "expression must be a condition"},
+		{"close >", "unexpected end of expression at 7"},
+		{"close > 1 )", `unexpected ")" at 10`},
+		{"1 < 2 < 3", `unexpected "<" at 6`},
+		{"close # 1", "unexpected character '#' at 6"},
+		{"1..2 > 1", "invalid number 1..2 at 0"},
+		{"(close > 1", "expected ) at 10, found end of expression"},
+		{"sma(20 > 1", "expected , at 7"},
+		{"sma(20", "missing ) of sma"},
+		{"foo > 1", "unknown variable foo at 0"},
+		{"bar(2) > 1", "unknown function bar at 0"},
+		{"sma() > 1", "sma expects a period, e.g. sma(14)"},
+		{"sma(close) > 1", "sma expects a period"},
+		{"sma(1, 2) > 1", "sma expects a period"},
+		{"sma(0) > 1", "sma period must be a whole number greater than zero"},
+		{"sma(1.5) > 1", "sma period must be a whole number greater than zero"},
+		{"close > 1 && 2", "&& expects boolean operands"},
+		{"(close > 1) + 1 > 0", "+ expects number operands"},
+		{"(close > 1) > 0", "> compares numbers"},
+		{"!close", "! expects a boolean operand"},
+		{"-true", "- expects a number operand"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Parse(tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	e, err := Parse("sma(10) > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Eval(closes(1, 2, 3)); !errors.Is(err, errNoData) {
+		t.Errorf("got error %v, want errNoData", err)
+	}
+	if _, err := e.Eval(closes()); !errors.Is(err, errNoData) {
+		t.Errorf("got error %v on no candles, want errNoData", err)
+	}
+
+	e, err = Parse("close / (open - close) > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Eval(closes(1)); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("got error %v, want division by zero", err)
+	}
+}
+
+func TestLookback(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int
+	}{
+		{"true", 0},
+		{"1 > 0", 0},
+		{"close > 1", 1},
+		{"sma(20) > 1", 20},
+		{"ema(10) > 1", 30},
+		{"rsi(14) < 30", 43},
+		{"change(5) > 2", 6},
+		{"highest(10) > lowest(12)", 12},
+		{"sma(20) > ema(10) && !(rsi(14) > 70)", 43},
+		{"-sma(50) < close", 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := Parse(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Lookback(); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	r := Rule{Name: "cross", Pair: "BTCUSD", Timeframe: "1h", When: "sma(20) > ema(10)", Action: ActionAlert}
+	if err := r.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Lookback() != 30 {
+		t.Errorf("rule lookback is %d, want 30", r.Lookback())
+	}
+}
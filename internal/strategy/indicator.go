@@ -0,0 +1,211 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/georlav/bitstamp-cli/internal/candle"
+)
+
+// series candles an expression is evaluated against, oldest first. The last candle is
+// the one being evaluated, it may still be forming when evaluated on trades
+type series struct {
+	candles []candle.Candle
+}
+
+// last returns the n most recent candles
+func (s *series) last(n int) ([]candle.Candle, error) {
+	if len(s.candles) < n || n == 0 {
+		return nil, errNoData
+	}
+
+	return s.candles[len(s.candles)-n:], nil
+}
+
+// variables values of the most recent candle
+var variables = map[string]func(c candle.Candle) float64{
+	"close":  func(c candle.Candle) float64 { return c.Close },
+	"price":  func(c candle.Candle) float64 { return c.Close },
+	"open":   func(c candle.Candle) float64 { return c.Open },
+	"high":   func(c candle.Candle) float64 { return c.High },
+	"low":    func(c candle.Candle) float64 { return c.Low },
+	"volume": func(c candle.Candle) float64 { return c.Volume },
+}
+
+// indicator computes a value from the most recent candles, lookback returns the number
+// of candles required for a period
+type indicator struct {
+	lookback func(n int) int
+	value    func(candles []candle.Candle, n int) float64
+}
+
+// indicators functions available to expressions, their argument is a period in candles
+var indicators = map[string]indicator{
+	"sma": {
+		lookback: func(n int) int { return n },
+		value:    func(cs []candle.Candle, n int) float64 { return mean(candle.Closes(cs)) },
+	},
+	// ema and rsi are seeded over their first period and smoothed over the next two
+	"ema": {
+		lookback: func(n int) int { return 3 * n },
+		value: func(cs []candle.Candle, n int) float64 {
+			c := candle.Closes(cs)
+			k, v := 2/float64(n+1), mean(c[:n])
+			for _, x := range c[n:] {
+				v = x*k + v*(1-k)
+			}
+			return v
+		},
+	},
+	"rsi": {
+		lookback: func(n int) int { return 3*n + 1 },
+		value: func(cs []candle.Candle, n int) float64 {
+			c := candle.Closes(cs)
+			var gain, loss float64
+			for i := 1; i < len(c); i++ {
+				up, down := math.Max(c[i]-c[i-1], 0), math.Max(c[i-1]-c[i], 0)
+				if i <= n {
+					gain, loss = gain+up/float64(n), loss+down/float64(n)
+					continue
+				}
+				gain = (gain*float64(n-1) + up) / float64(n)
+				loss = (loss*float64(n-1) + down) / float64(n)
+			}
+			if loss == 0 {
+				return 100
+			}
+			return 100 - 100/(1+gain/loss)
+		},
+	},
+	"highest": {
+		lookback: func(n int) int { return n },
+		value: func(cs []candle.Candle, n int) float64 {
+			v := cs[0].High
+			for _, c := range cs {
+				v = math.Max(v, c.High)
+			}
+			return v
+		},
+	},
+	"lowest": {
+		lookback: func(n int) int { return n },
+		value: func(cs []candle.Candle, n int) float64 {
+			v := cs[0].Low
+			for _, c := range cs {
+				v = math.Min(v, c.Low)
+			}
+			return v
+		},
+	},
+	// change percent change of close over n candles
+	"change": {
+		lookback: func(n int) int { return n + 1 },
+		value: func(cs []candle.Candle, n int) float64 {
+			return (cs[len(cs)-1].Close/cs[0].Close - 1) * 100
+		},
+	},
+}
+
+// variable returns the node of a variable or boolean constant
+func variable(t token) (node, error) {
+	switch t.text {
+	case "true":
+		return &constNode{value: 1}, nil
+	case "false":
+		return &constNode{value: 0}, nil
+	}
+
+	if _, ok := variables[t.text]; !ok {
+		return nil, fmt.Errorf("unknown variable %s at %d, variables are %s", t.text, t.pos, names(variables))
+	}
+
+	return &variableNode{name: t.text}, nil
+}
+
+// function returns the node of an indicator call, periods must be whole numbers
+func function(t token, args []token) (node, error) {
+	ind, ok := indicators[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at %d, functions are %s", t.text, t.pos, names(indicators))
+	}
+	if len(args) != 1 || args[0].kind != tokenNumber {
+		return nil, fmt.Errorf("%s expects a period, e.g. %s(14)", t.text, t.text)
+	}
+	n, err := strconv.Atoi(args[0].text)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("%s period must be a whole number greater than zero", t.text)
+	}
+
+	return &indicatorNode{period: n, indicator: ind}, nil
+}
+
+type constNode struct {
+	value float64
+}
+
+func (n *constNode) eval(*series) (float64, error) { return n.value, nil }
+func (n *constNode) kind() kind                    { return kindBool }
+func (n *constNode) lookback() int                 { return 0 }
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(s *series) (float64, error) {
+	cs, err := s.last(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return variables[n.name](cs[0]), nil
+}
+
+func (n *variableNode) kind() kind    { return kindNumber }
+func (n *variableNode) lookback() int { return 1 }
+
+type indicatorNode struct {
+	period    int
+	indicator indicator
+}
+
+func (n *indicatorNode) eval(s *series) (float64, error) {
+	cs, err := s.last(n.lookback())
+	if err != nil {
+		return 0, err
+	}
+
+	return n.indicator.value(cs, n.period), nil
+}
+
+func (n *indicatorNode) kind() kind    { return kindNumber }
+func (n *indicatorNode) lookback() int { return n.indicator.lookback(n.period) }
+
+func mean(v []float64) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+
+	return sum / float64(len(v))
+}
+
+// names returns the sorted keys of a map of functions or variables
+func names(m interface{}) string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]indicator:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]func(c candle.Candle) float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,70 @@
+package strategy
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestIndicators(t *testing.T) {
+	rising := closes(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+
+	tests := []struct {
+		name   string
+		source string
+		series *series
+		want   float64
+	}{
+		{name: "sma", source: "sma(3)", series: rising, want: 9},
+		{name: "sma of all candles", source: "sma(10)", series: rising, want: 5.5},
+		// a linear series is lagged by (n-1)/2 once seeded
+		{name: "ema", source: "ema(2)", series: closes(1, 2, 3, 4, 5, 6), want: 5.5},
+		{name: "ema of the last candles", source: "ema(3)", series: rising, want: 9},
+		{name: "rsi without losses", source: "rsi(3)", series: rising, want: 100},
+		{name: "rsi without gains", source: "rsi(2)", series: closes(7, 6, 5, 4, 3, 2, 1), want: 0},
+		{name: "rsi alternating", source: "rsi(2)", series: closes(1, 2, 1, 2, 1, 2, 1), want: 34.375},
+		{name: "highest", source: "highest(3)", series: closes(5, 1, 9, 3, 2), want: 10},
+		{name: "lowest", source: "lowest(3)", series: closes(5, 1, 9, 3, 2), want: 1},
+		{name: "change", source: "change(2)", series: closes(50, 100, 90, 110), want: 10},
+		{name: "change down", source: "change(1)", series: closes(100, 75), want: -25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.source + " > 0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.root.(*binaryNode).left.eval(tt.series)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndicatorsNoData(t *testing.T) {
+	// every indicator needs its full lookback of candles
+	for name := range indicators {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(name + "(3) > 0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v []float64
+			for i := 1; i <= e.Lookback(); i++ {
+				v = append(v, float64(i))
+			}
+			if _, err := e.Eval(closes(v[1:]...)); !errors.Is(err, errNoData) {
+				t.Errorf("got error %v with %d candles, want errNoData", err, len(v)-1)
+			}
+			if _, err := e.Eval(closes(v...)); err != nil {
+				t.Errorf("got error %v with %d candles", err, len(v))
+			}
+		})
+	}
+}
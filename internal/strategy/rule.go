@@ -0,0 +1,194 @@
+package strategy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+)
+
+// Action what a rule does when its condition becomes true
+type Action string
+
+// Available actions, orders are only sent when real orders are enabled
+const (
+	ActionAlert Action = "alert"
+	ActionPaper Action = "paper"
+	ActionOrder Action = "order"
+)
+
+// Evaluation events of a rule
+const (
+	OnClose = "close"
+	OnTrade = "trade"
+)
+
+// Rule runs an action when its condition becomes true, conditions are evaluated on every
+// closed candle of the timeframe and, with On set to trade, on every trade as well
+type Rule struct {
+	Name string `json:"name"`
+	Pair string `json:"pair"`
+	// Timeframe candle step, 1m, 5m, 15m, 1h, 4h or 1d
+	Timeframe string `json:"timeframe"`
+	// When condition in the rules language, e.g. sma(20) > sma(50) && rsi(14) < 70
+	When string `json:"when"`
+	// On close or trade, defaults to close
+	On     string `json:"on,omitempty"`
+	Action Action `json:"action"`
+	// Side buy or sell and Amount in base currency of paper and real orders
+	Side   string  `json:"side,omitempty"`
+	Amount float64 `json:"amount,omitempty"`
+
+	expr *Expr
+	pair bitstamp.Pair
+	step time.Duration
+}
+
+// DefaultPath returns the location of the rules file under the user config directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "bitstamp-cli-strategies.json"
+	}
+
+	return filepath.Join(dir, "bitstamp-cli", "strategies.json")
+}
+
+// Load reads and compiles the rules at path, a missing file results in no rules
+func Load(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read strategies file, %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse strategies file %s, %w", path, err)
+	}
+
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %s, %w", rules[i].Name, err)
+		}
+		if names[rules[i].Name] {
+			return nil, fmt.Errorf("rule %s is defined more than once", rules[i].Name)
+		}
+		names[rules[i].Name] = true
+	}
+
+	return rules, nil
+}
+
+// compile validates the rule and parses its condition
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return errors.New("rule name is required")
+	}
+
+	found := false
+	for _, p := range bitstamp.GetAllPairs() {
+		if p.String() == strings.ToLower(r.Pair) {
+			r.pair, found = p, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown pair %s", r.Pair)
+	}
+
+	step, err := parseTimeframe(r.Timeframe)
+	if err != nil {
+		return err
+	}
+	r.step = step
+
+	if r.On == "" {
+		r.On = OnClose
+	}
+	if r.On != OnClose && r.On != OnTrade {
+		return fmt.Errorf("unknown evaluation event %s, use close or trade", r.On)
+	}
+
+	switch r.Action {
+	case ActionAlert:
+	case ActionPaper, ActionOrder:
+		if r.Side != "buy" && r.Side != "sell" {
+			return fmt.Errorf("%s rules require a buy or sell side", r.Action)
+		}
+		if r.Amount <= 0 {
+			return fmt.Errorf("%s rules require an amount greater than zero", r.Action)
+		}
+	default:
+		return fmt.Errorf("unknown action %s, use alert, paper or order", r.Action)
+	}
+
+	if r.expr, err = Parse(r.When); err != nil {
+		return fmt.Errorf("invalid condition, %w", err)
+	}
+
+	return nil
+}
+
+// Sell reports whether the orders of the rule are sell orders
+func (r Rule) Sell() bool {
+	return r.Side == "sell"
+}
+
+// Lookback returns the number of candles required to evaluate the condition
+func (r Rule) Lookback() int {
+	return r.expr.Lookback()
+}
+
+// parseTimeframe parses one of the supported candle steps such as 15m, 4h or 1d
+func parseTimeframe(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if strings.HasSuffix(s, "d") {
+		d, err = time.ParseDuration(strings.TrimSuffix(s, "d") + "h")
+		d *= 24
+	}
+
+	for _, tf := range candle.Timeframes {
+		if err == nil && d == tf {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown timeframe %q, use 1m, 5m, 15m, 1h, 4h or 1d", s)
+}
+
+// Paper simulated account of a rule, it starts without funds so its equity is the profit
+type Paper struct {
+	Position float64
+	Cash     float64
+	Fees     float64
+	Trades   int
+}
+
+// Fill applies a paper order filled at price paying a fee rate
+func (p *Paper) Fill(sell bool, amount, price, rate float64) {
+	value := amount * price
+	fee := value * rate
+	if sell {
+		p.Position -= amount
+		p.Cash += value - fee
+	} else {
+		p.Position += amount
+		p.Cash -= value + fee
+	}
+	p.Fees += fee
+	p.Trades++
+}
+
+// Equity returns the value of the account at price
+func (p Paper) Equity(price float64) float64 {
+	return p.Cash + p.Position*price
+}
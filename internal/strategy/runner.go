@@ -0,0 +1,315 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+	"github.com/georlav/bitstamp-cli/internal/guard"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+)
+
+// Source provides historical candles, implemented by candlestore.Store
+type Source interface {
+	Candles(ctx context.Context, p bitstamp.Pair, step time.Duration, from, to time.Time) ([]candle.Candle, error)
+}
+
+// Options of a runner
+type Options struct {
+	// RealOrders allows rules with the order action to send orders
+	RealOrders bool
+	// Fee returns the taker fee rate of a pair paid by paper orders
+	Fee func(p bitstamp.Pair) float64
+	// Decimals returns the counter decimals of a pair
+	Decimals func(p bitstamp.Pair) int
+	// BaseDecimals returns the base decimals of a pair
+	BaseDecimals func(p bitstamp.Pair) int
+	// Signal is called for every action run
+	Signal func(s Signal)
+	// Notify is called with the status of every rule after each change
+	Notify func(statuses []Status)
+}
+
+// Signal an action run by a rule
+type Signal struct {
+	Rule  string
+	Pair  string
+	Price float64
+	Time  time.Time
+	// Text describes the action
+	Text string
+}
+
+// Status state of a rule
+type Status struct {
+	Name      string
+	Pair      string
+	Timeframe string
+	Action    Action
+	// Disabled the rule sends orders while real orders are disabled, Error tells why
+	Disabled bool
+	// Ready the rule has enough candles to be evaluated, Value is its last condition value
+	Ready   bool
+	Value   bool
+	Signals int
+	Last    time.Time
+	// Paper account of paper rules valued at Price
+	Paper Paper
+	Price float64
+	Error string
+}
+
+// PnL returns the profit of the paper account of the rule in quote currency
+func (s Status) PnL() float64 {
+	return s.Paper.Equity(s.Price)
+}
+
+// ruleState candles and evaluation state of a rule, candles are oldest first and the
+// last one may be forming
+type ruleState struct {
+	rule    Rule
+	candles []candle.Candle
+	// closed start of the last candle evaluated as closed
+	closed    time.Time
+	evaluated bool
+	status    Status
+}
+
+// Runner evaluates rules against live trades and runs their actions
+type Runner struct {
+	states []*ruleState
+	source Source
+	trader guard.Trader
+	opts   Options
+	log    *logging.Logger
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+}
+
+// NewRunner creates a runner of rules, rules sending orders are disabled unless real orders
+// are enabled and report it in their status
+func NewRunner(rules []Rule, source Source, trader guard.Trader, log *logging.Logger, opts Options) *Runner {
+	r := Runner{source: source, trader: trader, opts: opts, log: log}
+
+	for _, rule := range rules {
+		st := &ruleState{rule: rule, status: Status{
+			Name:      rule.Name,
+			Pair:      rule.pair.String(),
+			Timeframe: rule.Timeframe,
+			Action:    rule.Action,
+		}}
+		if rule.Action == ActionOrder && !opts.RealOrders {
+			st.status.Disabled, st.status.Error = true, "sends orders while real_orders is off"
+			log.Warn("strategy rule disabled, real orders are off", "rule", rule.Name)
+		}
+		r.states = append(r.states, st)
+	}
+
+	return &r
+}
+
+// Start loads the candles of every rule, conditions already true when loaded do not run actions
+func (r *Runner) Start(ctx context.Context) {
+	for _, st := range r.states {
+		if st.status.Disabled {
+			continue
+		}
+
+		now := time.Now()
+		lookback := st.rule.Lookback() + 1
+		candles, err := r.source.Candles(ctx, st.rule.pair, st.rule.step, now.Add(-st.rule.step*time.Duration(lookback)), now)
+
+		r.mu.Lock()
+		if err != nil {
+			st.status.Error = err.Error()
+			r.log.Error("strategy candles failed", "rule", st.rule.Name, "error", err)
+		} else {
+			st.candles = candle.Merge(candles, st.candles, lookback+1)
+			r.closeCandles(st, now)
+		}
+		r.changed()
+		r.mu.Unlock()
+	}
+}
+
+// Trade updates the candles of the rules of a pair and evaluates them
+func (r *Runner) Trade(p bitstamp.Pair, price, amount float64, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, st := range r.states {
+		if st.rule.pair != p || st.status.Disabled {
+			continue
+		}
+
+		st.candles = candle.Add(st.candles, st.rule.step, price, amount, t, st.rule.Lookback()+2)
+		r.closeCandles(st, t)
+		if st.rule.On == OnTrade {
+			r.evaluate(st, st.candles, t)
+		}
+	}
+	r.changed()
+}
+
+// Tick evaluates the rules whose candle closed without a trade of the next one
+func (r *Runner) Tick(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, st := range r.states {
+		if !st.status.Disabled {
+			r.closeCandles(st, now)
+		}
+	}
+	r.changed()
+}
+
+// Pairs returns the pairs of the enabled rules
+func (r *Runner) Pairs() []string {
+	var pairs []string
+	for _, st := range r.states {
+		if !st.status.Disabled {
+			pairs = append(pairs, st.rule.pair.String())
+		}
+	}
+
+	return pairs
+}
+
+// Close waits for orders being sent
+func (r *Runner) Close() {
+	r.wg.Wait()
+}
+
+// closeCandles evaluates the most recent candle closed at now unless it was already evaluated
+func (r *Runner) closeCandles(st *ruleState, now time.Time) {
+	for i := len(st.candles) - 1; i >= 0; i-- {
+		c := st.candles[i]
+		if c.Time.Add(st.rule.step).After(now) {
+			continue
+		}
+		if c.Time.After(st.closed) {
+			st.closed = c.Time
+			r.evaluate(st, st.candles[:i+1], now)
+		}
+		return
+	}
+}
+
+// evaluate evaluates the condition against candles and runs the action when it becomes true
+func (r *Runner) evaluate(st *ruleState, candles []candle.Candle, now time.Time) {
+	if len(candles) == 0 {
+		return
+	}
+
+	value, err := st.rule.expr.Eval(&series{candles: candles})
+	st.status.Ready = !errors.Is(err, errNoData)
+	if err != nil {
+		if st.status.Ready {
+			st.status.Error = err.Error()
+		}
+		return
+	}
+
+	price := candles[len(candles)-1].Close
+	st.status.Price, st.status.Error = price, ""
+	if value && !st.status.Value && st.evaluated {
+		r.run(st, price, now)
+	}
+	st.status.Value, st.evaluated = value, true
+}
+
+// run runs the action of a rule, must be called holding the lock
+func (r *Runner) run(st *ruleState, price float64, now time.Time) {
+	rule := st.rule
+	st.status.Signals++
+	st.status.Last = now
+
+	s := Signal{Rule: rule.Name, Pair: rule.pair.String(), Price: price, Time: now}
+	amount := strconv.FormatFloat(rule.Amount, 'f', -1, 64)
+	switch rule.Action {
+	case ActionAlert:
+		s.Text = fmt.Sprintf("%s at %s", rule.When, formatPrice(price))
+	case ActionPaper:
+		st.status.Paper.Fill(rule.Sell(), rule.Amount, price, r.opts.Fee(rule.pair))
+		s.Text = fmt.Sprintf("paper %s %s at %s", rule.Side, amount, formatPrice(price))
+	case ActionOrder:
+		s.Text = fmt.Sprintf("sending %s order of %s at market", rule.Side, amount)
+		r.wg.Add(1)
+		go r.send(rule, price)
+	}
+
+	r.log.Info("strategy signal", "rule", rule.Name, "pair", rule.pair, "price", price, "action", s.Text)
+	if r.opts.Signal != nil {
+		r.opts.Signal(s)
+	}
+}
+
+// send sends the instant order of a rule, sell amounts are in base currency and buy amounts are
+// converted to counter currency at price
+func (r *Runner) send(rule Rule, price float64) {
+	defer r.wg.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var (
+		resp *bitstamp.CreateOrderResponse
+		err  error
+	)
+	if rule.Sell() {
+		resp, err = r.trader.CreateSellInstantOrder(ctx, rule.pair, bitstamp.CreateSellInstantOrderRequest{
+			Amount: strconv.FormatFloat(rule.Amount, 'f', r.opts.BaseDecimals(rule.pair), 64),
+		})
+	} else {
+		resp, err = r.trader.CreateBuyInstantOrder(ctx, rule.pair, bitstamp.CreateBuyInstantOrderRequest{
+			Amount: strconv.FormatFloat(rule.Amount*price, 'f', r.opts.Decimals(rule.pair), 64),
+		})
+	}
+
+	s := Signal{Rule: rule.Name, Pair: rule.pair.String(), Price: price, Time: time.Now()}
+	if err != nil {
+		r.log.Error("strategy order failed", "rule", rule.Name, "error", err)
+		s.Text = fmt.Sprintf("%s order failed, %s", rule.Side, err)
+	} else {
+		r.log.Info("strategy order sent", "rule", rule.Name, "order_id", resp.ID)
+		s.Text = fmt.Sprintf("%s order %s sent", rule.Side, resp.ID)
+	}
+
+	r.mu.Lock()
+	for _, st := range r.states {
+		if st.rule.Name == rule.Name && err != nil {
+			st.status.Error = err.Error()
+		}
+	}
+	r.changed()
+	r.mu.Unlock()
+
+	if r.opts.Signal != nil {
+		r.opts.Signal(s)
+	}
+}
+
+// changed notifies the status of every rule, must be called holding the lock
+func (r *Runner) changed() {
+	if r.opts.Notify == nil {
+		return
+	}
+
+	statuses := make([]Status, 0, len(r.states))
+	for _, st := range r.states {
+		statuses = append(statuses, st.status)
+	}
+	r.opts.Notify(statuses)
+}
+
+// formatPrice formats a price without trailing zeros
+func formatPrice(p float64) string {
+	return strings.TrimSuffix(strings.TrimRight(strconv.FormatFloat(p, 'f', 8, 64), "0"), ".")
+}
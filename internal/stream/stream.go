@@ -0,0 +1,237 @@
+package stream
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/apperror"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Handler receives stream messages and connection changes, handlers are called
+// from the goroutine running the stream
+type Handler struct {
+	Message func(m interface{})
+	Error   func(err error)
+	// Connected is called after every successful connection, reconnect is false for the first one
+	Connected func(reconnect bool)
+	// Disconnected is called when the connection is lost
+	Disconnected func()
+}
+
+// Stream keeps a websocket connection subscribed to the channels of the selected
+// pair, reconnecting with backoff when the connection fails
+type Stream struct {
+	channels func(p bitstamp.Pair) []bitstamp.Channel
+	pair     bitstamp.Pair
+	// watched channels subscribed in addition to the ones of the selected pair, by owner
+	watched map[string][]bitstamp.Channel
+	ws      *bitstamp.WebsocketAPI
+	log     *logging.Logger
+	mu      sync.Mutex
+}
+
+// New creates a stream subscribing to the channels returned by channels for the selected pair
+func New(p bitstamp.Pair, channels func(p bitstamp.Pair) []bitstamp.Channel, log *logging.Logger) *Stream {
+	return &Stream{
+		channels: channels,
+		pair:     p,
+		log:      log,
+		watched:  make(map[string][]bitstamp.Channel),
+	}
+}
+
+// Run connects and delivers messages to h until ctx is done, the connection is
+// closed before Run returns
+func (s *Stream) Run(ctx context.Context, h Handler) {
+	backoff := minBackoff
+
+	for reconnect := false; ; reconnect = true {
+		events, err := s.connect()
+		if err != nil {
+			h.Error(err)
+			s.log.Warn("websocket connection failed", "error", err, "retry_in", backoff)
+
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+
+			continue
+		}
+		backoff = minBackoff
+		if reconnect {
+			s.log.Info("websocket reconnected")
+		}
+		h.Connected(reconnect)
+
+		if done := s.consume(ctx, events, h); done {
+			return
+		}
+		s.log.Warn("websocket disconnected")
+		h.Disconnected()
+	}
+}
+
+// SetPair replaces the subscriptions of the previous pair with the ones of p, when not
+// connected subscriptions are made on the next connection
+func (s *Stream) SetPair(ctx context.Context, p bitstamp.Pair) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pair = p
+
+	return s.subscribe(ctx)
+}
+
+// Watch subscribes to channels in addition to the ones of the selected pair, channels
+// replace the ones previously watched by owner
+func (s *Stream) Watch(ctx context.Context, owner string, channels []bitstamp.Channel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if channelNames(channels) == channelNames(s.watched[owner]) {
+		return nil
+	}
+	s.watched[owner] = channels
+
+	return s.subscribe(ctx)
+}
+
+// subscriptions returns the channels of the selected pair followed by the watched ones
+func (s *Stream) subscriptions() []bitstamp.Channel {
+	channels := s.channels(s.pair)
+
+	seen := make(map[bitstamp.Channel]bool, len(channels))
+	for _, c := range channels {
+		seen[c] = true
+	}
+
+	owners := make([]string, 0, len(s.watched))
+	for o := range s.watched {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+
+	for _, o := range owners {
+		for _, c := range s.watched[o] {
+			if !seen[c] {
+				seen[c] = true
+				channels = append(channels, c)
+			}
+		}
+	}
+
+	return channels
+}
+
+func (s *Stream) subscribe(ctx context.Context) error {
+	if s.ws == nil {
+		return nil
+	}
+
+	s.log.Info("websocket unsubscribe", "channels", "all")
+	if err := s.ws.UnSubscribeFromAllChannels(ctx); err != nil {
+		return err
+	}
+
+	channels := s.subscriptions()
+	s.log.Info("websocket subscribe", "channels", channelNames(channels))
+
+	return s.ws.SubscribeToChannels(ctx, channels...)
+}
+
+func (s *Stream) connect() (<-chan bitstamp.WebsocketMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ws, err := bitstamp.NewWebsocketAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	// consuming stops when the connection is closed, cancelling the consume context
+	// instead would leave the client reader sending to a closed channel
+	channels := s.subscriptions()
+	s.log.Info("websocket subscribe", "channels", channelNames(channels))
+	events, err := ws.Consume(context.Background(), channels...)
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	s.ws = ws
+
+	return events, nil
+}
+
+// consume delivers messages until the connection fails or ctx is done, returns true when ctx is done
+func (s *Stream) consume(ctx context.Context, events <-chan bitstamp.WebsocketMessage, h Handler) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			s.close(events)
+			return true
+
+		case e, ok := <-events:
+			if !ok {
+				s.close(events)
+				return false
+			}
+
+			if e.Error != nil {
+				h.Error(e.Error)
+				if apperror.Classify(e.Error) == apperror.KindParse {
+					s.log.Warn("websocket message parse failed", "error", e.Error)
+					s.log.Debug("websocket message", "raw", string(e.RawMessage))
+				}
+
+				// parse errors affect a single message, anything else requires a new connection
+				if apperror.Classify(e.Error) != apperror.KindParse || e.RawMessage == nil {
+					s.close(events)
+					return false
+				}
+
+				continue
+			}
+
+			h.Message(e.Message)
+		}
+	}
+}
+
+// close closes the connection and drains events until the client stops consuming
+func (s *Stream) close(events <-chan bitstamp.WebsocketMessage) {
+	s.mu.Lock()
+	if s.ws != nil {
+		s.ws.Close()
+		s.ws = nil
+	}
+	s.mu.Unlock()
+
+	for range events {
+	}
+}
+
+func channelNames(channels []bitstamp.Channel) string {
+	names := make([]string, 0, len(channels))
+	for _, c := range channels {
+		names = append(names, c.String())
+	}
+
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,165 @@
+package tape
+
+import "time"
+
+// volumeSeconds number of per second volume buckets, covers the longest volume window
+const volumeSeconds = 15 * 60
+
+// Windows running volume windows reported by Volumes
+var Windows = []time.Duration{time.Minute, time.Minute * 5, time.Minute * 15}
+
+// Trade a trade or consecutive trades of the same taker order merged together
+type Trade struct {
+	Time   time.Time
+	Price  float64
+	Amount float64
+	Sell   bool
+	// OrderID id of the taker order, zero when unknown
+	OrderID int64
+	// Count number of merged trades
+	Count int
+}
+
+// Volume buy and sell volume of a window
+type Volume struct {
+	Window time.Duration
+	Buy    float64
+	Sell   float64
+}
+
+type bucket struct {
+	second int64
+	buy    float64
+	sell   float64
+}
+
+// Tape keeps the most recent trades in a fixed size ring buffer and per second
+// buy and sell volume. A tape is never modified, Add and AddAll return a new one, so it
+// can be shared by states and read concurrently
+type Tape struct {
+	trades  []Trade
+	next    int
+	size    int
+	merge   bool
+	buckets [volumeSeconds]bucket
+}
+
+// New creates a tape keeping up to capacity trades, when merge is enabled consecutive
+// trades of the same side and taker order are merged into one
+func New(capacity int, merge bool) *Tape {
+	return &Tape{
+		trades: make([]Trade, capacity),
+		merge:  merge,
+	}
+}
+
+// Add returns a copy of the tape with a trade recorded
+func (t *Tape) Add(tr Trade) *Tape {
+	return t.AddAll(tr)
+}
+
+// AddAll returns a copy of the tape with trades recorded in order, the tape is copied
+// once however many trades are added
+func (t *Tape) AddAll(trades ...Trade) *Tape {
+	c := *t
+	c.trades = make([]Trade, len(t.trades))
+	copy(c.trades, t.trades)
+
+	for _, tr := range trades {
+		c.add(tr)
+	}
+
+	return &c
+}
+
+// add records a trade in place, only called on a tape not shared yet
+func (t *Tape) add(tr Trade) {
+	t.addVolume(tr)
+
+	if tr.Count == 0 {
+		tr.Count = 1
+	}
+
+	if last := t.last(); last != nil && t.merge && tr.OrderID != 0 && last.OrderID == tr.OrderID && last.Sell == tr.Sell {
+		amount := last.Amount + tr.Amount
+		if amount > 0 {
+			last.Price = (last.Price*last.Amount + tr.Price*tr.Amount) / amount
+		}
+		last.Amount = amount
+		last.Time = tr.Time
+		last.Count += tr.Count
+
+		return
+	}
+
+	if len(t.trades) == 0 {
+		return
+	}
+
+	t.trades[t.next] = tr
+	t.next = (t.next + 1) % len(t.trades)
+	if t.size < len(t.trades) {
+		t.size++
+	}
+}
+
+// Recent returns up to n trades starting from the most recent one
+func (t *Tape) Recent(n int) []Trade {
+	if n > t.size {
+		n = t.size
+	}
+
+	result := make([]Trade, 0, n)
+	for i := 1; i <= n; i++ {
+		result = append(result, t.trades[(t.next-i+len(t.trades))%len(t.trades)])
+	}
+
+	return result
+}
+
+// Volumes returns buy and sell volume of each window ending at now
+func (t *Tape) Volumes(now time.Time) []Volume {
+	result := make([]Volume, 0, len(Windows))
+	for _, w := range Windows {
+		v := Volume{Window: w}
+		from := now.Unix() - int64(w.Seconds())
+
+		for i := range t.buckets {
+			b := t.buckets[i]
+			if b.second > from && b.second <= now.Unix() {
+				v.Buy += b.buy
+				v.Sell += b.sell
+			}
+		}
+
+		result = append(result, v)
+	}
+
+	return result
+}
+
+func (t *Tape) last() *Trade {
+	if t.size == 0 {
+		return nil
+	}
+
+	return &t.trades[(t.next-1+len(t.trades))%len(t.trades)]
+}
+
+func (t *Tape) addVolume(tr Trade) {
+	if tr.Time.IsZero() {
+		return
+	}
+
+	sec := tr.Time.Unix()
+	b := &t.buckets[sec%volumeSeconds]
+	if b.second != sec {
+		*b = bucket{second: sec}
+	}
+
+	if tr.Sell {
+		b.sell += tr.Amount
+	} else {
+		b.buy += tr.Amount
+	}
+}
@@ -0,0 +1,174 @@
+package tape
+
+import (
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestAddMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		merge  bool
+		trades []Trade
+		want   []Trade
+	}{
+		{
+			name:  "same taker order and side are merged",
+			merge: true,
+			trades: []Trade{
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7},
+				{Time: t0.Add(time.Second), Price: 103, Amount: 2, OrderID: 7},
+			},
+			want: []Trade{{Time: t0.Add(time.Second), Price: 102, Amount: 3, OrderID: 7, Count: 2}},
+		},
+		{
+			name:  "merging disabled",
+			merge: false,
+			trades: []Trade{
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7},
+				{Time: t0, Price: 103, Amount: 2, OrderID: 7},
+			},
+			want: []Trade{
+				{Time: t0, Price: 103, Amount: 2, OrderID: 7, Count: 1},
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7, Count: 1},
+			},
+		},
+		{
+			name:  "different orders",
+			merge: true,
+			trades: []Trade{
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7},
+				{Time: t0, Price: 101, Amount: 1, OrderID: 8},
+			},
+			want: []Trade{
+				{Time: t0, Price: 101, Amount: 1, OrderID: 8, Count: 1},
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7, Count: 1},
+			},
+		},
+		{
+			name:  "different sides",
+			merge: true,
+			trades: []Trade{
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7},
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7, Sell: true},
+			},
+			want: []Trade{
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7, Sell: true, Count: 1},
+				{Time: t0, Price: 100, Amount: 1, OrderID: 7, Count: 1},
+			},
+		},
+		{
+			name:  "unknown orders are never merged",
+			merge: true,
+			trades: []Trade{
+				{Time: t0, Price: 100, Amount: 1},
+				{Time: t0, Price: 100, Amount: 1},
+			},
+			want: []Trade{
+				{Time: t0, Price: 100, Amount: 1, Count: 1},
+				{Time: t0, Price: 100, Amount: 1, Count: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := New(10, tt.merge)
+			for _, tr := range tt.trades {
+				tp = tp.Add(tr)
+			}
+
+			got := tp.Recent(10)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("trade %d got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAddReturnsCopy(t *testing.T) {
+	a := New(2, true).Add(Trade{Time: t0, Price: 100, Amount: 1, OrderID: 7})
+	b := a.Add(Trade{Time: t0, Price: 100, Amount: 1, OrderID: 7})
+	c := b.Add(Trade{Time: t0, Price: 90, Amount: 1, OrderID: 8})
+
+	if got := a.Recent(10); len(got) != 1 || got[0].Amount != 1 {
+		t.Errorf("first tape changed to %+v", got)
+	}
+	if got := b.Recent(10); len(got) != 1 || got[0].Amount != 2 {
+		t.Errorf("merged tape changed to %+v", got)
+	}
+	if got := c.Recent(10); len(got) != 2 {
+		t.Errorf("got %d trades, want 2", len(got))
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	tp := New(3, false)
+	for i := 1; i <= 5; i++ {
+		tp = tp.Add(Trade{Time: t0, Price: float64(i), Amount: 1})
+	}
+
+	got := tp.Recent(10)
+	if len(got) != 3 || got[0].Price != 5 || got[1].Price != 4 || got[2].Price != 3 {
+		t.Errorf("got %+v, want prices 5, 4 and 3", got)
+	}
+	if got := tp.Recent(1); len(got) != 1 || got[0].Price != 5 {
+		t.Errorf("got %+v, want the most recent trade", got)
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	tp := New(10, false)
+	tp = tp.Add(Trade{Time: t0, Amount: 1})
+	tp = tp.Add(Trade{Time: t0.Add(time.Minute * 3), Amount: 2, Sell: true})
+	tp = tp.Add(Trade{Time: t0.Add(time.Minute * 10), Amount: 4})
+
+	want := []Volume{
+		{Window: time.Minute, Buy: 4},
+		{Window: time.Minute * 5, Buy: 4},
+		{Window: time.Minute * 15, Buy: 5, Sell: 2},
+	}
+	got := tp.Volumes(t0.Add(time.Minute * 10))
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("window %s got %+v, want %+v", want[i].Window, got[i], want[i])
+		}
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	trades := []Trade{
+		{Time: t0, Price: 100, Amount: 1, OrderID: 7},
+		{Time: t0, Price: 100, Amount: 1, OrderID: 7},
+		{Time: t0, Price: 90, Amount: 1, OrderID: 8},
+		{Time: t0.Add(time.Second), Price: 80, Amount: 2, Sell: true},
+	}
+
+	base := New(2, true)
+	got := base.AddAll(trades...)
+
+	want := base
+	for _, tr := range trades {
+		want = want.Add(tr)
+	}
+
+	if g, w := got.Recent(10), want.Recent(10); len(g) != len(w) || g[0] != w[0] || g[1] != w[1] {
+		t.Errorf("got %+v, want %+v", g, w)
+	}
+	if g, w := got.Volumes(t0.Add(time.Second)), want.Volumes(t0.Add(time.Second)); g[0] != w[0] {
+		t.Errorf("got volume %+v, want %+v", g[0], w[0])
+	}
+	if len(base.Recent(10)) != 0 {
+		t.Error("base tape was modified")
+	}
+}
@@ -0,0 +1,224 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/private"
+)
+
+const (
+	// pageSize transactions requested per page, the maximum allowed
+	pageSize = 1000
+	// maxOffset largest offset accepted by bitstamp
+	maxOffset = 200000
+	// timeLayout layout of transaction datetimes, in UTC
+	timeLayout = "2006-01-02 15:04:05"
+)
+
+// Kind direction of a transfer
+type Kind string
+
+const (
+	KindDeposit    Kind = "deposit"
+	KindWithdrawal Kind = "withdrawal"
+	// KindIOU a ripple IOU transaction, only listed when requested
+	KindIOU Kind = "iou"
+)
+
+// Transfer a cryptocurrency deposit, withdrawal or ripple IOU transaction
+type Transfer struct {
+	Time     time.Time `json:"time"`
+	Kind     Kind      `json:"kind"`
+	Currency string    `json:"currency"`
+	Amount   string    `json:"amount"`
+	Address  string    `json:"address"`
+	TxID     string    `json:"txid"`
+}
+
+// Lister retrieves crypto transactions, implemented by profile.Account
+type Lister interface {
+	GetCryptoTransactions(ctx context.Context, r bitstamp.GetCryptoTransactionsRequest) (*private.CryptoTransactions, error)
+}
+
+// Filter selects transfers, zero fields match everything
+type Filter struct {
+	Currency string
+	Kind     Kind
+	// From inclusive and To exclusive bounds of the transfer time
+	From time.Time
+	To   time.Time
+}
+
+// Match reports whether a transfer is selected by the filter
+func (f Filter) Match(t Transfer) bool {
+	switch {
+	case f.Currency != "" && !strings.EqualFold(f.Currency, t.Currency):
+		return false
+	case f.Kind != "" && f.Kind != t.Kind:
+		return false
+	case !f.From.IsZero() && t.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !t.Time.Before(f.To):
+		return false
+	}
+
+	return true
+}
+
+// Fetch retrieves every page of deposits and withdrawals, most recent first. Ripple IOU
+// transactions are included when ious is set
+func Fetch(ctx context.Context, api Lister, ious bool) ([]Transfer, error) {
+	var transfers []Transfer
+
+	for offset := int64(0); offset <= maxOffset; offset += pageSize {
+		page, err := fetchPage(ctx, api, offset, ious)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, page...)
+
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	sort.SliceStable(transfers, func(i, j int) bool { return transfers[i].Time.After(transfers[j].Time) })
+
+	return transfers, nil
+}
+
+// Latest retrieves the most recent page of deposits and withdrawals, most recent first
+func Latest(ctx context.Context, api Lister, ious bool) ([]Transfer, error) {
+	transfers, err := fetchPage(ctx, api, 0, ious)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(transfers, func(i, j int) bool { return transfers[i].Time.After(transfers[j].Time) })
+
+	return transfers, nil
+}
+
+// Merge adds the latest transfers to known ones, both most recent first. A latest transfer
+// replaces the known one of the same time, kind, currency, amount and address, so a
+// transaction id assigned since is kept
+func Merge(latest, known []Transfer) []Transfer {
+	seen := make(map[Transfer]bool, len(latest))
+	for _, t := range latest {
+		seen[identity(t)] = true
+	}
+
+	merged := append([]Transfer(nil), latest...)
+	for _, t := range known {
+		if !seen[identity(t)] {
+			merged = append(merged, t)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Time.After(merged[j].Time) })
+
+	return merged
+}
+
+// identity returns a transfer without the fields that may change after it is listed
+func identity(t Transfer) Transfer {
+	t.TxID = ""
+	t.Time = t.Time.UTC()
+
+	return t
+}
+
+// fetchPage retrieves the page of deposits and withdrawals starting at offset
+func fetchPage(ctx context.Context, api Lister, offset int64, ious bool) ([]Transfer, error) {
+	resp, err := api.GetCryptoTransactions(ctx, bitstamp.GetCryptoTransactionsRequest{
+		Limit:       pageSize,
+		Offset:      offset,
+		IncludeIOUS: ious,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve crypto transactions, %w", err)
+	}
+
+	transfers := make([]Transfer, 0, len(resp.Deposits)+len(resp.Withdrawals)+len(resp.RippleIOUTransactions))
+	for _, group := range []struct {
+		kind         Kind
+		transactions []private.CryptoTransaction
+	}{
+		{KindDeposit, resp.Deposits},
+		{KindWithdrawal, resp.Withdrawals},
+		{KindIOU, resp.RippleIOUTransactions},
+	} {
+		for _, t := range group.transactions {
+			transfers = append(transfers, Transfer{
+				Time:     parseTime(t.Datetime),
+				Kind:     group.kind,
+				Currency: strings.ToUpper(t.Currency),
+				Amount:   t.Amount,
+				Address:  t.DestinationAddress,
+				TxID:     t.TXID,
+			})
+		}
+	}
+
+	return transfers, nil
+}
+
+// Select returns the transfers matching a filter
+func Select(transfers []Transfer, f Filter) []Transfer {
+	var selected []Transfer
+	for _, t := range transfers {
+		if f.Match(t) {
+			selected = append(selected, t)
+		}
+	}
+
+	return selected
+}
+
+// WriteCSV writes transfers as csv with a header row
+func WriteCSV(w io.Writer, transfers []Transfer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "kind", "currency", "amount", "address", "txid"}); err != nil {
+		return err
+	}
+
+	for _, t := range transfers {
+		record := []string{t.Time.Format(time.RFC3339), string(t.Kind), t.Currency, t.Amount, t.Address, t.TxID}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteJSON writes transfers as an indented json array
+func WriteJSON(w io.Writer, transfers []Transfer) error {
+	if transfers == nil {
+		transfers = []Transfer{}
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	return e.Encode(transfers)
+}
+
+// parseTime parses a transaction datetime, bitstamp may append fractional seconds
+func parseTime(s string) time.Time {
+	if i := strings.Index(s, "."); i > 0 {
+		s = s[:i]
+	}
+
+	t, _ := time.Parse(timeLayout, s)
+
+	return t
+}
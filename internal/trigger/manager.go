@@ -0,0 +1,622 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/guard"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+)
+
+const (
+	// retention time finished triggers are kept for
+	retention = time.Hour * 24 * 7
+	// peakSaveInterval minimum time between saves caused only by trailing stop peaks
+	peakSaveInterval = time.Second * 5
+)
+
+// Account sends the orders of a profile and reads the status of its entry orders,
+// implemented by profile.Account
+type Account interface {
+	guard.Trader
+	GetOrderStatus(ctx context.Context, r bitstamp.GetOrderStatusRequest) (*bitstamp.GetOrderStatusResponse, error)
+}
+
+// Manager watches traded prices and sends the orders of triggers whose condition is
+// met. Triggers are persisted so pending ones survive restarts. It is safe for concurrent use
+type Manager struct {
+	path     string
+	account  func(ctx context.Context, profile string) (Account, error)
+	log      *logging.Logger
+	notify   func(triggers []Trigger)
+	triggers []Trigger
+	seq      int
+	saved    time.Time
+	// version of the last snapshot taken and of the last one written
+	version   int
+	published int
+	mu        sync.Mutex
+	// publishMu orders writes of the triggers file and notifications, both happen
+	// without holding mu so trades are not blocked by them
+	publishMu sync.Mutex
+}
+
+// snapshot triggers to persist and notify
+type snapshot struct {
+	version  int
+	triggers []Trigger
+}
+
+// DefaultPath returns the location of the triggers file under the user config directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "bitstamp-cli-triggers.json"
+	}
+
+	return filepath.Join(dir, "bitstamp-cli", "triggers.json")
+}
+
+// Load creates a manager with the triggers persisted at path, profiles are the configured
+// profile names and account returns the account orders of a profile are sent with. Pending
+// triggers of profiles that no longer exist are orphaned. Notify is called with every
+// trigger after each change
+func Load(path string, profiles []string, account func(ctx context.Context, profile string) (Account, error), log *logging.Logger, notify func(triggers []Trigger)) (*Manager, error) {
+	m := Manager{
+		path:    path,
+		account: account,
+		log:     log,
+		notify:  notify,
+	}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return &m, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read triggers file, %w", err)
+	}
+
+	var triggers []Trigger
+	if err := json.Unmarshal(b, &triggers); err != nil {
+		return nil, fmt.Errorf("failed to parse triggers file %s, %w", path, err)
+	}
+
+	now := time.Now()
+	for _, t := range triggers {
+		if !t.Pending() && now.Sub(t.Updated) > retention {
+			continue
+		}
+
+		// an order may have been sent before exiting, it is never sent twice
+		if t.Status == StatusFired && t.OrderID == "" && t.Error == "" {
+			t.Status, t.Error = StatusFailed, "exited before the order was confirmed, check open orders"
+		}
+		// bracket triggers are added before their entry order is sent
+		if t.Status == StatusWaiting && t.EntryOrderID == "" {
+			t.Status, t.Error = StatusFailed, "exited before the entry order was confirmed, check open orders"
+		}
+		// orders are never sent with the account of another profile
+		if t.Pending() && !hasProfile(profiles, t.Profile) {
+			t.Status, t.Updated = StatusOrphaned, now
+			t.Error = fmt.Sprintf("profile %q no longer exists", t.Profile)
+			log.Warn("trigger orphaned", "id", t.ID, "profile", t.Profile)
+		}
+		m.triggers = append(m.triggers, t)
+
+		if id, err := strconv.Atoi(t.ID); err == nil && id > m.seq {
+			m.seq = id
+		}
+	}
+
+	return &m, nil
+}
+
+// Start persists and notifies the current triggers
+func (m *Manager) Start() {
+	m.mu.Lock()
+	s := m.snapshot()
+	m.mu.Unlock()
+
+	if err := m.publish(s); err != nil {
+		m.log.Error("failed to save triggers", "error", err)
+	}
+}
+
+// Bracket sends a buy limit entry order using the account of a profile and adds take
+// profit and stop loss triggers of the bought amount, armed when the entry order fills
+// and cancelling each other. The triggers are added and their pair watched before the
+// entry order is sent so no fill is missed
+func (m *Manager) Bracket(ctx context.Context, profile string, p bitstamp.Pair, amount string, entry, take, stop float64) ([]Trigger, error) {
+	if take <= entry || stop >= entry {
+		return nil, errors.New("bracket take profit must be above and stop loss below the entry price")
+	}
+
+	acc, err := m.account(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	group := m.Group()
+	var triggers []Trigger
+	for _, t := range []Trigger{
+		{Profile: profile, Pair: p.String(), Kind: KindTakeProfit, Amount: amount, Price: take, Group: group, Status: StatusWaiting},
+		{Profile: profile, Pair: p.String(), Kind: KindStopLoss, Amount: amount, Price: stop, Group: group, Status: StatusWaiting},
+	} {
+		t, err := m.Add(t)
+		if err != nil {
+			m.abandon(group, StatusCancelled, "")
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+
+	resp, err := acc.CreateBuyLimitOrder(ctx, p, bitstamp.CreateBuyLimitOrderRequest{
+		Amount: amount,
+		Price:  strconv.FormatFloat(entry, 'f', -1, 64),
+	})
+	if err != nil {
+		m.abandon(group, StatusFailed, err.Error())
+		return nil, fmt.Errorf("failed to send bracket entry order, %w", err)
+	}
+
+	m.mu.Lock()
+	for i := range triggers {
+		triggers[i].EntryOrderID = resp.ID
+	}
+	for i := range m.triggers {
+		if t := &m.triggers[i]; t.Group == group && t.Status == StatusWaiting {
+			t.EntryOrderID, t.Updated = resp.ID, time.Now()
+		}
+	}
+	s := m.snapshot()
+	m.mu.Unlock()
+	m.log.Info("bracket entry order sent", "profile", profile, "group", group, "entry_order_id", resp.ID)
+
+	// the triggers stay pending in memory when they can not be saved
+	if err := m.publish(s); err != nil {
+		return triggers, fmt.Errorf("bracket entry order %s sent but its triggers were not saved, %w", resp.ID, err)
+	}
+
+	return triggers, nil
+}
+
+// abandon ends the pending triggers of a bracket whose entry order was not sent
+func (m *Manager) abandon(group string, status Status, reason string) {
+	m.mu.Lock()
+	for i := range m.triggers {
+		if t := &m.triggers[i]; t.Group == group && t.Pending() {
+			t.Status, t.Error, t.Updated = status, reason, time.Now()
+		}
+	}
+	s := m.snapshot()
+	m.mu.Unlock()
+
+	if err := m.publish(s); err != nil {
+		m.log.Error("failed to save triggers", "error", err)
+	}
+}
+
+// CheckEntries reads the status of open bracket entry orders with the accounts of their
+// profiles and arms their triggers for fills missed on the stream, such as fills while
+// the application was closed. Triggers of entry orders closed without fills are cancelled
+func (m *Manager) CheckEntries(ctx context.Context) {
+	type entry struct {
+		profile, id string
+	}
+
+	m.mu.Lock()
+	var entries []entry
+	seen := make(map[entry]bool)
+	for _, t := range m.triggers {
+		e := entry{profile: t.Profile, id: t.EntryOrderID}
+		if t.Pending() && t.EntryOrderID != "" && !t.EntryClosed && !seen[e] {
+			seen[e] = true
+			entries = append(entries, e)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, e := range entries {
+		acc, err := m.account(ctx, e.profile)
+		if err != nil {
+			m.log.Warn("failed to read entry order status", "profile", e.profile, "entry_order_id", e.id, "error", err)
+			continue
+		}
+		status, err := acc.GetOrderStatus(ctx, bitstamp.GetOrderStatusRequest{ID: e.id})
+		if err != nil {
+			m.log.Warn("failed to read entry order status", "profile", e.profile, "entry_order_id", e.id, "error", err)
+			continue
+		}
+		m.entry(e.profile, e.id, status)
+	}
+}
+
+// entry applies the status of an entry order of a profile to its triggers
+func (m *Manager) entry(profile, id string, status *bitstamp.GetOrderStatusResponse) {
+	closed := status.Status != "Open"
+	remaining, err := strconv.ParseFloat(status.AmountRemaining, 64)
+	if err != nil && status.Status != "Finished" {
+		return
+	}
+
+	m.mu.Lock()
+
+	var changed bool
+	for i := range m.triggers {
+		t := &m.triggers[i]
+		if !t.Pending() || t.Profile != profile || t.EntryOrderID != id {
+			continue
+		}
+
+		// finished orders are filled completely
+		amount, _ := strconv.ParseFloat(t.Amount, 64)
+		filled := amount
+		if err == nil {
+			filled = amount - remaining
+		}
+
+		if t.fill(filled) {
+			t.Updated, changed = time.Now(), true
+			m.log.Info("trigger entry filled", "id", t.ID, "entry_order_id", id, "filled", t.Filled, "status", t.Status)
+		}
+		if closed {
+			t.EntryClosed, t.Updated, changed = true, time.Now(), true
+			if t.Filled <= 0 {
+				t.Status, t.Error = StatusCancelled, fmt.Sprintf("entry order %s closed without fills", id)
+				m.log.Info("trigger cancelled", "id", t.ID, "entry_order_id", id, "entry_status", status.Status)
+			}
+		}
+	}
+
+	if !changed {
+		m.mu.Unlock()
+		return
+	}
+	s := m.snapshot()
+	m.mu.Unlock()
+
+	if err := m.publish(s); err != nil {
+		m.log.Error("failed to save triggers", "error", err)
+	}
+}
+
+// Add stores a new trigger and returns it
+func (m *Manager) Add(t Trigger) (Trigger, error) {
+	if err := t.Validate(); err != nil {
+		return t, err
+	}
+
+	m.mu.Lock()
+
+	m.seq++
+	t.ID = strconv.Itoa(m.seq)
+	t.Created, t.Updated = time.Now(), time.Now()
+	if t.Status == "" {
+		t.Status = StatusActive
+		if t.EntryOrderID != "" {
+			t.Status = StatusWaiting
+		}
+	}
+	m.triggers = append(m.triggers, t)
+	s := m.snapshot()
+	m.mu.Unlock()
+	m.log.Info("trigger added", "id", t.ID, "profile", t.Profile, "pair", t.Pair, "kind", t.Kind, "amount", t.Amount,
+		"price", t.Price, "trail", t.Trail, "group", t.Group, "entry_order_id", t.EntryOrderID)
+
+	return t, m.publish(s)
+}
+
+// Group returns a new group id for one-cancels-other triggers
+func (m *Manager) Group() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seq++
+
+	return "g" + strconv.Itoa(m.seq)
+}
+
+// Cancel cancels a pending trigger
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	for i := range m.triggers {
+		if m.triggers[i].ID != id {
+			continue
+		}
+		if !m.triggers[i].Pending() {
+			m.mu.Unlock()
+			return fmt.Errorf("trigger %s is %s", id, m.triggers[i].Status)
+		}
+
+		m.triggers[i].Status, m.triggers[i].Updated = StatusCancelled, time.Now()
+		s := m.snapshot()
+		m.mu.Unlock()
+		m.log.Info("trigger cancelled", "id", id)
+
+		return m.publish(s)
+	}
+	m.mu.Unlock()
+
+	return fmt.Errorf("unknown trigger %s", id)
+}
+
+// Pairs returns the pairs of pending triggers
+func (m *Manager) Pairs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var pairs []string
+	for _, t := range m.triggers {
+		if t.Pending() && !seen[t.Pair] {
+			seen[t.Pair] = true
+			pairs = append(pairs, t.Pair)
+		}
+	}
+	sort.Strings(pairs)
+
+	return pairs
+}
+
+// Trade evaluates the triggers of a pair against a trade of amount, buyOrderID and sellOrderID
+// are the orders matched by the trade. Orders of fired triggers are sent in the background.
+// Changes are saved and notified once the lock is released
+func (m *Manager) Trade(ctx context.Context, p bitstamp.Pair, price, amount float64, buyOrderID, sellOrderID int64) {
+	m.mu.Lock()
+
+	var (
+		fired   []int
+		changed bool
+		peaked  bool
+		now     = time.Now()
+	)
+	for i := range m.triggers {
+		t := &m.triggers[i]
+		if t.Pair != p.String() {
+			continue
+		}
+
+		// fills of the entry order arm the trigger for the filled amount
+		if id, _ := strconv.ParseInt(t.EntryOrderID, 10, 64); id != 0 && t.Pending() && (id == buyOrderID || id == sellOrderID) {
+			waiting := t.Status == StatusWaiting
+			if t.fill(t.Filled + amount) {
+				t.Updated, changed = now, true
+				m.log.Info("trigger entry filled", "id", t.ID, "entry_order_id", t.EntryOrderID, "filled", t.Filled)
+			}
+			if waiting && t.Status == StatusActive {
+				m.log.Info("trigger armed", "id", t.ID, "entry_order_id", t.EntryOrderID)
+				continue
+			}
+		}
+
+		if t.Status == StatusActive {
+			peak := t.Peak
+			if t.observe(price) {
+				t.Status, t.Updated, changed = StatusFired, now, true
+				fired = append(fired, i)
+				continue
+			}
+			peaked = peaked || t.Peak != peak
+		}
+	}
+
+	// one-cancels-other, the first trigger of a group to fire cancels the rest
+	for _, i := range fired {
+		t := m.triggers[i]
+		if t.Status != StatusFired || t.Group == "" {
+			continue
+		}
+
+		for j := range m.triggers {
+			o := &m.triggers[j]
+			if j == i || o.Group != t.Group {
+				continue
+			}
+
+			// triggers of the group fired by the same trade are cancelled before sending their orders
+			if o.Pending() || (o.Status == StatusFired && contains(fired, j)) {
+				o.Status, o.Updated = StatusCancelled, now
+				m.log.Info("trigger cancelled by group", "id", o.ID, "group", t.Group)
+			}
+		}
+	}
+
+	var send []Trigger
+	for _, i := range fired {
+		if t := m.triggers[i]; t.Status == StatusFired {
+			send = append(send, t)
+		}
+	}
+
+	save := changed || (peaked && now.Sub(m.saved) >= peakSaveInterval)
+	var s snapshot
+	if save {
+		s = m.snapshot()
+	}
+	m.mu.Unlock()
+
+	// fired triggers are saved before their orders are sent, a restart never sends them twice
+	if save {
+		if err := m.publish(s); err != nil {
+			m.log.Error("failed to save triggers", "error", err)
+		}
+	}
+
+	for _, t := range send {
+		m.log.Info("trigger fired", "id", t.ID, "profile", t.Profile, "pair", t.Pair, "kind", t.Kind, "price", price, "level", t.Level())
+		go m.send(ctx, p, t)
+	}
+}
+
+// Triggers returns a copy of every trigger, pending ones first
+func (m *Manager) Triggers() []Trigger {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return sorted(m.triggers)
+}
+
+// Close saves trailing stop peaks
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	s := m.snapshot()
+	m.mu.Unlock()
+
+	m.publishMu.Lock()
+	defer m.publishMu.Unlock()
+
+	return m.save(s.triggers)
+}
+
+// send sends the instant sell order of a fired trigger using the account of its profile,
+// the rest of an open entry order is cancelled first so it does not fill without a trigger
+func (m *Manager) send(ctx context.Context, p bitstamp.Pair, t Trigger) {
+	acc, err := m.account(ctx, t.Profile)
+
+	var resp *bitstamp.CreateOrderResponse
+	if err == nil {
+		if t.EntryOrderID != "" && !t.EntryClosed {
+			t = m.closeEntry(ctx, acc, t)
+		}
+		resp, err = acc.CreateSellInstantOrder(ctx, p, bitstamp.CreateSellInstantOrderRequest{Amount: t.SellAmount()})
+	}
+
+	m.mu.Lock()
+	for i := range m.triggers {
+		if m.triggers[i].ID != t.ID {
+			continue
+		}
+
+		m.triggers[i].Updated = time.Now()
+		switch {
+		case err != nil:
+			m.triggers[i].Status, m.triggers[i].Error = StatusFailed, err.Error()
+			m.log.Error("trigger order failed", "id", t.ID, "error", err)
+		case resp != nil:
+			m.triggers[i].OrderID = resp.ID
+			m.log.Info("trigger order sent", "id", t.ID, "order_id", resp.ID)
+		}
+	}
+	s := m.snapshot()
+	m.mu.Unlock()
+
+	if err := m.publish(s); err != nil {
+		m.log.Error("failed to save triggers", "error", err)
+	}
+}
+
+// closeEntry cancels the entry order of a fired trigger and records its final filled amount
+func (m *Manager) closeEntry(ctx context.Context, acc Account, t Trigger) Trigger {
+	if _, err := acc.CancelOrder(ctx, bitstamp.CancelOrderRequest{ID: t.EntryOrderID}); err != nil {
+		m.log.Warn("failed to cancel entry order", "id", t.ID, "entry_order_id", t.EntryOrderID, "error", err)
+	}
+
+	status, err := acc.GetOrderStatus(ctx, bitstamp.GetOrderStatusRequest{ID: t.EntryOrderID})
+	if err != nil {
+		m.log.Warn("failed to read entry order status", "entry_order_id", t.EntryOrderID, "error", err)
+		return t
+	}
+	if remaining, err := strconv.ParseFloat(status.AmountRemaining, 64); err == nil {
+		amount, _ := strconv.ParseFloat(t.Amount, 64)
+		t.fill(amount - remaining)
+	}
+	t.EntryClosed = true
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.triggers {
+		if m.triggers[i].ID == t.ID {
+			m.triggers[i].Filled, m.triggers[i].EntryClosed = t.Filled, true
+		}
+	}
+
+	return t
+}
+
+// snapshot copies the triggers to publish, must be called holding the lock
+func (m *Manager) snapshot() snapshot {
+	m.version++
+	m.saved = time.Now()
+
+	return snapshot{version: m.version, triggers: append([]Trigger(nil), m.triggers...)}
+}
+
+// publish persists a snapshot and notifies its triggers, it must be called without
+// holding the lock. Snapshots older than the last published one are dropped
+func (m *Manager) publish(s snapshot) error {
+	m.publishMu.Lock()
+	defer m.publishMu.Unlock()
+
+	if s.version <= m.published {
+		return nil
+	}
+	m.published = s.version
+
+	err := m.save(s.triggers)
+	if m.notify != nil {
+		m.notify(sorted(s.triggers))
+	}
+
+	return err
+}
+
+func (m *Manager) save(triggers []Trigger) error {
+	b, err := json.MarshalIndent(triggers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create triggers directory, %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write triggers file, %w", err)
+	}
+
+	return os.Rename(tmp, m.path)
+}
+
+// sorted returns a copy of triggers, pending ones first
+func sorted(triggers []Trigger) []Trigger {
+	triggers = append([]Trigger(nil), triggers...)
+	sort.SliceStable(triggers, func(i, j int) bool {
+		if triggers[i].Pending() != triggers[j].Pending() {
+			return triggers[i].Pending()
+		}
+		return triggers[i].Updated.After(triggers[j].Updated)
+	})
+
+	return triggers
+}
+
+func contains(s []int, v int) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasProfile(profiles []string, name string) bool {
+	for _, p := range profiles {
+		if p == name {
+			return true
+		}
+	}
+
+	return false
+}
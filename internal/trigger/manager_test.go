@@ -0,0 +1,178 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+)
+
+// fakeAccount records the sell orders sent with it
+type fakeAccount struct {
+	sells []string
+	mu    sync.Mutex
+}
+
+func (f *fakeAccount) CreateBuyLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return &bitstamp.CreateOrderResponse{ID: "100"}, nil
+}
+
+func (f *fakeAccount) CreateBuyInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateBuyInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeAccount) CreateSellLimitOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellLimitOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeAccount) CreateSellInstantOrder(ctx context.Context, p bitstamp.Pair, r bitstamp.CreateSellInstantOrderRequest) (*bitstamp.CreateOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sells = append(f.sells, r.Amount)
+
+	return &bitstamp.CreateOrderResponse{ID: "200"}, nil
+}
+
+func (f *fakeAccount) CancelOrder(ctx context.Context, r bitstamp.CancelOrderRequest) (*bitstamp.CancelOrderResponse, error) {
+	return &bitstamp.CancelOrderResponse{}, nil
+}
+
+func (f *fakeAccount) GetOrderStatus(ctx context.Context, r bitstamp.GetOrderStatusRequest) (*bitstamp.GetOrderStatusResponse, error) {
+	return &bitstamp.GetOrderStatusResponse{Status: "Open", AmountRemaining: "1"}, nil
+}
+
+func (f *fakeAccount) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.sells...)
+}
+
+// load creates a manager of the main and savings profiles with the triggers file at path
+func load(t *testing.T, path string, accounts map[string]*fakeAccount, notify func(m *Manager)) *Manager {
+	t.Helper()
+
+	var m *Manager
+	account := func(ctx context.Context, profile string) (Account, error) {
+		acc, ok := accounts[profile]
+		if !ok {
+			return nil, errors.New("unknown profile " + profile)
+		}
+		return acc, nil
+	}
+
+	m, err := Load(path, []string{"main", "savings"}, account, logging.New(io.Discard, logging.LevelError, logging.FormatLogfmt), func([]Trigger) {
+		if notify != nil {
+			notify(m)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// wait waits for the status of a trigger
+func wait(t *testing.T, m *Manager, id string, status Status) Trigger {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second * 2); time.Now().Before(deadline); time.Sleep(time.Millisecond * 5) {
+		for _, tr := range m.Triggers() {
+			if tr.ID == id && tr.Status == status && (status != StatusFired || tr.OrderID != "") {
+				return tr
+			}
+		}
+	}
+	t.Fatalf("trigger %s did not become %s, triggers %+v", id, status, m.Triggers())
+
+	return Trigger{}
+}
+
+func TestTriggersSendWithTheirProfile(t *testing.T) {
+	main, savings := &fakeAccount{}, &fakeAccount{}
+	m := load(t, filepath.Join(t.TempDir(), "triggers.json"), map[string]*fakeAccount{"main": main, "savings": savings}, nil)
+
+	stop, err := m.Add(Trigger{Profile: "savings", Pair: "btcusd", Kind: KindStopLoss, Amount: "0.5", Price: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Add(Trigger{Pair: "btcusd", Kind: KindStopLoss, Amount: "1", Price: 100}); err == nil {
+		t.Error("added a trigger without a profile")
+	}
+
+	m.Trade(context.Background(), bitstamp.BTCUSD, 99, 1, 0, 0)
+	wait(t, m, stop.ID, StatusFired)
+
+	if got := savings.sent(); len(got) != 1 || got[0] != "0.5" {
+		t.Errorf("savings account sent %v, want one order of 0.5", got)
+	}
+	if got := main.sent(); len(got) != 0 {
+		t.Errorf("main account sent %v, want none", got)
+	}
+}
+
+func TestTriggersOfRemovedProfilesAreOrphaned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "triggers.json")
+	b, err := json.Marshal([]Trigger{
+		{ID: "1", Profile: "main", Pair: "btcusd", Kind: KindStopLoss, Amount: "1", Price: 100, Status: StatusActive},
+		{ID: "2", Profile: "old", Pair: "btcusd", Kind: KindStopLoss, Amount: "1", Price: 100, Status: StatusActive},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	main := &fakeAccount{}
+	m := load(t, path, map[string]*fakeAccount{"main": main}, nil)
+	m.Start()
+
+	m.Trade(context.Background(), bitstamp.BTCUSD, 99, 1, 0, 0)
+	wait(t, m, "1", StatusFired)
+	orphan := wait(t, m, "2", StatusOrphaned)
+	if orphan.Error == "" {
+		t.Error("orphaned trigger has no error")
+	}
+	if got := main.sent(); len(got) != 1 {
+		t.Errorf("main account sent %v, want only the order of its trigger", got)
+	}
+}
+
+func TestTradeNotifiesWithoutHoldingTheLock(t *testing.T) {
+	var (
+		fired bool
+		mu    sync.Mutex
+	)
+	m := load(t, filepath.Join(t.TempDir(), "triggers.json"), map[string]*fakeAccount{"main": {}}, func(m *Manager) {
+		// reading the manager from notify deadlocks when it is called holding the lock
+		for _, tr := range m.Triggers() {
+			mu.Lock()
+			fired = fired || tr.Status == StatusFired
+			mu.Unlock()
+		}
+	})
+
+	stop, err := m.Add(Trigger{Profile: "main", Pair: "btcusd", Kind: KindStopLoss, Amount: "1", Price: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Trade(context.Background(), bitstamp.BTCUSD, 99, 1, 0, 0)
+	wait(t, m, stop.ID, StatusFired)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !fired {
+		t.Error("fired trigger was not notified")
+	}
+}
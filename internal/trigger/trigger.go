@@ -0,0 +1,153 @@
+package trigger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// amountDecimals decimals of base currency amounts
+const amountDecimals = 8
+
+// Kind condition of a trigger
+type Kind string
+
+const (
+	// KindStopLoss sells when the price falls to or below Price
+	KindStopLoss Kind = "stop"
+	// KindTakeProfit sells when the price rises to or above Price
+	KindTakeProfit Kind = "take"
+	// KindTrailingStop sells when the price falls Trail below the highest price seen
+	KindTrailingStop Kind = "trail"
+)
+
+// Status lifecycle of a trigger
+type Status string
+
+const (
+	// StatusWaiting the trigger is armed once its entry order fills
+	StatusWaiting Status = "waiting"
+	StatusActive  Status = "active"
+	// StatusFired the trigger condition was met and its order sent
+	StatusFired     Status = "fired"
+	StatusCancelled Status = "cancelled"
+	StatusFailed    Status = "failed"
+	// StatusOrphaned the profile that created the trigger no longer exists
+	StatusOrphaned Status = "orphaned"
+)
+
+// Trigger sells Amount of the base currency of Pair with an instant order when its
+// condition is met. Triggers sharing a Group are one-cancels-other, when one fires
+// the rest are cancelled. Orders are sent using the account of Profile regardless of
+// the active profile
+type Trigger struct {
+	ID      string  `json:"id"`
+	Profile string  `json:"profile"`
+	Pair    string  `json:"pair"`
+	Kind    Kind    `json:"kind"`
+	Amount  string  `json:"amount"`
+	Price   float64 `json:"price,omitempty"`
+	// Trail distance of a trailing stop from the highest price as a fraction
+	Trail float64 `json:"trail,omitempty"`
+	// Peak highest price seen by a trailing stop
+	Peak  float64 `json:"peak,omitempty"`
+	Group string  `json:"group,omitempty"`
+	// EntryOrderID order whose first fill arms the trigger
+	EntryOrderID string `json:"entry_order_id,omitempty"`
+	// Filled amount of the entry order filled so far, the amount sold when the trigger fires
+	Filled float64 `json:"filled,omitempty"`
+	// EntryClosed the entry order is finished or cancelled and fills no more
+	EntryClosed bool      `json:"entry_closed,omitempty"`
+	Status      Status    `json:"status"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+	// OrderID id of the order sent when the trigger fired
+	OrderID string `json:"order_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Validate checks the trigger parameters
+func (t Trigger) Validate() error {
+	if t.Profile == "" {
+		return errors.New("trigger profile is required")
+	}
+	if amount, err := strconv.ParseFloat(t.Amount, 64); err != nil || amount <= 0 {
+		return errors.New("trigger amount must be a number greater than zero")
+	}
+
+	switch t.Kind {
+	case KindStopLoss, KindTakeProfit:
+		if t.Price <= 0 {
+			return fmt.Errorf("%s trigger price must be greater than zero", t.Kind)
+		}
+	case KindTrailingStop:
+		if t.Trail <= 0 || t.Trail >= 1 {
+			return errors.New("trailing stop distance must be between 0 and 100%")
+		}
+	default:
+		return fmt.Errorf("unknown trigger kind %s", t.Kind)
+	}
+
+	return nil
+}
+
+// Pending reports whether the trigger may still fire
+func (t Trigger) Pending() bool {
+	return t.Status == StatusWaiting || t.Status == StatusActive
+}
+
+// SellAmount returns the amount sold when the trigger fires, the filled amount of the
+// entry order for triggers of a bracket
+func (t Trigger) SellAmount() string {
+	if t.EntryOrderID == "" {
+		return t.Amount
+	}
+
+	return strings.TrimRight(strings.TrimRight(strconv.FormatFloat(t.Filled, 'f', amountDecimals, 64), "0"), ".")
+}
+
+// fill records the filled amount of the entry order, at most the trigger amount, and arms
+// a waiting trigger. It reports whether the trigger changed
+func (t *Trigger) fill(filled float64) bool {
+	if amount, err := strconv.ParseFloat(t.Amount, 64); err == nil && filled > amount {
+		filled = amount
+	}
+	if filled <= t.Filled {
+		return false
+	}
+
+	t.Filled = filled
+	if t.Status == StatusWaiting {
+		t.Status = StatusActive
+	}
+
+	return true
+}
+
+// Level returns the price the trigger fires at
+func (t Trigger) Level() float64 {
+	if t.Kind == KindTrailingStop {
+		return t.Peak * (1 - t.Trail)
+	}
+
+	return t.Price
+}
+
+// observe updates a trailing stop with a traded price and reports whether the trigger fires
+func (t *Trigger) observe(price float64) bool {
+	switch t.Kind {
+	case KindStopLoss:
+		return price <= t.Price
+	case KindTakeProfit:
+		return price >= t.Price
+	case KindTrailingStop:
+		if price > t.Peak {
+			t.Peak = price
+		}
+		return price <= t.Level()
+	}
+
+	return false
+}
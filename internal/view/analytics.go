@@ -0,0 +1,117 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/georlav/bitstamp-cli/internal/app"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+// maxWalls number of walls listed by the analytics panel
+const maxWalls = 4
+
+// analyticsPanel shows order book statistics above spread and imbalance history sparklines
+type analyticsPanel struct {
+	ui.Block
+	text      *widgets.Paragraph
+	spread    *widgets.Sparkline
+	imbalance *widgets.Sparkline
+	history   *widgets.SparklineGroup
+}
+
+func newAnalyticsPanel() *analyticsPanel {
+	p := analyticsPanel{Block: *ui.NewBlock()}
+	p.Title = "| Analytics |"
+	p.TitleStyle = titleStyle
+	p.BorderStyle = borderStyle
+
+	p.text = widgets.NewParagraph()
+	p.text.Border = false
+	p.text.TextStyle = textStyle
+
+	p.spread = widgets.NewSparkline()
+	p.spread.Title = "Spread (bps)"
+	p.spread.LineColor = ui.ColorYellow
+
+	p.imbalance = widgets.NewSparkline()
+	p.imbalance.Title = "Imbalance"
+	p.imbalance.LineColor = ui.ColorCyan
+	p.imbalance.MaxVal = 100
+
+	p.history = widgets.NewSparklineGroup(p.spread, p.imbalance)
+	p.history.Border = false
+
+	return &p
+}
+
+// update sets panel contents from state
+func (p *analyticsPanel) update(s app.State) {
+	st := s.BookStats
+	if st.Mid == 0 {
+		p.text.Text = "waiting for order book"
+		p.spread.Data, p.imbalance.Data = nil, nil
+		return
+	}
+
+	lines := []string{
+		fmt.Sprintf("Bid %s  Ask %s", greenText(formatAmount(st.BestBid)), redText(formatAmount(st.BestAsk))),
+		fmt.Sprintf("Spread %s (%.2f bps)", formatAmount(st.Spread), st.SpreadBps),
+		fmt.Sprintf("Mid %s  Micro %s", formatAmount(st.Mid), formatAmount(st.Micro)),
+		fmt.Sprintf("Imbalance %+.2f", st.Imbalance),
+	}
+	for i := range st.Walls {
+		if i == maxWalls {
+			lines = append(lines, fmt.Sprintf("... %d more walls", len(st.Walls)-maxWalls))
+			break
+		}
+
+		w, side := st.Walls[i], redText("ask")
+		if w.Bid {
+			side = greenText("bid")
+		}
+		lines = append(lines, fmt.Sprintf("Wall %s %s @ %s, %.1f bps", side, formatAmount(w.Amount), formatAmount(w.Price), w.DistanceBps))
+	}
+	p.text.Text = strings.Join(lines, "\n")
+
+	spread := make([]float64, 0, len(s.BookHistory))
+	imbalance := make([]float64, 0, len(s.BookHistory))
+	max := 0.0
+	for _, h := range s.BookHistory {
+		spread = append(spread, h.SpreadBps)
+		imbalance = append(imbalance, (h.Imbalance+1)*50)
+		if h.SpreadBps > max {
+			max = h.SpreadBps
+		}
+	}
+	p.spread.Data, p.spread.MaxVal = spread, max
+	p.imbalance.Data = imbalance
+}
+
+// Draw implements the ui.Drawable interface
+func (p *analyticsPanel) Draw(buf *ui.Buffer) {
+	p.Block.Draw(buf)
+
+	// child blocks reserve a border cell on each side even when borders are disabled
+	in := p.Inner
+	split := in.Min.Y + in.Dy()/2
+	p.text.SetRect(in.Min.X-1, in.Min.Y-1, in.Max.X+1, split+1)
+	p.text.Draw(buf)
+
+	// show the most recent samples that fit the panel
+	width := in.Dx()
+	spread, imbalance := p.spread.Data, p.imbalance.Data
+	if len(spread) > width {
+		p.spread.Data, p.imbalance.Data = spread[len(spread)-width:], imbalance[len(imbalance)-width:]
+	}
+	if p.spread.MaxVal == 0 {
+		p.spread.MaxVal = 1
+	}
+
+	p.history.SetRect(in.Min.X-1, split-1, in.Max.X+1, in.Max.Y+1)
+	if p.history.Inner.Dy() >= len(p.history.Sparklines)*2 && p.history.Inner.Dx() > 0 {
+		p.history.Draw(buf)
+	}
+	p.spread.Data, p.imbalance.Data = spread, imbalance
+}
@@ -0,0 +1,122 @@
+package view
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/compare"
+	ui "github.com/gizak/termui/v3"
+)
+
+// compareLabelWidth width of the change labels on the left of the lines
+const compareLabelWidth = 8
+
+// compareColors line colours of the compared pairs in order
+var compareColors = []ui.Color{ui.ColorYellow, ui.ColorCyan, ui.ColorMagenta, ui.ColorGreen, ui.ColorRed, ui.ColorBlue}
+
+// comparePanel draws the performance of several pairs rebased to 0% at the start of the
+// window on one plot, above a legend and the correlations between them
+type comparePanel struct {
+	ui.Block
+	series []compare.Series
+}
+
+func newComparePanel() *comparePanel {
+	p := comparePanel{Block: *ui.NewBlock()}
+	p.Title = "| Compare |"
+	p.TitleStyle = titleStyle
+	p.BorderStyle = borderStyle
+
+	return &p
+}
+
+// update sets panel contents from state
+func (p *comparePanel) update(s app.State) {
+	names := make([]string, 0, len(s.Compare))
+	for _, pair := range s.Compare {
+		names = append(names, strings.ToUpper(pair.String()))
+	}
+	p.Title = fmt.Sprintf("| Compare %s (%s) |", strings.Join(names, ", "), timeframeText(s.ChartStep()))
+	p.series = s.Comparison
+}
+
+// Draw implements the ui.Drawable interface
+func (p *comparePanel) Draw(buf *ui.Buffer) {
+	p.Block.Draw(buf)
+
+	in := p.Inner
+	if len(p.series) == 0 || len(p.series[0].Change) < 2 {
+		buf.SetString("waiting for candles", textStyle, in.Min)
+		return
+	}
+
+	// legend and correlations take the last two lines
+	area := image.Rect(in.Min.X+compareLabelWidth, in.Min.Y, in.Max.X, in.Max.Y-2)
+	if area.Dx() < 2 || area.Dy() < 2 {
+		return
+	}
+
+	min, max := 0.0, 0.0
+	for _, s := range p.series {
+		for _, v := range s.Change {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
+	if max-min < 0.01 {
+		min, max = min-0.5, max+0.5
+	}
+
+	// braille cells hold 2 by 4 dots
+	dotY := func(v float64) int {
+		return area.Min.Y*4 + int(math.Round((max-v)/(max-min)*float64(area.Dy()*4-1)))
+	}
+	for _, v := range []float64{max, 0, min} {
+		buf.SetString(fmt.Sprintf("%+.1f%%", v), textStyle, image.Pt(in.Min.X, dotY(v)/4))
+	}
+	for x := area.Min.X; x < area.Max.X; x++ {
+		buf.SetCell(ui.NewCell('┈', ui.NewStyle(ui.ColorWhite)), image.Pt(x, dotY(0)/4))
+	}
+
+	c := ui.NewCanvas()
+	c.Rectangle = area
+	for i, s := range p.series {
+		n := len(s.Change)
+		dotX := func(j int) int {
+			return area.Min.X*2 + j*(area.Dx()*2-1)/(n-1)
+		}
+		for j := 1; j < n; j++ {
+			c.SetLine(image.Pt(dotX(j-1), dotY(s.Change[j-1])), image.Pt(dotX(j), dotY(s.Change[j])), compareColor(i))
+		}
+	}
+	c.Draw(buf)
+
+	x := in.Min.X
+	for i, s := range p.series {
+		label := fmt.Sprintf("■ %s %+.2f%%  ", strings.ToUpper(s.Pair.String()), s.Last())
+		buf.SetString(label, ui.NewStyle(compareColor(i)), image.Pt(x, in.Max.Y-2))
+		x += len([]rune(label))
+	}
+
+	correlations := make([]string, 0, len(p.series))
+	for _, c := range compare.Correlations(p.series) {
+		correlations = append(correlations, fmt.Sprintf("%s/%s %s", strings.ToUpper(c.A.String()), strings.ToUpper(c.B.String()), correlationText(c.R)))
+	}
+	if len(correlations) > 0 {
+		buf.SetString("Correlation "+strings.Join(correlations, "  "), textStyle, image.Pt(in.Min.X, in.Max.Y-1))
+	}
+}
+
+func compareColor(i int) ui.Color {
+	return compareColors[i%len(compareColors)]
+}
+
+func correlationText(r float64) string {
+	if math.IsNaN(r) {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%.2f", r)
+}
@@ -0,0 +1,583 @@
+package view
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/arbitrage"
+	"github.com/georlav/bitstamp-cli/internal/balance"
+	"github.com/georlav/bitstamp-cli/internal/execution"
+	"github.com/georlav/bitstamp-cli/internal/fee"
+	"github.com/georlav/bitstamp-cli/internal/notify"
+	"github.com/georlav/bitstamp-cli/internal/orderbook"
+	"github.com/georlav/bitstamp-cli/internal/strategy"
+	"github.com/georlav/bitstamp-cli/internal/transfer"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+)
+
+const (
+	// barWidth the maximum width of order book volume bars
+	barWidth = 8
+	// maxTapeRows number of trades shown by the live trades table
+	maxTapeRows = 100
+)
+
+func redText(s string) string {
+	return fmt.Sprintf("[%s](fg:red,bg:clear)", s)
+}
+
+func greenText(s string) string {
+	return fmt.Sprintf("[%s](fg:green,bg:clear)", s)
+}
+
+// tradeRows formats running volumes and the trade tape as live trades table rows,
+// returns the indexes of header rows. Trades with a value of at least the large trade value
+// are highlighted, times are shown in the display zone or as ages
+func tradeRows(s app.State, now time.Time) ([][]string, []int) {
+	t, largeTrade := s.Tape, s.LargeTrade
+	rows := [][]string{{"Window", "Buy", "Sell"}}
+	if t == nil {
+		return append(rows, []string{"Amount", "Time", "Price"}), []int{0, 1}
+	}
+
+	for _, v := range t.Volumes(now) {
+		rows = append(rows, []string{timeframeText(v.Window), greenText(formatAmount(v.Buy)), redText(formatAmount(v.Sell))})
+	}
+	header := len(rows)
+	rows = append(rows, []string{"Amount", "Time", "Price"})
+
+	for _, tr := range t.Recent(maxTapeRows) {
+		amount := formatAmount(tr.Amount)
+		if tr.Count > 1 {
+			amount = fmt.Sprintf("%s (%d)", amount, tr.Count)
+		}
+
+		price := greenText(formatAmount(tr.Price))
+		if tr.Sell {
+			price = redText(formatAmount(tr.Price))
+		}
+		if largeTrade > 0 && tr.Price*tr.Amount >= largeTrade {
+			amount = fmt.Sprintf("[%s](mod:reverse)", amount)
+		}
+
+		at := clockText(s, tr.Time)
+		if s.RelativeTime {
+			at = ageText(tr.Time, now)
+		}
+
+		rows = append(rows, []string{amount, at, price})
+	}
+
+	return rows, []int{0, header}
+}
+
+// bookRows formats the order book of the active pair as order book table rows fitting
+// height rows and the indexes of header rows, levels are grouped using the selected step and
+// levels of own orders are highlighted. Each side is rendered independently, the shorter side
+// is padded with blanks
+func bookRows(s app.State, height int) ([][]string, []int) {
+	var (
+		step    = s.GroupStep()
+		bids    = orderbook.Group(app.ParseLevels(s.Book.Bids), step, true)
+		asks    = orderbook.Group(app.ParseLevels(s.Book.Asks), step, false)
+		ownBids []float64
+		ownAsks []float64
+	)
+
+	for _, o := range s.OpenOrders {
+		if o.Pair != s.ActivePair() {
+			continue
+		}
+
+		price, _ := strconv.ParseFloat(o.Price, 64)
+		if o.Sell {
+			ownAsks = append(ownAsks, price)
+		} else {
+			ownBids = append(ownBids, price)
+		}
+	}
+	orderbook.MarkOwn(bids, ownBids, step, true)
+	orderbook.MarkOwn(asks, ownAsks, step, false)
+
+	if s.BookVertical {
+		return verticalBookRows(bids, asks, step, bookDepth(s, (height-2)/2))
+	}
+
+	depth := bookDepth(s, height-1)
+	bids, asks = truncateRows(bids, depth), truncateRows(asks, depth)
+	max := orderbook.MaxAmount(bids, asks)
+
+	n := len(bids)
+	if len(asks) > n {
+		n = len(asks)
+	}
+
+	rows := make([][]string, 0, n+1)
+	rows = append(rows, []string{"", "Total", "Amount", "Bid", "Ask", "Amount", "Total", ""})
+	for i := 0; i < n; i++ {
+		row := make([]string, 8)
+		if i < len(bids) {
+			row[0] = greenText(volumeBar(bids[i].Amount, max))
+			row[1] = formatAmount(bids[i].Total)
+			row[2] = formatAmount(bids[i].Amount)
+			row[3] = priceText(bids[i], step, greenText)
+		}
+		if i < len(asks) {
+			row[4] = priceText(asks[i], step, redText)
+			row[5] = formatAmount(asks[i].Amount)
+			row[6] = formatAmount(asks[i].Total)
+			row[7] = redText(volumeBar(asks[i].Amount, max))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, []int{0}
+}
+
+// verticalBookRows formats the order book with asks above bids, the best levels of
+// both sides meet at a spread row in the middle
+func verticalBookRows(bids, asks []orderbook.Row, step float64, depth int) ([][]string, []int) {
+	bids, asks = truncateRows(bids, depth), truncateRows(asks, depth)
+	max := orderbook.MaxAmount(bids, asks)
+
+	rows := make([][]string, 0, len(bids)+len(asks)+2)
+	rows = append(rows, []string{"Price", "Amount", "Total", ""})
+
+	// the best ask is the last row above the spread
+	for i := len(asks) - 1; i >= 0; i-- {
+		rows = append(rows, []string{
+			priceText(asks[i], step, redText),
+			formatAmount(asks[i].Amount),
+			formatAmount(asks[i].Total),
+			redText(volumeBar(asks[i].Amount, max)),
+		})
+	}
+
+	spread := []string{"", "", "", ""}
+	if len(bids) > 0 && len(asks) > 0 {
+		s := asks[0].Price - bids[0].Price
+		decimals := -1
+		if step > 0 {
+			decimals = stepDecimals(step)
+		}
+		spread[0] = "Spread " + strconv.FormatFloat(s, 'f', decimals, 64)
+		spread[1] = fmt.Sprintf("%.1f bps", s/((asks[0].Price+bids[0].Price)/2)*10000)
+	}
+	rows = append(rows, spread)
+	headers := []int{0, len(rows) - 1}
+
+	for i := range bids {
+		rows = append(rows, []string{
+			priceText(bids[i], step, greenText),
+			formatAmount(bids[i].Amount),
+			formatAmount(bids[i].Total),
+			greenText(volumeBar(bids[i].Amount, max)),
+		})
+	}
+
+	return rows, headers
+}
+
+// bookDepth returns the number of levels shown per side, fit limits it to the rows
+// available in the panel and the configured depth limits it further
+func bookDepth(s app.State, fit int) int {
+	if fit < 1 {
+		fit = 1
+	}
+	if s.BookDepth > 0 && s.BookDepth < fit {
+		return s.BookDepth
+	}
+
+	return fit
+}
+
+func truncateRows(rows []orderbook.Row, n int) []orderbook.Row {
+	if len(rows) > n {
+		return rows[:n]
+	}
+
+	return rows
+}
+
+// priceText formats the price of a row using the precision of step, own levels are highlighted
+func priceText(r orderbook.Row, step float64, color func(string) string) string {
+	price := strconv.FormatFloat(r.Price, 'f', -1, 64)
+	if step > 0 {
+		price = strconv.FormatFloat(r.Price, 'f', stepDecimals(step), 64)
+	}
+
+	if r.Own {
+		return fmt.Sprintf("[%s](mod:reverse)", price)
+	}
+
+	return color(price)
+}
+
+// stepDecimals returns the number of decimals needed to display multiples of step
+func stepDecimals(step float64) int {
+	if step >= 1 {
+		return 0
+	}
+
+	return int(math.Round(-math.Log10(step)))
+}
+
+// formatAmount formats an amount with up to 8 decimals
+func formatAmount(a float64) string {
+	s := strconv.FormatFloat(a, 'f', 8, 64)
+	s = strings.TrimRight(s, "0")
+
+	return strings.TrimSuffix(s, ".")
+}
+
+// volumeBar returns a horizontal bar sized relative to max
+func volumeBar(amount, max float64) string {
+	if max <= 0 {
+		return ""
+	}
+
+	return strings.Repeat("▇", int(math.Round(amount/max*barWidth)))
+}
+
+// depthData returns the cumulative amount of bids and asks by level, empty when
+// any side has less than two levels
+func depthData(b app.Book) [][]float64 {
+	if len(b.Bids) < 2 || len(b.Asks) < 2 {
+		return [][]float64{}
+	}
+
+	return [][]float64{cumulative(b.Bids), cumulative(b.Asks)}
+}
+
+func cumulative(levels []app.Level) []float64 {
+	data := make([]float64, 0, len(levels))
+
+	sum := 0.0
+	for i := range levels {
+		amount, _ := strconv.ParseFloat(levels[i].Amount, 64)
+		sum += amount
+		data = append(data, sum)
+	}
+
+	return data
+}
+
+// timeframeText formats a chart timeframe as 15m, 4h, 1d
+func timeframeText(d time.Duration) string {
+	switch {
+	case d >= time.Hour*24:
+		return fmt.Sprintf("%dd", d/(time.Hour*24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// clockText formats a time of day in the display time zone with millisecond precision
+func clockText(s app.State, t time.Time) string {
+	return t.In(s.Zone()).Format("15:04:05.000")
+}
+
+// ageText formats the time elapsed from t until now
+func ageText(t, now time.Time) string {
+	switch d := now.Sub(t); {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", d/time.Second)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", d/time.Minute)
+	case d < time.Hour*24:
+		return fmt.Sprintf("%dh ago", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dd ago", d/(time.Hour*24))
+	}
+}
+
+// errorRows formats the error log, most recent first
+func errorRows(errs []app.ErrorEntry, loc *time.Location) []string {
+	rows := make([]string, 0, len(errs))
+	for _, e := range errs {
+		rows = append(rows, fmt.Sprintf("%s %s %s: %s", e.Time.In(loc).Format("15:04:05"), redText(e.Kind.String()), e.Source, e.Message))
+	}
+
+	return rows
+}
+
+// alertRows formats trigger, job and strategy events
+func alertRows(alerts []notify.Event, loc *time.Location) []string {
+	rows := make([]string, 0, len(alerts))
+	for _, e := range alerts {
+		rows = append(rows, fmt.Sprintf("%s %s %s: %s", e.Time.In(loc).Format("15:04:05"), e.Kind, e.Title, e.Message))
+	}
+
+	return rows
+}
+
+// balanceRows formats the balances of the active profile
+func balanceRows(balances []balance.Balance) [][]string {
+	rows := [][]string{{"Currency", "Total", "Available", "Reserved"}}
+	for _, b := range balances {
+		rows = append(rows, []string{
+			strings.ToUpper(b.Currency),
+			formatAmount(b.Total),
+			formatAmount(b.Available),
+			formatAmount(b.Reserved),
+		})
+	}
+
+	return rows
+}
+
+// triggerRows formats order triggers, the level of a trailing stop follows the highest price
+// and bracket triggers show the filled amount of their entry order
+func triggerRows(triggers []trigger.Trigger) [][]string {
+	rows := [][]string{{"ID", "Profile", "Pair", "Kind", "Amount", "Level", "Group", "Status"}}
+	for _, t := range triggers {
+		level := "-"
+		if t.Kind != trigger.KindTrailingStop || t.Peak > 0 {
+			level = strconv.FormatFloat(t.Level(), 'f', -1, 64)
+		}
+
+		status := string(t.Status)
+		switch t.Status {
+		case trigger.StatusFired:
+			status = greenText(status)
+		case trigger.StatusFailed, trigger.StatusOrphaned:
+			status = redText(status)
+		}
+
+		amount := t.Amount
+		if t.EntryOrderID != "" {
+			amount = t.SellAmount() + "/" + t.Amount
+		}
+
+		rows = append(rows, []string{t.ID, t.Profile, strings.ToUpper(t.Pair), string(t.Kind), amount, level, t.Group, status})
+	}
+
+	return rows
+}
+
+// jobRows formats execution jobs, slippage of the average fill price against the arrival price is in basis points
+func jobRows(jobs []execution.Job) [][]string {
+	rows := [][]string{{"ID", "Profile", "Pair", "Kind", "Side", "Filled", "Avg", "Arrival", "Slip bps", "Status"}}
+	for _, j := range jobs {
+		side := greenText("buy")
+		if j.Sell {
+			side = redText("sell")
+		}
+
+		avg, slip := "-", "-"
+		if j.Filled > 0 {
+			avg = strconv.FormatFloat(j.AvgPrice(), 'f', 2, 64)
+			slip = strconv.FormatFloat(j.Slippage(), 'f', 1, 64)
+		}
+
+		status := string(j.Status)
+		switch j.Status {
+		case execution.StatusDone:
+			status = greenText(status)
+		case execution.StatusFailed:
+			status = redText(status)
+		}
+
+		rows = append(rows, []string{
+			j.ID,
+			j.Profile,
+			strings.ToUpper(j.Pair.String()),
+			string(j.Kind),
+			side,
+			fmt.Sprintf("%s/%s %.0f%%", formatAmount(j.Filled), formatAmount(j.Amount), j.Progress()*100),
+			avg,
+			strconv.FormatFloat(j.Arrival, 'f', 2, 64),
+			slip,
+			status,
+		})
+	}
+
+	return rows
+}
+
+// arbitrageRows formats the best n arbitrage opportunities, returns are in percent and sizes in the first currency of the path
+func arbitrageRows(opportunities []arbitrage.Opportunity, n int) [][]string {
+	rows := [][]string{{"Path", "Pairs", "Gross %", "Net %", "Size"}}
+	for i, o := range opportunities {
+		if i >= n {
+			break
+		}
+
+		pairs := make([]string, 0, len(o.Pairs))
+		for _, p := range o.Pairs {
+			pairs = append(pairs, strings.ToUpper(p.String()))
+		}
+
+		net := fmt.Sprintf("%.3f", o.Net*100)
+		if o.Net > 0 {
+			net = greenText(net)
+		}
+
+		rows = append(rows, []string{
+			strings.ToUpper(strings.Join(o.Path, ">")),
+			strings.Join(pairs, " "),
+			fmt.Sprintf("%.3f", o.Gross*100),
+			net,
+			formatAmount(o.Size),
+		})
+	}
+
+	return rows
+}
+
+// orderPreview estimates the cost of the order typed in the command prompt against the
+// book of the active pair, empty when the command does not start with a side and an amount
+func orderPreview(s app.State) string {
+	fields := strings.Fields(s.PromptText)
+	if len(fields) < 2 {
+		return ""
+	}
+
+	var sell bool
+	var amount string
+	switch fields[0] {
+	case "quote", "twap", "iceberg", "pov":
+		if len(fields) < 3 || (fields[1] != "buy" && fields[1] != "sell") {
+			return ""
+		}
+		sell, amount = fields[1] == "sell", fields[2]
+	case "stop", "take", "trail", "oco":
+		sell, amount = true, fields[1]
+	case "bracket":
+		amount = fields[1]
+	default:
+		return ""
+	}
+
+	a, err := strconv.ParseFloat(amount, 64)
+	if err != nil || a <= 0 {
+		return ""
+	}
+
+	e, err := fee.EstimateOrder(app.ParseLevels(s.Book.Bids), app.ParseLevels(s.Book.Asks), sell, a, fee.Taker(s.Fees, s.ActivePair()))
+	if err != nil {
+		return ""
+	}
+
+	return e.String()
+}
+
+// strategyRows formats the status of strategy rules, the condition value and paper
+// position of each rule and the time of its last signal
+func strategyRows(statuses []strategy.Status, loc *time.Location) [][]string {
+	rows := [][]string{{"Rule", "Pair", "TF", "Action", "Condition", "Signals", "Last", "Position", "PnL", "Error"}}
+	for _, st := range statuses {
+		value := "warming up"
+		switch {
+		case st.Disabled:
+			value = "disabled"
+		case st.Ready && st.Value:
+			value = greenText("true")
+		case st.Ready:
+			value = "false"
+		}
+
+		last, position, pnl, errText := "-", "-", "-", ""
+		if !st.Last.IsZero() {
+			last = st.Last.In(loc).Format("01-02 15:04")
+		}
+		if st.Action == strategy.ActionPaper {
+			position = formatAmount(st.Paper.Position)
+			pnl = strconv.FormatFloat(st.PnL(), 'f', 2, 64)
+			switch {
+			case st.PnL() > 0:
+				pnl = greenText(pnl)
+			case st.PnL() < 0:
+				pnl = redText(pnl)
+			}
+		}
+
+		if st.Error != "" {
+			errText = redText(st.Error)
+		}
+
+		rows = append(rows, []string{
+			st.Name,
+			strings.ToUpper(st.Pair),
+			st.Timeframe,
+			string(st.Action),
+			value,
+			strconv.Itoa(st.Signals),
+			last,
+			position,
+			pnl,
+			errText,
+		})
+	}
+
+	return rows
+}
+
+// transferRows formats the n most recent deposits and withdrawals
+func transferRows(transfers []transfer.Transfer, n int, loc *time.Location) [][]string {
+	rows := [][]string{{"Time", "Currency", "Amount", "Address", "TxID"}}
+	for i, t := range transfers {
+		if i >= n {
+			break
+		}
+
+		amount := greenText("+" + t.Amount)
+		if t.Kind == transfer.KindWithdrawal {
+			amount = redText("-" + t.Amount)
+		}
+
+		rows = append(rows, []string{t.Time.In(loc).Format("2006-01-02 15:04"), t.Currency, amount, t.Address, t.TxID})
+	}
+
+	return rows
+}
+
+// statusText formats the status bar with the current time, the command prompt replaces it while open
+func statusText(s app.State, now time.Time) string {
+	if s.Prompt {
+		if preview := orderPreview(s); preview != "" {
+			return ":" + s.PromptText + "_ | " + preview
+		}
+		return ":" + s.PromptText + "_"
+	}
+
+	conn := greenText("connected")
+	if !s.Connected {
+		conn = redText("disconnected")
+	}
+
+	budget := "API budget -"
+	if s.Budget.Limit > 0 {
+		text := fmt.Sprintf("%d/%d", s.Budget.Remaining, s.Budget.Limit)
+		switch r := float64(s.Budget.Remaining) / float64(s.Budget.Limit); {
+		case r < 0.1:
+			text = redText(text)
+		case r < 0.5:
+			text = fmt.Sprintf("[%s](fg:yellow)", text)
+		}
+		budget = "API budget " + text
+	}
+
+	profile := s.ActiveProfile()
+	if s.ReadOnly {
+		profile += " " + greenText("read-only")
+	}
+
+	clock := now.In(s.Zone()).Format("15:04:05 MST")
+	text := fmt.Sprintf(" %s | %s | %s | %s | %s | h help", clock, strings.ToUpper(s.ActivePair().String()), profile, conn, budget)
+	// the most recent of the last command message and the last error
+	if len(s.Errors) > 0 && !s.Errors[0].Time.Before(s.Message.Time) {
+		e := s.Errors[0]
+		text += fmt.Sprintf(" | %s %s %s: %s", e.Time.In(s.Zone()).Format("15:04:05"), redText(e.Kind.String()), e.Source, e.Message)
+	} else if s.Message.Text != "" {
+		text += " | " + s.Message.Text
+	}
+
+	return text
+}
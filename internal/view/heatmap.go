@@ -0,0 +1,108 @@
+package view
+
+import (
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/heatmap"
+	ui "github.com/gizak/termui/v3"
+)
+
+const (
+	// heatmapSpan distance of the top and bottom rows from mid price as a fraction of mid
+	heatmapSpan = 0.003
+	// heatmapLabelEvery rows between price labels
+	heatmapLabelEvery = 4
+	// largePrint share of the largest print volume above which a print is drawn as a large dot
+	largePrint = 0.33
+)
+
+// heatGradient 256 colour palette indexes from no resting volume to the most
+var heatGradient = []ui.Color{16, 17, 18, 19, 20, 21, 27, 33, 39, 45, 51, 50, 49, 48, 47, 46, 82, 118, 154, 190, 226, 220, 214, 208, 202, 196}
+
+// heatmapPanel shows resting order book volume over time, the x axis is time with the
+// most recent snapshot on the right and the y axis price around mid. Trades are drawn as dots
+type heatmapPanel struct {
+	ui.Block
+	snapshots []heatmap.Snapshot
+	prints    []heatmap.Print
+}
+
+func newHeatmapPanel() *heatmapPanel {
+	p := heatmapPanel{Block: *ui.NewBlock()}
+	p.Title = "| Heatmap |"
+	p.TitleStyle = titleStyle
+	p.BorderStyle = borderStyle
+
+	return &p
+}
+
+// update sets panel contents from state
+func (p *heatmapPanel) update(s app.State) {
+	p.snapshots, p.prints = s.Heatmap, s.Prints
+}
+
+// Draw implements the ui.Drawable interface
+func (p *heatmapPanel) Draw(buf *ui.Buffer) {
+	p.Block.Draw(buf)
+
+	in := p.Inner
+	if len(p.snapshots) == 0 {
+		buf.SetString("waiting for order book", textStyle, in.Min)
+		return
+	}
+
+	// price labels are drawn on the left of the cells with enough decimals to tell rows apart
+	mid := p.snapshots[len(p.snapshots)-1].Mid
+	decimals := 0
+	if step := mid * heatmapSpan * 2 / float64(in.Dy()); step > 0 && step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step)))
+	}
+	labelWidth := len(strconv.FormatFloat(mid, 'f', decimals, 64)) + 1
+
+	g := heatmap.Build(p.snapshots, p.prints, in.Dy(), in.Dx()-labelWidth, heatmapSpan)
+	if len(g.Cells) == 0 {
+		return
+	}
+
+	for r, row := range g.Cells {
+		y := in.Min.Y + r
+		if r%heatmapLabelEvery == heatmapLabelEvery/2 {
+			buf.SetString(strconv.FormatFloat(g.Price(r), 'f', decimals, 64), textStyle, image.Pt(in.Min.X, y))
+		}
+
+		// the most recent snapshot is aligned to the right edge
+		x := in.Max.X - len(row)
+		for c, cell := range row {
+			style := ui.NewStyle(ui.ColorWhite, heatColor(cell.Volume, g.MaxVolume))
+			char := ' '
+			if cell.Traded > 0 {
+				char, style.Fg, style.Modifier = '•', ui.ColorGreen, ui.ModifierBold
+				if cell.Sell {
+					style.Fg = ui.ColorRed
+				}
+				if cell.Traded >= g.MaxTraded*largePrint {
+					char = '●'
+				}
+			}
+			buf.SetCell(ui.NewCell(char, style), image.Pt(x+c, y))
+		}
+	}
+}
+
+// heatColor returns the gradient colour of a volume, the square root scale keeps
+// smaller levels visible next to walls
+func heatColor(volume, max float64) ui.Color {
+	if volume <= 0 || max <= 0 {
+		return heatGradient[0]
+	}
+
+	i := int(math.Round(math.Sqrt(volume/max) * float64(len(heatGradient)-1)))
+	if i >= len(heatGradient) {
+		i = len(heatGradient) - 1
+	}
+
+	return heatGradient[i]
+}
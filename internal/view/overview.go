@@ -0,0 +1,131 @@
+package view
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/overview"
+	ui "github.com/gizak/termui/v3"
+)
+
+// overviewFullScale 24 hour change in percent drawn with the most intense colour
+const overviewFullScale = 10
+
+// 256 colour palette indexes from the smallest change to the largest
+var (
+	riseGradient = []ui.Color{108, 114, 77, 83, 46}
+	fallGradient = []ui.Color{138, 174, 167, 203, 196}
+)
+
+// overviewTitle returns the title of the overview panel
+func overviewTitle(s app.State) string {
+	market := ""
+	if s.MarketIndex < len(s.Markets) {
+		market = s.Markets[s.MarketIndex].Name
+	}
+
+	return fmt.Sprintf("| Market %s (by %s) |", market, overview.Sorts[s.OverviewSort])
+}
+
+// overviewRows formats the statistics of at most n pairs of the selected market in the
+// selected order, rows are coloured by the size of the change and the active pair is highlighted
+func overviewRows(s app.State, n int) ([][]string, map[int]ui.Style) {
+	rows := [][]string{{"Pair", "Last", "24h %", "Volume", "High", "Low"}}
+	styles := map[int]ui.Style{0: tableHeaderStyle}
+
+	market := make(map[bitstamp.Pair]bool, len(s.Pairs()))
+	for _, p := range s.Pairs() {
+		market[p] = true
+	}
+
+	for _, r := range overview.Sorted(s.Overview, s.OverviewSort) {
+		if len(rows) > n {
+			break
+		}
+		if !market[r.Pair] {
+			continue
+		}
+
+		style := ui.NewStyle(changeColor(r.Change))
+		if r.Pair == s.ActivePair() {
+			style = selectedRowStyle
+		}
+		styles[len(rows)] = style
+
+		rows = append(rows, []string{
+			strings.ToUpper(r.Pair.String()),
+			formatAmount(r.Last),
+			fmt.Sprintf("%+.2f", r.Change),
+			compactAmount(r.Volume),
+			formatAmount(r.High),
+			formatAmount(r.Low),
+		})
+	}
+
+	return rows, styles
+}
+
+// watchlistRows formats the statistics of the watchlist pairs in the configured order, pairs
+// whose ticker is not loaded yet are listed without values
+func watchlistRows(s app.State) ([][]string, map[int]ui.Style) {
+	rows := [][]string{{"Pair", "Last", "24h %", "Volume"}}
+	styles := map[int]ui.Style{0: tableHeaderStyle}
+
+	stats := make(map[bitstamp.Pair]overview.Row, len(s.WatchlistRows))
+	for _, r := range s.WatchlistRows {
+		stats[r.Pair] = r
+	}
+
+	for _, p := range s.Watchlist {
+		r, ok := stats[p]
+		if !ok {
+			rows = append(rows, []string{strings.ToUpper(p.String()), "-", "-", "-"})
+			continue
+		}
+
+		styles[len(rows)] = ui.NewStyle(changeColor(r.Change))
+		if p == s.ActivePair() {
+			styles[len(rows)] = selectedRowStyle
+		}
+		rows = append(rows, []string{
+			strings.ToUpper(p.String()),
+			formatAmount(r.Last),
+			fmt.Sprintf("%+.2f", r.Change),
+			compactAmount(r.Volume),
+		})
+	}
+
+	return rows, styles
+}
+
+// changeColor returns the gradient colour of a change in percent
+func changeColor(change float64) ui.Color {
+	gradient := riseGradient
+	if change < 0 {
+		gradient = fallGradient
+	}
+
+	i := int(math.Abs(change) / overviewFullScale * float64(len(gradient)-1))
+	if i >= len(gradient) {
+		i = len(gradient) - 1
+	}
+
+	return gradient[i]
+}
+
+// compactAmount formats an amount with a thousands, millions or billions suffix
+func compactAmount(a float64) string {
+	switch {
+	case a >= 1e9:
+		return fmt.Sprintf("%.2fB", a/1e9)
+	case a >= 1e6:
+		return fmt.Sprintf("%.2fM", a/1e6)
+	case a >= 1e3:
+		return fmt.Sprintf("%.2fK", a/1e3)
+	default:
+		return fmt.Sprintf("%.2f", a)
+	}
+}
@@ -0,0 +1,410 @@
+package view
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+	"github.com/georlav/bitstamp-cli/internal/layout"
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+// View owns the termui widgets and draws application state, it must only be
+// used from the goroutine that polls ui events
+type View struct {
+	grid       *ui.Grid
+	cList      *widgets.List
+	pList      *widgets.List
+	chart      *widgets.Plot
+	liveTrades *widgets.Table
+	orderBook  *widgets.Table
+	depth      *widgets.Plot
+	analytics  *analyticsPanel
+	heatmap    *heatmapPanel
+	compare    *comparePanel
+	errorLog   *widgets.List
+	triggers   *widgets.Table
+	jobs       *widgets.Table
+	arbitrage  *widgets.Table
+	transfers  *widgets.Table
+	overview   *widgets.Table
+	strategies *widgets.Table
+	watchlist  *widgets.Table
+	account    *widgets.Table
+	alerts     *widgets.List
+	status     *widgets.Paragraph
+	help       *widgets.Table
+	log        *widgets.List
+	logs       func(n int) []string
+	panels     map[string]panel
+	layout     layout.Layout
+	focus      string
+	maximised  bool
+	width      int
+	height     int
+}
+
+// panel a widget that can be placed in a layout
+type panel struct {
+	ui.Drawable
+	block *ui.Block
+}
+
+// Generic styles
+var (
+	titleStyle       = ui.NewStyle(ui.ColorGreen, ui.ColorClear, ui.ModifierBold)
+	textStyle        = ui.NewStyle(ui.ColorClear, ui.ColorClear, ui.ModifierClear)
+	borderStyle      = ui.NewStyle(ui.ColorGreen, ui.ColorClear, ui.ModifierBold)
+	selectedRowStyle = ui.NewStyle(ui.ColorClear, ui.ColorGreen, ui.ModifierBold)
+	tableHeaderStyle = ui.NewStyle(ui.ColorClear, ui.ColorClear, ui.ModifierBold)
+	focusBorderStyle = ui.NewStyle(ui.ColorYellow, ui.ColorClear, ui.ModifierBold)
+)
+
+// New creates the application widgets, logs returns the most recent log entries shown
+// in the log overlay. A layout must be set before rendering
+func New(version string, logs func(n int) []string) *View {
+	v := View{logs: logs}
+
+	v.cList = widgets.NewList()
+	v.cList.Title = "| Currencies |"
+	v.cList.TitleStyle = titleStyle
+	v.cList.BorderStyle = borderStyle
+	v.cList.SelectedRowStyle = selectedRowStyle
+	v.cList.TextStyle = textStyle
+
+	v.pList = widgets.NewList()
+	v.pList.Title = fmt.Sprintf("| Pairs %s%s |", string(rune(8593)), string(rune(8595)))
+	v.pList.TitleStyle = titleStyle
+	v.pList.BorderStyle = borderStyle
+	v.pList.SelectedRowStyle = selectedRowStyle
+	v.pList.TextStyle = textStyle
+
+	v.chart = widgets.NewPlot()
+	v.chart.Title = "| Chart (1h) |"
+	v.chart.AxesColor = ui.ColorClear
+	v.chart.LineColors[0] = ui.ColorGreen
+	v.chart.BorderStyle = borderStyle
+	v.chart.TitleStyle = titleStyle
+
+	v.liveTrades = widgets.NewTable()
+	v.liveTrades.Rows = [][]string{{"Amount", "Time", "Price"}}
+	v.liveTrades.Title = "| Live Trades |"
+	v.liveTrades.TextAlignment = ui.AlignCenter
+	v.liveTrades.RowSeparator = false
+	v.liveTrades.TitleStyle = titleStyle
+	v.liveTrades.TextStyle = textStyle
+	v.liveTrades.BorderStyle = borderStyle
+	v.liveTrades.RowStyles[0] = tableHeaderStyle
+
+	v.orderBook = widgets.NewTable()
+	v.orderBook.Rows = [][]string{{"", "Total", "Amount", "Bid", "Ask", "Amount", "Total", ""}}
+	v.orderBook.Title = "| Order Book |"
+	v.orderBook.TextAlignment = ui.AlignCenter
+	v.orderBook.RowSeparator = false
+	v.orderBook.TitleStyle = titleStyle
+	v.orderBook.TextStyle = textStyle
+	v.orderBook.BorderStyle = borderStyle
+	v.orderBook.RowStyles[0] = tableHeaderStyle
+
+	v.depth = widgets.NewPlot()
+	v.depth.Title = "| Depth |"
+	v.depth.AxesColor = ui.ColorClear
+	v.depth.LineColors = []ui.Color{ui.ColorGreen, ui.ColorRed}
+	v.depth.BorderStyle = borderStyle
+	v.depth.TitleStyle = titleStyle
+
+	v.analytics = newAnalyticsPanel()
+	v.heatmap = newHeatmapPanel()
+	v.compare = newComparePanel()
+
+	v.errorLog = widgets.NewList()
+	v.errorLog.Title = "| Errors |"
+	v.errorLog.TitleStyle = titleStyle
+	v.errorLog.BorderStyle = borderStyle
+	v.errorLog.TextStyle = textStyle
+	v.errorLog.SelectedRowStyle = textStyle
+
+	v.triggers = widgets.NewTable()
+	v.triggers.Title = "| Triggers |"
+	v.triggers.TextAlignment = ui.AlignCenter
+	v.triggers.RowSeparator = false
+	v.triggers.TitleStyle = titleStyle
+	v.triggers.TextStyle = textStyle
+	v.triggers.BorderStyle = borderStyle
+	v.triggers.RowStyles[0] = tableHeaderStyle
+
+	v.jobs = widgets.NewTable()
+	v.jobs.Title = "| Jobs |"
+	v.jobs.TextAlignment = ui.AlignCenter
+	v.jobs.RowSeparator = false
+	v.jobs.TitleStyle = titleStyle
+	v.jobs.TextStyle = textStyle
+	v.jobs.BorderStyle = borderStyle
+	v.jobs.RowStyles[0] = tableHeaderStyle
+
+	v.arbitrage = widgets.NewTable()
+	v.arbitrage.TextAlignment = ui.AlignCenter
+	v.arbitrage.RowSeparator = false
+	v.arbitrage.TitleStyle = titleStyle
+	v.arbitrage.TextStyle = textStyle
+	v.arbitrage.BorderStyle = borderStyle
+	v.arbitrage.RowStyles[0] = tableHeaderStyle
+
+	v.overview = widgets.NewTable()
+	v.overview.TextAlignment = ui.AlignRight
+	v.overview.RowSeparator = false
+	v.overview.TitleStyle = titleStyle
+	v.overview.TextStyle = textStyle
+	v.overview.BorderStyle = borderStyle
+
+	v.transfers = widgets.NewTable()
+	v.transfers.TextAlignment = ui.AlignCenter
+	v.transfers.RowSeparator = false
+	v.transfers.TitleStyle = titleStyle
+	v.transfers.TextStyle = textStyle
+	v.transfers.BorderStyle = borderStyle
+	v.transfers.RowStyles[0] = tableHeaderStyle
+
+	v.strategies = widgets.NewTable()
+	v.strategies.TextAlignment = ui.AlignCenter
+	v.strategies.RowSeparator = false
+	v.strategies.TitleStyle = titleStyle
+	v.strategies.TextStyle = textStyle
+	v.strategies.BorderStyle = borderStyle
+	v.strategies.RowStyles[0] = tableHeaderStyle
+
+	v.watchlist = widgets.NewTable()
+	v.watchlist.Title = "| Watchlist |"
+	v.watchlist.TextAlignment = ui.AlignRight
+	v.watchlist.RowSeparator = false
+	v.watchlist.TitleStyle = titleStyle
+	v.watchlist.TextStyle = textStyle
+	v.watchlist.BorderStyle = borderStyle
+
+	v.account = widgets.NewTable()
+	v.account.TextAlignment = ui.AlignRight
+	v.account.RowSeparator = false
+	v.account.TitleStyle = titleStyle
+	v.account.TextStyle = textStyle
+	v.account.BorderStyle = borderStyle
+	v.account.RowStyles[0] = tableHeaderStyle
+
+	v.alerts = widgets.NewList()
+	v.alerts.Title = "| Alerts |"
+	v.alerts.TitleStyle = titleStyle
+	v.alerts.BorderStyle = borderStyle
+	v.alerts.TextStyle = textStyle
+	v.alerts.SelectedRowStyle = textStyle
+
+	v.status = widgets.NewParagraph()
+	v.status.Border = false
+	v.status.TextStyle = textStyle
+
+	// help menu
+	v.help = widgets.NewTable()
+	v.help.Title = "| Help |"
+	v.help.Rows = [][]string{
+		{"Command", "Key"},
+		{"Select currency", "1, 2, 3, 4, 5"},
+		{"Select previous pair", "up, s, mouse wheel up"},
+		{"Select next pair", "down, w, mouse wheel down"},
+		{"Next layout", "l"},
+		{"Focus next panel", "tab"},
+		{"Maximise/Restore focused panel", "m"},
+		{"Next account profile", "a"},
+		{"Enter a command", ":"},
+		{"Increase/Decrease order book grouping", "+, -"},
+		{"Vertical/Horizontal order book", "v"},
+		{"Sort market overview", "o"},
+		{"Relative/Absolute trade times", "r"},
+		{"Next chart timeframe", "t"},
+		{"Show/Hide log", "g"},
+		{"Show/Hide this menu", "h"},
+		{"Quit", "q"},
+		{"", ""},
+		{"App version", version},
+	}
+	v.help.TextAlignment = 1
+	v.help.TextStyle = textStyle
+	v.help.TitleStyle = titleStyle
+	v.help.BorderStyle = borderStyle
+	v.help.RowStyles[0] = tableHeaderStyle
+
+	// log overlay
+	v.log = widgets.NewList()
+	v.log.Title = "| Log |"
+	v.log.TextStyle = textStyle
+	v.log.TitleStyle = titleStyle
+	v.log.BorderStyle = borderStyle
+	v.log.SelectedRowStyle = textStyle
+	v.log.WrapText = false
+
+	v.panels = map[string]panel{
+		layout.PanelCurrencies: {v.cList, &v.cList.Block},
+		layout.PanelPairs:      {v.pList, &v.pList.Block},
+		layout.PanelChart:      {v.chart, &v.chart.Block},
+		layout.PanelBook:       {v.orderBook, &v.orderBook.Block},
+		layout.PanelTrades:     {v.liveTrades, &v.liveTrades.Block},
+		layout.PanelDepth:      {v.depth, &v.depth.Block},
+		layout.PanelAnalytics:  {v.analytics, &v.analytics.Block},
+		layout.PanelHeatmap:    {v.heatmap, &v.heatmap.Block},
+		layout.PanelErrors:     {v.errorLog, &v.errorLog.Block},
+		layout.PanelTriggers:   {v.triggers, &v.triggers.Block},
+		layout.PanelJobs:       {v.jobs, &v.jobs.Block},
+		layout.PanelArbitrage:  {v.arbitrage, &v.arbitrage.Block},
+		layout.PanelTransfers:  {v.transfers, &v.transfers.Block},
+		layout.PanelCompare:    {v.compare, &v.compare.Block},
+		layout.PanelOverview:   {v.overview, &v.overview.Block},
+		layout.PanelStrategies: {v.strategies, &v.strategies.Block},
+		layout.PanelWatchlist:  {v.watchlist, &v.watchlist.Block},
+		layout.PanelAccount:    {v.account, &v.account.Block},
+		layout.PanelAlerts:     {v.alerts, &v.alerts.Block},
+	}
+
+	return &v
+}
+
+// Resize changes the dimensions of the view and returns the number of visible pair rows
+func (v *View) Resize(width, height int) int {
+	v.width, v.height = width, height
+
+	return v.arrange()
+}
+
+// SetLayout arranges panels using a layout, when maximised only the focused
+// panel is shown. Returns the number of visible pair rows
+func (v *View) SetLayout(l layout.Layout, focus string, maximised bool) int {
+	v.layout, v.focus, v.maximised = l, focus, maximised
+
+	return v.arrange()
+}
+
+func (v *View) arrange() int {
+	for name, p := range v.panels {
+		p.block.BorderStyle = borderStyle
+		if name == v.focus {
+			p.block.BorderStyle = focusBorderStyle
+		}
+		p.SetRect(0, 0, 0, 0)
+	}
+
+	v.grid = ui.NewGrid()
+	if p, ok := v.panels[v.focus]; ok && v.maximised {
+		v.grid.Set(ui.NewRow(1, p))
+	} else if v.layout.Panel != "" {
+		// a single panel layout has no rows or columns to convert
+		v.grid.Set(ui.NewRow(1, v.entry(v.layout.Node)...))
+	} else {
+		v.grid.Set(v.items(v.layout.Node)...)
+	}
+	// the last line is reserved for the status bar, blocks reserve a cell on each side for borders
+	v.grid.SetRect(0, 0, v.width, v.height-1)
+	v.status.SetRect(-1, v.height-2, v.width+1, v.height+1)
+	v.help.SetRect(0, 0, v.width, v.height)
+	v.log.SetRect(0, 0, v.width, v.height)
+	ui.Clear()
+
+	// grid assigns item dimensions while drawing
+	v.grid.Draw(ui.NewBuffer(v.grid.GetRect()))
+
+	return v.pList.Inner.Dy()
+}
+
+// items converts the children of a layout node into grid items
+func (v *View) items(n layout.Node) []interface{} {
+	var items []interface{}
+
+	for _, c := range n.Rows {
+		items = append(items, ui.NewRow(c.Ratio, v.entry(c)...))
+	}
+	for _, c := range n.Cols {
+		items = append(items, ui.NewCol(c.Ratio, v.entry(c)...))
+	}
+
+	return items
+}
+
+func (v *View) entry(n layout.Node) []interface{} {
+	if n.Panel != "" {
+		return []interface{}{v.panels[n.Panel]}
+	}
+
+	return v.items(n)
+}
+
+// Render draws the given state
+func (v *View) Render(s app.State) {
+	if s.HelpVisible {
+		ui.Render(v.help)
+		return
+	}
+
+	if s.LogVisible {
+		// show as many of the most recent entries as fit, the newest at the bottom
+		v.log.Rows = v.logs(v.log.Inner.Dy())
+		v.log.SelectedRow = len(v.log.Rows) - 1
+		ui.Render(v.log)
+		return
+	}
+
+	rows := make([]string, 0, len(s.Markets))
+	for i := range s.Markets {
+		rows = append(rows, fmt.Sprintf("%d. %s", i+1, s.Markets[i].Name))
+	}
+	v.cList.Rows = rows
+	v.cList.SelectedRow = s.MarketIndex
+
+	v.pList.Rows = s.PairRows()
+	v.pList.SelectedRow = s.PairIndex
+
+	// line chart requires at least two points
+	v.chart.Title = fmt.Sprintf("| Chart (%s) |", timeframeText(s.ChartStep()))
+	v.chart.Data = [][]float64{}
+	if len(s.Candles) > 1 {
+		v.chart.Data = [][]float64{candle.Closes(s.Candles)}
+	}
+
+	trades, headers := tradeRows(s, time.Now())
+	v.liveTrades.Rows = trades
+	v.liveTrades.RowStyles = make(map[int]ui.Style, len(headers))
+	for _, i := range headers {
+		v.liveTrades.RowStyles[i] = tableHeaderStyle
+	}
+
+	v.orderBook.Title = "| Order Book |"
+	if step := s.GroupStep(); step > 0 {
+		v.orderBook.Title = fmt.Sprintf("| Order Book (group %s) |", strconv.FormatFloat(step, 'f', stepDecimals(step), 64))
+	}
+	book, headers := bookRows(s, v.orderBook.Inner.Dy())
+	v.orderBook.Rows = book
+	v.orderBook.RowStyles = make(map[int]ui.Style, len(headers))
+	for _, i := range headers {
+		v.orderBook.RowStyles[i] = tableHeaderStyle
+	}
+	v.depth.Data = depthData(s.Book)
+	v.analytics.update(s)
+	v.heatmap.update(s)
+	v.compare.update(s)
+	v.errorLog.Rows = errorRows(s.Errors, s.Zone())
+	v.triggers.Rows = triggerRows(s.Triggers)
+	v.jobs.Rows = jobRows(s.Jobs)
+	v.arbitrage.Title = fmt.Sprintf("| Arbitrage (%d triangles) |", s.Triangles)
+	v.arbitrage.Rows = arbitrageRows(s.Arbitrage, v.arbitrage.Inner.Dy()-1)
+	v.overview.Title = overviewTitle(s)
+	v.overview.Rows, v.overview.RowStyles = overviewRows(s, v.overview.Inner.Dy()-1)
+	v.transfers.Title = fmt.Sprintf("| Deposits & Withdrawals (%d) |", len(s.Transfers))
+	v.transfers.Rows = transferRows(s.Transfers, v.transfers.Inner.Dy()-1, s.Zone())
+	v.strategies.Title = fmt.Sprintf("| Strategies (%d) |", len(s.Strategies))
+	v.strategies.Rows = strategyRows(s.Strategies, s.Zone())
+	v.watchlist.Rows, v.watchlist.RowStyles = watchlistRows(s)
+	v.account.Title = fmt.Sprintf("| Account %s |", s.ActiveProfile())
+	v.account.Rows = balanceRows(s.Balances)
+	v.alerts.Rows = alertRows(s.Alerts, s.Zone())
+
+	v.status.Text = statusText(s, time.Now())
+
+	ui.Render(v.grid, v.status)
+}
@@ -0,0 +1,51 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/georlav/bitstamp-cli/internal/layout"
+)
+
+func TestSetLayout(t *testing.T) {
+	tests := []struct {
+		name      string
+		layout    layout.Layout
+		focus     string
+		maximised bool
+	}{
+		{
+			name:   "single panel root",
+			layout: layout.Layout{Name: "chart", Node: layout.Node{Panel: layout.PanelChart}},
+			focus:  layout.PanelChart,
+		},
+		{
+			name: "rows",
+			layout: layout.Layout{Name: "rows", Node: layout.Node{Rows: []layout.Node{
+				{Ratio: 0.5, Panel: layout.PanelChart},
+				{Ratio: 0.5, Panel: layout.PanelTrades},
+			}}},
+			focus: layout.PanelTrades,
+		},
+		{
+			name: "maximised",
+			layout: layout.Layout{Name: "cols", Node: layout.Node{Cols: []layout.Node{
+				{Ratio: 0.5, Panel: layout.PanelTrades},
+				{Ratio: 0.5, Panel: layout.PanelChart},
+			}}},
+			focus:     layout.PanelChart,
+			maximised: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New("test", func(int) []string { return nil })
+			v.width, v.height = 120, 40
+			v.SetLayout(tt.layout, tt.focus, tt.maximised)
+
+			if r := v.panels[tt.focus].GetRect(); r.Dx() == 0 || r.Dy() == 0 {
+				t.Errorf("focused panel %s was not arranged, got %v", tt.focus, r)
+			}
+		})
+	}
+}
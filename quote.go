@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/fee"
+	"github.com/georlav/bitstamp-cli/internal/orderbook"
+	"github.com/georlav/bitstamp-cli/internal/profile"
+)
+
+const quoteUsage = "usage: bitstamp-cli quote buy|sell <amount> <pair>"
+
+// Prints the estimated fill of a market order against the current order book of a pair,
+// the fee rate of the account of the profile is used when it has credentials
+func runQuote(ctx context.Context, accounts *profile.Accounts, profileName string, args []string) error {
+	if len(args) != 3 || (args[0] != "buy" && args[0] != "sell") {
+		return errors.New(quoteUsage)
+	}
+	amount, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("%s is not a number greater than zero", args[1])
+	}
+
+	p, err := findPair(args[2])
+	if err != nil {
+		return err
+	}
+
+	book, err := bitstamp.NewHTTPAPI().GetOrderBook(ctx, p)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve %s order book, %w", p, err)
+	}
+
+	rate, source := fee.DefaultTaker, "default"
+	done := make(chan error, 1)
+	accounts.Select(ctx, profileName, func(err error) { done <- err })
+	if err := <-done; err != nil {
+		fmt.Fprintf(os.Stderr, "using the default fee, %s\n", err)
+	}
+	if _, api := accounts.Active(); api != nil {
+		balance, err := api.GetAccountBalance(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve account fees, %w", err)
+		}
+		fees, err := fee.Parse(balance)
+		if err != nil {
+			return err
+		}
+		if f, ok := fees[p]; ok {
+			rate, source = f, "profile "+profileName
+		}
+	}
+
+	e, err := fee.EstimateOrder(parseBookSide(book.Bids), parseBookSide(book.Asks), args[0] == "sell", amount, rate)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n", strings.ToUpper(p.String()), e)
+	fmt.Printf("mid %.8g, fee rate of %s\n", e.Mid, source)
+
+	return nil
+}
+
+// Parses price and amount pairs of an order book side
+func parseBookSide(levels [][]string) []orderbook.Level {
+	parsed := make([]orderbook.Level, 0, len(levels))
+	for _, l := range levels {
+		if len(l) >= 2 {
+			parsed = append(parsed, orderbook.ParseLevel(l[0], l[1]))
+		}
+	}
+
+	return parsed
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/georlav/bitstamp-cli/internal/profile"
+)
+
+const secretsUsage = "usage: bitstamp-cli secrets set|remove <profile>"
+
+// Stores or removes the credentials of a profile in the encrypted secrets file
+func runSecrets(path string, args []string) error {
+	if len(args) != 2 || (args[0] != "set" && args[0] != "remove") {
+		return errors.New(secretsUsage)
+	}
+	name := args[1]
+
+	passphrase, err := profile.ReadPassphrase("Secrets file passphrase: ")
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return errors.New("passphrase must not be empty")
+	}
+
+	secrets, err := profile.LoadSecrets(path, passphrase)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "set":
+		var c profile.Credentials
+		if c.Key, err = profile.ReadSecret("API key: "); err != nil {
+			return err
+		}
+		if c.Secret, err = profile.ReadSecret("API secret: "); err != nil {
+			return err
+		}
+		if !c.Valid() {
+			return errors.New("api key and secret must not be empty")
+		}
+		secrets[name] = c
+
+	case "remove":
+		if _, ok := secrets[name]; !ok {
+			return fmt.Errorf("profile %s is not in the secrets file", name)
+		}
+		delete(secrets, name)
+	}
+
+	if err := profile.SaveSecrets(path, passphrase, secrets); err != nil {
+		return err
+	}
+	fmt.Printf("updated secrets of profile %s in %s\n", name, path)
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/config"
+	"github.com/georlav/bitstamp-cli/internal/statusline"
+)
+
+const (
+	// statuslineTimeout time allowed for a statusline request, the first request of a pair loads its ticker
+	statuslineTimeout = time.Second * 5
+	// daemonStartTimeout time to wait for a started daemon to accept connections
+	daemonStartTimeout = time.Second * 3
+)
+
+// Prints the quotes of the statusline pairs, starting the daemon holding the websocket
+// connection when it is not running. With -daemon it runs the daemon instead
+func runStatusline(cfg config.Statusline, args []string, logFile, logLevel, logFormat string) error {
+	fs := flag.NewFlagSet("statusline", flag.ContinueOnError)
+	pairs := fs.String("pairs", strings.Join(cfg.Pairs, ","), "comma separated pairs to print")
+	format := fs.String("format", cfg.Format, "text/template executed with the quotes of the pairs")
+	socket := fs.String("socket", cfg.Socket, "unix socket of the statusline daemon")
+	daemon := fs.Bool("daemon", false, "run the statusline daemon in the foreground")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *daemon {
+		logger, closeLog, err := newLogger(logFile, logLevel, logFormat)
+		if err != nil {
+			return err
+		}
+		defer closeLog()
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		return statusline.NewDaemon(*socket, bitstamp.NewHTTPAPI(), logger).Run(ctx)
+	}
+
+	t, err := statusline.Parse(*format)
+	if err != nil {
+		return err
+	}
+
+	names := strings.Split(strings.ToLower(*pairs), ",")
+	quotes, err := statusline.Query(*socket, names, statuslineTimeout)
+	if errors.Is(err, statusline.ErrNotRunning) {
+		// the daemon is started and asked again
+		if err := startDaemon(*socket, logFile, logLevel, logFormat); err != nil {
+			return err
+		}
+		quotes, err = statusline.Query(*socket, names, statuslineTimeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	line, err := statusline.Render(t, quotes)
+	if err != nil {
+		return err
+	}
+	fmt.Println(line)
+
+	return nil
+}
+
+// Starts the statusline daemon in the background and waits for it to accept connections
+func startDaemon(socket, logFile, logLevel, logFormat string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable, %w", err)
+	}
+
+	cmd := exec.Command(exe, "-log-file", logFile, "-log-level", logLevel, "-log-format", logFormat,
+		"statusline", "-daemon", "-socket", socket)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start statusline daemon, %w", err)
+	}
+	if err := cmd.Process.Release(); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(daemonStartTimeout)
+	for time.Now().Before(deadline) {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			return conn.Close()
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	return fmt.Errorf("statusline daemon did not start listening on %s", socket)
+}
@@ -0,0 +1,6 @@
+package main
+
+import "os/exec"
+
+// Processes outlive their parent on windows, nothing needs to change
+func detach(cmd *exec.Cmd) {}
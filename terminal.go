@@ -0,0 +1,801 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/georlav/bitstamp"
+	"github.com/georlav/bitstamp-cli/internal/app"
+	"github.com/georlav/bitstamp-cli/internal/apperror"
+	"github.com/georlav/bitstamp-cli/internal/arbitrage"
+	"github.com/georlav/bitstamp-cli/internal/balance"
+	"github.com/georlav/bitstamp-cli/internal/candle"
+	"github.com/georlav/bitstamp-cli/internal/candlestore"
+	"github.com/georlav/bitstamp-cli/internal/compare"
+	"github.com/georlav/bitstamp-cli/internal/config"
+	"github.com/georlav/bitstamp-cli/internal/execution"
+	"github.com/georlav/bitstamp-cli/internal/fee"
+	"github.com/georlav/bitstamp-cli/internal/guard"
+	"github.com/georlav/bitstamp-cli/internal/layout"
+	"github.com/georlav/bitstamp-cli/internal/logging"
+	"github.com/georlav/bitstamp-cli/internal/notify"
+	"github.com/georlav/bitstamp-cli/internal/overview"
+	"github.com/georlav/bitstamp-cli/internal/profile"
+	"github.com/georlav/bitstamp-cli/internal/ratelimit"
+	"github.com/georlav/bitstamp-cli/internal/strategy"
+	"github.com/georlav/bitstamp-cli/internal/stream"
+	"github.com/georlav/bitstamp-cli/internal/transfer"
+	"github.com/georlav/bitstamp-cli/internal/trigger"
+	"github.com/georlav/bitstamp-cli/internal/view"
+	ui "github.com/gizak/termui/v3"
+)
+
+// terminal the interactive application, it connects the store to the requests, the websocket
+// stream and the services sending orders. Fields are set before the goroutines using them start
+type terminal struct {
+	cfg         *config.Config
+	ctx         context.Context
+	logger      *logging.Logger
+	limiter     *ratelimit.Limiter
+	accounts    *profile.Accounts
+	store       *app.Store
+	requests    *ratelimit.Group
+	bitClient   *bitstamp.HTTPAPI
+	candleStore *candlestore.Store
+	pairMap     map[string]bitstamp.Pair
+	quotes      *guard.Quotes
+	orders      *guard.Guard
+	pinned      profileOrders
+	arb         *arbitrage.Monitor
+	board       *overview.Board
+	st          *stream.Stream
+	notifier    *notify.Notifier
+	triggers    *trigger.Manager
+	jobs        *execution.Engine
+	strategies  *strategy.Runner
+}
+
+// Runs the terminal until it is quit or interrupted
+func runTerminal(cfg *config.Config, accounts *profile.Accounts, limiter *ratelimit.Limiter, logs logOptions) error {
+	logger, closeLog, err := newLogger(logs.file, logs.level, logs.format)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	logger.Info("starting", "version", version)
+
+	// requests of the terminal are logged as well, through the same limiter
+	http.DefaultClient.Transport = ratelimit.Transport{
+		Limiter: limiter,
+		Next:    logging.Transport{Logger: logger, Next: http.DefaultTransport},
+	}
+
+	state, err := newState(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	audit, err := guard.OpenAudit(cfg.GetAuditLog(), logger)
+	if err != nil {
+		return err
+	}
+	defer audit.Close()
+
+	bitClient := bitstamp.NewHTTPAPI()
+	t := &terminal{
+		cfg:         cfg,
+		ctx:         ctx,
+		logger:      logger,
+		limiter:     limiter,
+		accounts:    accounts,
+		store:       app.NewStore(state),
+		requests:    ratelimit.NewGroup(),
+		bitClient:   bitClient,
+		candleStore: candlestore.New(cfg.GetCacheDir(), bitClient),
+		pairMap:     make(map[string]bitstamp.Pair),
+		arb:         arbitrage.New(),
+		board:       overview.New(),
+	}
+	for _, p := range bitstamp.GetAllPairs() {
+		t.pairMap[p.String()] = p
+	}
+
+	// every order passes through the guardrails, prices of every pair on the stream are kept
+	// and pairs without recent prices are read from their ticker
+	t.quotes = guard.NewQuotes(bitClient, quoteMaxAge)
+	t.orders = guard.New(accounts, cfg.Guardrails, t.quotes.Quote, audit)
+	t.pinned = profileOrders{accounts: accounts, guard: t.orders}
+	logger.Info("guardrails", "read_only", t.orders.ReadOnly(), "max_deviation", cfg.Guardrails.MaxDeviation,
+		"daily_orders", cfg.Guardrails.DailyOrders, "audit_log", cfg.GetAuditLog())
+
+	if cfg.Profile != "" {
+		if _, s := t.store.Dispatch(app.ProfileSelected{Name: cfg.Profile}); s.ActiveProfile() != cfg.Profile {
+			return fmt.Errorf("unknown profile %s", cfg.Profile)
+		}
+	}
+
+	if cfg.Layout != "" {
+		if _, s := t.store.Dispatch(app.LayoutSelected{Name: cfg.Layout}); s.Layout().Name != cfg.Layout {
+			return fmt.Errorf("unknown layout %s", cfg.Layout)
+		}
+	}
+
+	if err := ui.Init(); err != nil {
+		return fmt.Errorf("failed to initialize ui, %w", err)
+	}
+	defer ui.Close()
+
+	activePair := t.store.State().ActivePair()
+
+	// periodically resync chart candles with bitstamp
+	go t.every(time.Hour, func() {
+		s := t.store.State()
+		t.updateChartData(s.ActivePair(), s.ChartStep())
+		t.updateComparison()
+	})
+	go t.updateLiveTrades(activePair)
+	go t.loadPairsInfo()
+
+	t.selectProfile(t.store.State().ActiveProfile())
+
+	// keep open orders updated, results are cached by bitstamp for 10 seconds
+	go t.every(time.Second*10, t.updateOpenOrders)
+	go t.every(time.Minute, t.updateBalances)
+	go t.every(time.Hour, t.updateTransfers)
+
+	// Keep a websocket connection subscribed to the active pair channels, providing data
+	// to live trade and order book widgets. Data missed while disconnected is reloaded on reconnect
+	t.st = stream.New(activePair, func(p bitstamp.Pair) []bitstamp.Channel {
+		return []bitstamp.Channel{bitstamp.GetDetailOrderBookChannel(p), bitstamp.GetLiveTradeChannel(p)}
+	}, logger)
+
+	// sinks were validated when the configuration was loaded, terminal sequences are written between frames
+	sinks, term, _ := notify.Build(cfg.Notifications.Sinks, cfg.Notifications.Command)
+	t.notifier = notify.New(sinks, cfg.Notifications.PerMinute, time.Second*time.Duration(cfg.Notifications.DedupeSeconds), logger)
+	defer t.notifier.Close()
+
+	if err := t.startTriggers(); err != nil {
+		return err
+	}
+	defer t.triggers.Close()
+
+	t.startJobs()
+	defer t.jobs.Close()
+
+	if err := t.startStrategies(); err != nil {
+		return err
+	}
+	defer t.strategies.Close()
+
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		t.st.Run(ctx, t.handler())
+	}()
+
+	v := view.New(version, logger.Tail)
+	v.Resize(ui.TerminalDimensions())
+	s := t.store.State()
+	t.store.Dispatch(app.Resized{PageSize: v.SetLayout(s.Layout(), s.FocusedPanel(), s.Maximised)})
+	v.Render(t.store.State())
+
+	t.watchPanels()
+
+	// wait for the websocket connection to close before restoring the terminal
+	shutdown := func() {
+		cancel()
+		select {
+		case <-streamDone:
+		case <-time.After(shutdownTimeout):
+		}
+	}
+	defer shutdown()
+
+	t.loop(v, term, activePair)
+
+	return nil
+}
+
+// Creates the initial state from the configuration
+func newState(cfg *config.Config) (app.State, error) {
+	state := app.NewState([]app.Market{
+		{Name: "ALL", Pairs: sortPairs(bitstamp.GetAllPairs())},
+		{Name: "BTC", Pairs: sortPairs(bitstamp.GetBTCPairs())},
+		{Name: "EUR", Pairs: sortPairs(bitstamp.GetEuroPairs())},
+		{Name: "GBP", Pairs: sortPairs(bitstamp.GetGBPPairs())},
+		{Name: "USD", Pairs: sortPairs(bitstamp.GetUSDPairs())},
+	}, cfg.GetLayouts())
+	state.MergeTrades = cfg.Tape.Merge
+	state.LargeTrade = cfg.Tape.LargeTrade
+	state.BookVertical = cfg.Book.Vertical
+	state.BookDepth = cfg.Book.Depth
+	state.ReadOnly = cfg.Guardrails.ReadOnly
+	state.RelativeTime = cfg.Time.Relative
+	// the zone was validated when the configuration was loaded
+	state.Location, _ = cfg.Time.Location()
+	for _, name := range cfg.GetComparePairs() {
+		p, err := findPair(name)
+		if err != nil {
+			return state, err
+		}
+		state.Compare = append(state.Compare, p)
+	}
+	for _, name := range cfg.GetWatchlist() {
+		p, err := findPair(name)
+		if err != nil {
+			return state, err
+		}
+		state.Watchlist = append(state.Watchlist, p)
+	}
+	for _, p := range cfg.GetProfiles() {
+		state.Profiles = append(state.Profiles, p.Name)
+	}
+
+	return state, nil
+}
+
+// Reports a failed operation in the error log, errors caused by exiting are ignored
+func (t *terminal) report(source string, err error) {
+	if err == nil || t.ctx.Err() != nil {
+		return
+	}
+	t.logger.Error("operation failed", "source", source, "kind", apperror.Classify(err), "error", err)
+	t.store.Dispatch(app.ErrorOccurred{Time: time.Now(), Source: source, Err: err})
+}
+
+// Calls fn retrying transient errors
+func (t *terminal) retry(fn func() error) error {
+	return apperror.Retry(t.ctx, retryAttempts, time.Second, fn)
+}
+
+// Runs fn every interval until exit, the first call is made immediately
+func (t *terminal) every(interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fn()
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update chart candles for a pair, candles built from live trades are merged with them.
+// Calls for the same pair and step are coalesced while a request is in flight
+func (t *terminal) updateChartData(p bitstamp.Pair, step time.Duration) {
+	t.requests.Do(fmt.Sprintf("chart %s %s", p, step), func() {
+		var candles []candle.Candle
+		err := t.retry(func() (err error) {
+			now := time.Now()
+			candles, err = t.candleStore.Candles(t.ctx, p, step, now.Add(-step*(app.MaxCandles-1)), now)
+			return err
+		})
+		if err != nil {
+			t.report("chart", err)
+			return
+		}
+
+		t.store.Dispatch(app.ChartLoaded{Pair: p, Step: step, Candles: candles})
+	})
+}
+
+// update candles of the compared pairs while the comparison chart is shown
+func (t *terminal) updateComparison() {
+	s := t.store.State()
+	if !s.Shows(layout.PanelCompare) || len(s.Compare) == 0 {
+		return
+	}
+
+	pairs, step := s.Compare, s.ChartStep()
+	t.requests.Do(fmt.Sprintf("compare %v %s", pairs, step), func() {
+		candles := make([][]candle.Candle, 0, len(pairs))
+		for _, p := range pairs {
+			var cs []candle.Candle
+			err := t.retry(func() (err error) {
+				now := time.Now()
+				cs, err = t.candleStore.Candles(t.ctx, p, step, now.Add(-step*(compare.Candles-1)), now)
+				return err
+			})
+			if err != nil {
+				t.report("compare", err)
+				return
+			}
+			candles = append(candles, cs)
+		}
+
+		t.store.Dispatch(app.ComparisonLoaded{Pairs: pairs, Step: step, Series: compare.Build(pairs, candles)})
+	})
+}
+
+// update live trades data for a pair, calls for the same pair are coalesced while a request is in flight
+func (t *terminal) updateLiveTrades(p bitstamp.Pair) {
+	t.requests.Do("trades "+p.String(), func() {
+		var data []bitstamp.GetTransactionResponse
+		err := t.retry(func() (err error) {
+			data, err = t.bitClient.GetTransactions(t.ctx, p, bitstamp.GetTransactionsRequest{
+				Time: "day",
+			})
+			return err
+		})
+		if err != nil {
+			t.report("trades", err)
+			return
+		}
+
+		trades := make([]app.Trade, 0, len(data))
+		for i := range data {
+			// the transactions endpoint only has second precision
+			var ts time.Time
+			if sec, err := strconv.ParseInt(data[i].Date, 10, 64); err == nil {
+				ts = time.Unix(sec, 0)
+			}
+
+			trades = append(trades, app.Trade{
+				Amount: data[i].Amount,
+				Price:  data[i].Price,
+				Time:   ts,
+				Sell:   data[i].Type == "1",
+			})
+		}
+
+		t.store.Dispatch(app.TradesLoaded{Pair: p, Trades: trades})
+	})
+}
+
+// retrieve counter and base decimals of pairs used to group order book levels and format
+// order amounts, and the currencies of pairs used to find arbitrage triangles
+func (t *terminal) loadPairsInfo() {
+	var info []bitstamp.GetTradingPairInfoResult
+	err := t.retry(func() (err error) {
+		info, err = t.bitClient.GetTradingPairsInfo(t.ctx)
+		return err
+	})
+	if err != nil {
+		t.report("pairs info", err)
+		return
+	}
+
+	decimals := make(map[bitstamp.Pair]int, len(info))
+	baseDecimals := make(map[bitstamp.Pair]int, len(info))
+	var markets []arbitrage.Market
+	for i := range info {
+		p, ok := t.pairMap[info[i].URLSymbol]
+		if !ok {
+			continue
+		}
+		decimals[p] = info[i].CounterDecimals
+		baseDecimals[p] = info[i].BaseDecimals
+
+		if m, ok := arbitrage.ParseMarket(p, info[i].Name); ok && info[i].Trading == "Enabled" {
+			markets = append(markets, m)
+		}
+	}
+	t.arb.SetMarkets(markets)
+
+	t.store.Dispatch(app.PairsInfoLoaded{Decimals: decimals, BaseDecimals: baseDecimals})
+}
+
+// Returns the counter decimals of a pair, 2 until pair info is loaded
+func (t *terminal) pairDecimals(p bitstamp.Pair) int {
+	if d, ok := t.store.State().Decimals[p]; ok {
+		return d
+	}
+	return 2
+}
+
+// Returns the base decimals of a pair, 8 until pair info is loaded
+func (t *terminal) pairBaseDecimals(p bitstamp.Pair) int {
+	if d, ok := t.store.State().BaseDecimals[p]; ok {
+		return d
+	}
+	return 8
+}
+
+// update open orders of the active profile, nothing is requested while it has no credentials
+func (t *terminal) updateOpenOrders() {
+	profileName, api := t.accounts.Active()
+	if api == nil {
+		return
+	}
+
+	t.requests.Do("open orders "+profileName, func() {
+		data, err := api.GetOpenOrders(t.ctx)
+		if err != nil {
+			t.report("open orders", err)
+			return
+		}
+
+		orders := make([]app.Order, 0, len(data))
+		for i := range data {
+			p, ok := t.pairMap[strings.ToLower(strings.ReplaceAll(data[i].CurrencyPair, "/", ""))]
+			if !ok {
+				continue
+			}
+
+			orders = append(orders, app.Order{Pair: p, Price: data[i].Price, Sell: data[i].Type == "1"})
+		}
+
+		t.store.Dispatch(app.OpenOrdersLoaded{Profile: profileName, Orders: orders})
+	})
+}
+
+// update balances and trading fees of the active profile, default fees apply while it has no credentials
+func (t *terminal) updateBalances() {
+	profileName, api := t.accounts.Active()
+	if api == nil {
+		return
+	}
+
+	t.requests.Do("balances "+profileName, func() {
+		var resp *bitstamp.GetAccountBalancesResponse
+		err := t.retry(func() (err error) {
+			resp, err = api.GetAccountBalance(t.ctx, nil)
+			return err
+		})
+		if err != nil {
+			t.report("balances", err)
+			return
+		}
+
+		fees, err := fee.Parse(resp)
+		if err != nil {
+			t.report("fees", err)
+			return
+		}
+		t.store.Dispatch(app.FeesLoaded{Profile: profileName, Fees: fees})
+
+		balances, err := balance.Parse(resp)
+		if err != nil {
+			t.report("balances", err)
+			return
+		}
+		t.store.Dispatch(app.BalancesLoaded{Profile: profileName, Balances: balances})
+	})
+}
+
+// update crypto deposits and withdrawals of the active profile
+func (t *terminal) updateTransfers() {
+	profileName, api := t.accounts.Active()
+	if api == nil {
+		return
+	}
+
+	t.requests.Do("transfers "+profileName, func() {
+		// the full history is loaded once, later updates only fetch the most recent page
+		fetch, known := transfer.Fetch, t.store.State().Transfers
+		if known != nil {
+			fetch = transfer.Latest
+		}
+
+		acc, err := t.accounts.Account(t.ctx, profileName)
+		if err != nil {
+			t.report("transfers", err)
+			return
+		}
+
+		var transfers []transfer.Transfer
+		err = t.retry(func() (err error) {
+			transfers, err = fetch(t.ctx, acc, false)
+			return err
+		})
+		if err != nil {
+			t.report("transfers", err)
+			return
+		}
+		if known != nil {
+			transfers = transfer.Merge(transfers, known)
+		}
+
+		t.store.Dispatch(app.TransfersLoaded{Profile: profileName, Transfers: transfers})
+	})
+}
+
+// Activates the account of a profile and loads its private data
+func (t *terminal) selectProfile(name string) {
+	t.accounts.Select(t.ctx, name, func(err error) {
+		if err != nil {
+			t.report("profile "+name, err)
+			return
+		}
+		t.logger.Info("profile selected", "profile", name)
+		t.updateOpenOrders()
+		t.updateBalances()
+		t.updateTransfers()
+	})
+}
+
+// Subscribes to the trades of pairs used by an owner besides the active pair
+func (t *terminal) watch(owner string, pairs []string) {
+	channels := make([]bitstamp.Channel, 0, len(pairs))
+	for _, name := range pairs {
+		if p, ok := t.pairMap[name]; ok {
+			channels = append(channels, bitstamp.GetLiveTradeChannel(p))
+		}
+	}
+	t.report("websocket", t.st.Watch(t.ctx, owner, channels))
+}
+
+// Lists an event in the alerts panel and notifies it
+func (t *terminal) alert(e notify.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	t.store.Dispatch(app.AlertRaised{Event: e})
+	t.notifier.Notify(e)
+}
+
+// Loads the saved triggers and arms them, their changes are alerted and their pairs watched
+func (t *terminal) startTriggers() error {
+	triggers, err := trigger.Load(t.cfg.GetTriggersFile(), t.store.State().Profiles, t.pinned.trigger, t.logger, func(tr []trigger.Trigger) {
+		prev, s := t.store.Dispatch(app.TriggersUpdated{Triggers: tr})
+		for _, e := range notify.TriggerEvents(prev.Triggers, s.Triggers) {
+			t.alert(e)
+		}
+		// called while the manager publishes a change, pairs are read once it is done
+		go func() {
+			t.watch("triggers", t.triggers.Pairs())
+		}()
+	})
+	if err != nil {
+		return err
+	}
+	t.triggers = triggers
+	t.triggers.Start()
+
+	// bracket entry orders may fill while the application is closed or their trades are missed
+	go t.every(time.Minute, func() {
+		t.triggers.CheckEntries(t.ctx)
+	})
+
+	return nil
+}
+
+// Starts the execution engine, job changes are alerted and their pairs watched
+func (t *terminal) startJobs() {
+	t.jobs = execution.New(t.pinned.job, t.bitClient, t.pairDecimals, t.logger, func(j []execution.Job) {
+		prev, s := t.store.Dispatch(app.JobsUpdated{Jobs: j})
+		for _, e := range notify.JobEvents(prev.Jobs, s.Jobs) {
+			t.alert(e)
+		}
+		// called holding the engine lock, pairs are read once it is released
+		go func() {
+			t.watch("jobs", t.jobs.Pairs())
+		}()
+	})
+}
+
+// Loads the strategy rules and runs them on the candles of their pairs, signals are shown and alerted
+func (t *terminal) startStrategies() error {
+	rules, err := strategy.Load(t.cfg.GetStrategiesFile())
+	if err != nil {
+		return err
+	}
+
+	t.strategies = strategy.NewRunner(rules, t.candleStore, t.orders, t.logger, strategy.Options{
+		RealOrders: t.cfg.Strategies.RealOrders,
+		Fee: func(p bitstamp.Pair) float64 {
+			return fee.Taker(t.store.State().Fees, p)
+		},
+		Decimals:     t.pairDecimals,
+		BaseDecimals: t.pairBaseDecimals,
+		Signal: func(sig strategy.Signal) {
+			t.store.Dispatch(app.MessageShown{Time: sig.Time, Text: fmt.Sprintf("strategy %s: %s", sig.Rule, sig.Text)})
+			t.alert(notify.Event{
+				Kind:    notify.KindStrategy,
+				Title:   fmt.Sprintf("%s strategy %s", strings.ToUpper(sig.Pair), sig.Rule),
+				Message: sig.Text,
+				Pair:    sig.Pair,
+				Time:    sig.Time,
+			})
+		},
+		Notify: func(statuses []strategy.Status) {
+			t.store.Dispatch(app.StrategiesUpdated{Statuses: statuses})
+		},
+	})
+	t.watch("strategies", t.strategies.Pairs())
+	go t.strategies.Start(t.ctx)
+	go t.every(time.Second, func() {
+		t.strategies.Tick(time.Now())
+	})
+
+	return nil
+}
+
+// Starts the goroutines updating panels that watch channels of their own while shown, and the request budget
+func (t *terminal) watchPanels() {
+	// the order books of arbitrage triangles are only watched while the arbitrage panel is shown
+	go t.every(time.Second, func() {
+		s := t.store.State()
+		visible := s.Shows(layout.PanelArbitrage)
+
+		var channels []bitstamp.Channel
+		if visible {
+			for _, p := range t.arb.Pairs() {
+				channels = append(channels, bitstamp.GetOrderBookChannel(p))
+			}
+		}
+		t.report("websocket", t.st.Watch(t.ctx, "arbitrage", channels))
+		if !visible {
+			return
+		}
+
+		t.store.Dispatch(app.ArbitrageUpdated{
+			Opportunities: t.arb.Opportunities(s.Fees, t.cfg.Arbitrage.Threshold/100, time.Now()),
+			Triangles:     t.arb.Triangles(),
+		})
+	})
+
+	// the trades of the pairs of the selected market are only watched while the overview panel is shown
+	go t.every(time.Second, func() {
+		s := t.store.State()
+		visible := s.Shows(layout.PanelOverview)
+
+		var channels []bitstamp.Channel
+		if visible {
+			for _, p := range s.Pairs() {
+				channels = append(channels, bitstamp.GetLiveTradeChannel(p))
+			}
+		}
+		t.report("websocket", t.st.Watch(t.ctx, "overview", channels))
+		if !visible {
+			return
+		}
+
+		if stale := t.board.Stale(s.Pairs(), time.Now(), overviewRefresh); len(stale) > 0 {
+			go t.seedOverview(stale)
+		}
+		t.store.Dispatch(app.OverviewUpdated{Rows: t.board.Rows(s.Pairs())})
+	})
+
+	// the trades of the watchlist pairs are only watched while the watchlist panel is shown
+	go t.every(time.Second, func() {
+		s := t.store.State()
+		visible := s.Shows(layout.PanelWatchlist)
+
+		var channels []bitstamp.Channel
+		if visible {
+			for _, p := range s.Watchlist {
+				channels = append(channels, bitstamp.GetLiveTradeChannel(p))
+			}
+		}
+		t.report("websocket", t.st.Watch(t.ctx, "watchlist", channels))
+		if !visible {
+			return
+		}
+
+		if stale := t.board.Stale(s.Watchlist, time.Now(), overviewRefresh); len(stale) > 0 {
+			go t.seedOverview(stale)
+		}
+		t.store.Dispatch(app.WatchlistUpdated{Rows: t.board.Rows(s.Watchlist)})
+	})
+
+	// report remaining request budget
+	go t.every(time.Second, func() {
+		remaining, limit := t.limiter.Remaining()
+		t.store.Dispatch(app.BudgetUpdated{Budget: app.Budget{Remaining: remaining, Limit: limit}})
+	})
+}
+
+// seeds the market overview and watchlist statistics of pairs from their tickers, requests are spaced
+// so a market with many pairs does not use up the request budget
+func (t *terminal) seedOverview(pairs []bitstamp.Pair) {
+	t.requests.Do("overview", func() {
+		for _, p := range pairs {
+			var ticker *bitstamp.GetTickerResponse
+			err := t.retry(func() (err error) {
+				ticker, err = t.bitClient.GetTicker(t.ctx, p)
+				return err
+			})
+			if err != nil {
+				t.report("overview", err)
+				t.board.Failed(p, time.Now())
+			} else {
+				t.board.Seed(p, ticker, time.Now())
+			}
+
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-time.After(tickerInterval):
+			}
+		}
+	})
+}
+
+// Polls ui events and renders the view until quit or exit, pair changes are applied after
+// pairDebounce so scrolling through pairs does not request data for every pair passed
+func (t *terminal) loop(v *view.View, term *notify.Terminal, subscribed bitstamp.Pair) {
+	cmds := commands{triggers: t.triggers, jobs: t.jobs, state: t.store.State, compare: func(pairs []bitstamp.Pair) {
+		t.store.Dispatch(app.CompareSelected{Pairs: pairs})
+		t.updateComparison()
+	}}
+
+	var (
+		uiEvents    = ui.PollEvents()
+		ticker      = time.NewTicker(time.Millisecond * 50).C
+		pairChanged <-chan time.Time
+	)
+	for {
+		select {
+		case e := <-uiEvents:
+			if e.ID == "<Resize>" {
+				payload := e.Payload.(ui.Resize)
+				t.store.Dispatch(app.Resized{PageSize: v.Resize(payload.Width, payload.Height)})
+			}
+
+			prev, s := t.store.Dispatch(app.KeyPressed{Key: e.ID})
+			if s.Quit {
+				return
+			}
+
+			if prev.LayoutIndex != s.LayoutIndex || prev.Focus != s.Focus || prev.Maximised != s.Maximised {
+				t.store.Dispatch(app.Resized{PageSize: v.SetLayout(s.Layout(), s.FocusedPanel(), s.Maximised)})
+			}
+
+			if prev.Timeframe != s.Timeframe || (prev.LayoutIndex != s.LayoutIndex && s.Comparison == nil) {
+				go t.updateComparison()
+			}
+
+			if prev.ActiveProfile() != s.ActiveProfile() {
+				t.selectProfile(s.ActiveProfile())
+			}
+
+			if prev.CommandSeq != s.CommandSeq {
+				go func(profile string, p bitstamp.Pair, line string) {
+					msg, err := cmds.run(t.ctx, profile, p, line)
+					if err != nil {
+						t.report("command", err)
+						return
+					}
+					t.logger.Info("command", "command", line, "result", msg)
+					t.store.Dispatch(app.MessageShown{Time: time.Now(), Text: msg})
+				}(s.ActiveProfile(), s.ActivePair(), s.Command)
+			}
+
+			if prev.Timeframe != s.Timeframe {
+				go t.updateChartData(s.ActivePair(), s.ChartStep())
+			}
+
+			if prev.ActivePair() != s.ActivePair() {
+				pairChanged = time.After(pairDebounce)
+			}
+
+		// Do the following only on pair change
+		case <-pairChanged:
+			pairChanged = nil
+
+			s := t.store.State()
+			if selectedPair := s.ActivePair(); selectedPair != subscribed {
+				subscribed = selectedPair
+				go t.updateLiveTrades(selectedPair)
+				go t.updateChartData(selectedPair, s.ChartStep())
+
+				// a failed subscription breaks the connection, the stream reconnects subscribed to the selected pair
+				t.report("websocket", t.st.SetPair(t.ctx, selectedPair))
+			}
+
+		case <-t.ctx.Done():
+			return
+
+		case <-ticker:
+			v.Render(t.store.State())
+			if term != nil {
+				if err := term.Flush(os.Stdout); err != nil {
+					t.logger.Warn("notification failed", "error", err)
+				}
+			}
+		}
+	}
+}
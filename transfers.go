@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/georlav/bitstamp-cli/internal/profile"
+	"github.com/georlav/bitstamp-cli/internal/transfer"
+)
+
+// dateLayout layout of the dates accepted by the transfers filters
+const dateLayout = "2006-01-02"
+
+// Lists the crypto deposits and withdrawals of the account of a profile as a table,
+// csv or json, written to stdout or a file
+func runTransfers(ctx context.Context, accounts *profile.Accounts, profileName string, args []string) error {
+	fs := flag.NewFlagSet("transfers", flag.ContinueOnError)
+	currency := fs.String("currency", "", "only list transfers of a currency")
+	kind := fs.String("kind", "", "only list transfers of a kind, deposit, withdrawal or iou")
+	from := fs.String("from", "", "only list transfers on or after a date, YYYY-MM-DD in UTC")
+	to := fs.String("to", "", "only list transfers before a date, YYYY-MM-DD in UTC")
+	ious := fs.Bool("ious", false, "include ripple IOU transactions")
+	format := fs.String("format", "table", "output format, table, csv or json")
+	output := fs.String("o", "", "write to a file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f := transfer.Filter{Currency: *currency, Kind: transfer.Kind(*kind)}
+	if f.Kind != "" && f.Kind != transfer.KindDeposit && f.Kind != transfer.KindWithdrawal && f.Kind != transfer.KindIOU {
+		return fmt.Errorf("unknown transfer kind %s", *kind)
+	}
+	for _, d := range []struct {
+		value string
+		t     *time.Time
+	}{{*from, &f.From}, {*to, &f.To}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.Parse(dateLayout, d.value)
+		if err != nil {
+			return fmt.Errorf("invalid date %s, expected YYYY-MM-DD", d.value)
+		}
+		*d.t = t
+	}
+
+	write := map[string]func(io.Writer, []transfer.Transfer) error{
+		"table": writeTransfersTable,
+		"csv":   transfer.WriteCSV,
+		"json":  transfer.WriteJSON,
+	}[*format]
+	if write == nil {
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	acc, err := accounts.Account(ctx, profileName)
+	if err != nil {
+		return err
+	}
+	transfers, err := transfer.Fetch(ctx, acc, *ious)
+	if err != nil {
+		return err
+	}
+	transfers = transfer.Select(transfers, f)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s, %w", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return write(w, transfers)
+}
+
+// Writes transfers as an aligned table
+func writeTransfersTable(w io.Writer, transfers []transfer.Transfer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tKIND\tCURRENCY\tAMOUNT\tADDRESS\tTXID")
+	for _, t := range transfers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Time.Format("2006-01-02 15:04:05"), t.Kind,
+			strings.ToUpper(t.Currency), t.Amount, t.Address, t.TxID)
+	}
+
+	return tw.Flush()
+}